@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// BenchResult is how long one target took in a benchmark run.
+type BenchResult struct {
+	Target   string
+	Duration time.Duration
+	ExitCode int
+}
+
+// Bench runs targets one after another, timing each, and returns the
+// results slowest first. Unlike a sequence it goes on past failures, so
+// every target is measured. Progress and the report are written to
+// stdio.Stdout.
+func (r *Runner) Bench(targets []string, stdio Stdio) []BenchResult {
+	results := make([]BenchResult, len(targets))
+	for i, target := range targets {
+		fmt.Fprintf(stdio.Stdout, "==> [%d/%d] %s\n", i+1, len(targets), target)
+		start := time.Now()
+		err := r.run(target, stdio)
+		results[i] = BenchResult{Target: target, Duration: time.Since(start).Round(time.Millisecond), ExitCode: exitCode(err)}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Duration > results[j].Duration })
+	fmt.Fprint(stdio.Stdout, formatBench(results))
+	return results
+}
+
+// formatBench renders results as an aligned table with a total.
+func formatBench(results []BenchResult) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "time\texit\ttarget\t")
+	var total time.Duration
+	for _, res := range results {
+		fmt.Fprintf(w, "%s\t%d\t%s\t\n", res.Duration, res.ExitCode, res.Target)
+		total += res.Duration
+	}
+	fmt.Fprintf(w, "%s\t\ttotal\t\n", total)
+	w.Flush()
+	return b.String()
+}
+
+// writeBenchCSV writes results as CSV with a header, durations in seconds.
+func writeBenchCSV(w io.Writer, results []BenchResult) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"target", "seconds", "exit_code"})
+	for _, res := range results {
+		out.Write([]string{res.Target, strconv.FormatFloat(res.Duration.Seconds(), 'f', 3, 64), strconv.Itoa(res.ExitCode)})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// writeBenchFile writes results to path as CSV.
+func writeBenchFile(path string, results []BenchResult) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeBenchCSV(file, results); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// benchTargets returns the targets -bench names: the comma-separated list
+// given, each of which must be listed in tabs, or for "phony" every action
+// target in tabs, once each in tab order. "phony" leaves out aliases,
+// internal targets and workflows, which would only time their steps
+// again, and the targets marked Confirm or requiring variables, which are
+// not to be run unasked.
+func benchTargets(spec string, tabs []Tab, workflows map[string]Workflow) ([]string, error) {
+	if spec != "phony" {
+		listed := targetNames(tabs)
+		var targets []string
+		for _, target := range strings.Split(spec, ",") {
+			target = strings.TrimSpace(target)
+			if target == "" {
+				continue
+			}
+			if _, ok := workflows[target]; ok || !listed[target] {
+				return nil, fmt.Errorf("%s is not a listed target", target)
+			}
+			targets = append(targets, target)
+		}
+		return targets, nil
+	}
+	seen := make(map[string]bool)
+	var targets []string
+	for _, t := range tabs {
+		for _, opt := range t.Options {
+			if _, ok := workflows[opt.Target]; ok || !opt.IsPhony || opt.Internal || opt.AliasOf != "" || seen[opt.Target] {
+				continue
+			}
+			if opt.Confirm || len(opt.Requires) > 0 {
+				continue
+			}
+			seen[opt.Target] = true
+			targets = append(targets, opt.Target)
+		}
+	}
+	return targets, nil
+}
+
+// benchFailed reports whether any of results exited non-zero.
+func benchFailed(results []BenchResult) bool {
+	for _, res := range results {
+		if res.ExitCode != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// The Bubble Tea front-end's styles, matching the terminal UI's colors.
+var (
+	teaCurrentTab           = ansi.Style{}.ForegroundColor(ansi.Yellow)
+	teaCurrentTabAccessible = ansi.Style{}.Bold().ForegroundColor(ansi.Black).BackgroundColor(ansi.Yellow)
+	teaPassed               = ansi.Style{}.ForegroundColor(ansi.Green)
+	teaFailed               = ansi.Style{}.ForegroundColor(ansi.Red)
+	teaPassedAccessible     = ansi.Style{}.Bold().ForegroundColor(ansi.Cyan)
+	teaFailedAccessible     = ansi.Style{}.Bold().ForegroundColor(ansi.Yellow)
+)
+
+// teaModel is the Bubble Tea front-end state. It mirrors runTUI: Left/Right
+// switch tabs, Up/Down move through targets and Enter runs the highlighted
+// one.
+type teaModel struct {
+	tabs   []Tab
+	runner *Runner
+
+	tab       int
+	cursor    int
+	width     int
+	height    int
+	searching bool
+	query     string
+	depSearch bool   // query matches prerequisites instead of names
+	info      string // description being shown; empty when hidden
+	status    string
+	confirm   bool       // preview runs before starting them, see uiOptions.Confirm
+	pending   string     // target whose preview is being shown
+	args      []string   // the arguments pending runs with
+	asking    MakeOption // target whose required variables are asked for
+	answer    string     // the arguments typed for asking, see withVars
+	git       string     // gitSummary of the Makefile directory
+
+	accessible   bool               // see uiOptions.Accessible
+	env          string             // see uiOptions.Env
+	icons        map[string]string  // see uiOptions.TabIcons
+	ci           map[string]Outcome // see uiOptions.CIResults
+	showInternal bool               // list targets marked Internal
+	onlyPhony    bool               // list only action targets, see MakeOption.IsPhony
+}
+
+// teaRunDoneMsg reports a finished run back to the model.
+type teaRunDoneMsg struct {
+	target string
+	err    error
+}
+
+// teaGitMsg carries a refreshed gitSummary.
+type teaGitMsg string
+
+// teaRun adapts Runner.Run to tea.ExecCommand so Bubble Tea releases the
+// terminal while make is running.
+type teaRun struct {
+	runner *Runner
+	target string
+	args   []string
+}
+
+func (c teaRun) Run() error          { return c.runner.Run(c.target, terminalStdio, c.args...) }
+func (c teaRun) SetStdin(io.Reader)  {}
+func (c teaRun) SetStdout(io.Writer) {}
+func (c teaRun) SetStderr(io.Writer) {}
+
+func (m teaModel) Init() tea.Cmd { return m.refreshGit() }
+
+// refreshGit schedules the next git status refresh.
+func (m teaModel) refreshGit() tea.Cmd {
+	dir := m.runner.Dir()
+	return tea.Tick(gitRefreshInterval, func(time.Time) tea.Msg {
+		return teaGitMsg(gitSummary(dir))
+	})
+}
+
+// current returns the highlighted option; callers check visible is non-empty.
+func (m teaModel) current() MakeOption { return m.visible()[m.cursor] }
+
+// visible returns the current tab's options matching the search query,
+// by name and comment or, in prerequisite mode, by prerequisite, and to
+// phony targets when onlyPhony is set.
+func (m teaModel) visible() []MakeOption {
+	q := strings.ToLower(m.query)
+	var matched []MakeOption
+	for _, opt := range m.tabs[m.tab].Options {
+		if (opt.Internal && !m.showInternal) || (m.onlyPhony && !opt.IsPhony) {
+			continue
+		}
+		if m.depSearch {
+			if q == "" || dependsOn(opt, q) {
+				matched = append(matched, opt)
+			}
+		} else if strings.Contains(strings.ToLower(opt.Target), q) || strings.Contains(strings.ToLower(opt.Comment), q) {
+			matched = append(matched, opt)
+		}
+	}
+	return matched
+}
+
+func (m teaModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		return m, nil
+	case teaGitMsg:
+		m.git = string(msg)
+		return m, m.refreshGit()
+	case teaRunDoneMsg:
+		m.status = fmt.Sprintf("%s %s exited %d", m.runner.Program(), msg.target, exitCode(msg.err))
+		return m, nil
+	case tea.KeyMsg:
+		if m.info != "" {
+			m.info = ""
+			return m, nil
+		}
+		if m.pending != "" {
+			target := m.pending
+			m.pending = ""
+			if msg.String() == "enter" || msg.String() == "y" {
+				return m, m.run(target, m.args)
+			}
+			return m, nil
+		}
+		if m.asking.Target != "" {
+			return m.updateAsking(msg)
+		}
+		if m.searching {
+			return m.updateSearch(msg), nil
+		}
+		return m.updateList(msg)
+	}
+	return m, nil
+}
+
+func (m teaModel) updateSearch(msg tea.KeyMsg) teaModel {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.searching = false
+		m.query = ""
+	case tea.KeyEnter:
+		m.searching = false
+	case tea.KeyTab:
+		m.depSearch = !m.depSearch
+	case tea.KeyBackspace:
+		if r := []rune(m.query); len(r) > 0 {
+			m.query = string(r[:len(r)-1])
+		}
+	case tea.KeySpace:
+		m.query += " "
+	case tea.KeyRunes:
+		m.query += string(msg.Runes)
+	}
+	m.cursor = 0
+	return m
+}
+
+// updateAsking edits the arguments asked for the variables a target
+// requires, and starts it with them on Enter.
+func (m teaModel) updateAsking(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.asking = MakeOption{}
+	case tea.KeyEnter:
+		args, err := shellWords(m.answer)
+		if err != nil {
+			m.status = "Could not read the arguments: " + err.Error()
+			return m, nil
+		}
+		opt := m.asking
+		m.asking = MakeOption{}
+		return m.start(opt, args)
+	case tea.KeyBackspace:
+		if r := []rune(m.answer); len(r) > 0 {
+			m.answer = string(r[:len(r)-1])
+		}
+	case tea.KeySpace:
+		m.answer += " "
+	case tea.KeyRunes:
+		m.answer += string(msg.Runes)
+	}
+	return m, nil
+}
+
+func (m teaModel) updateList(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	count := len(m.visible())
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "left", "h":
+		if m.tab > 0 {
+			m.tab--
+			m.cursor = 0
+		}
+	case "right", "l":
+		if m.tab < len(m.tabs)-1 {
+			m.tab++
+			m.cursor = 0
+		}
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < count-1 {
+			m.cursor++
+		}
+	case "/":
+		m.searching = true
+		m.depSearch = false
+	case "d":
+		m.searching = true
+		m.depSearch = true
+	case ".":
+		m.showInternal = !m.showInternal
+		m.cursor = 0
+	case "a":
+		m.onlyPhony = !m.onlyPhony
+		m.cursor = 0
+	case "esc":
+		m.query = ""
+		m.cursor = 0
+	case "i", "m":
+		if count > 0 {
+			opt := m.current()
+			desc := opt.Comment
+			if desc == "" {
+				desc = "No description available."
+			}
+			if recipe := describeRecipe(opt.Recipe); recipe != "" {
+				desc += "\n\nRecipe:\n" + recipe
+			}
+			if opt.Source != "" {
+				desc += "\n\nDefined in: " + opt.Source
+			}
+			desc += "\n\n" + reverseDeps(m.tabs).describeImpact(opt)
+			desc += "\n\nRuns: " + strings.Join(m.runner.CommandLine(opt.Target), " ")
+			m.info = opt.Target + "\n\n" + desc
+		}
+	case "V":
+		if vars, err := m.runner.Variables(); err != nil {
+			m.status = err.Error()
+		} else {
+			m.info = "Variables\n\n" + describeVariables(vars, m.runner.Makefile)
+		}
+	case "S":
+		if count > 0 {
+			if source, err := m.runner.targetSource(m.current()); err != nil {
+				m.status = err.Error()
+			} else {
+				m.info = m.current().Target + "\n\n" + source
+			}
+		}
+	case "o":
+		dir := m.runner.Dir()
+		if err := openInFileManager(dir); err != nil {
+			m.status = "Makefile directory: " + dir
+		}
+	case "enter":
+		if count > 0 {
+			return m.start(m.current(), nil)
+		}
+	}
+	return m, nil
+}
+
+// start runs opt with args, first asking for the variables it requires
+// that args leave unset, and with m.confirm or for a target marked Confirm
+// once its preview is accepted.
+func (m teaModel) start(opt MakeOption, args []string) (tea.Model, tea.Cmd) {
+	if missing := m.runner.MissingVars(opt.Target, args); len(missing) > 0 {
+		m.asking, m.answer = opt, withVars(args, missing)
+		m.status = ""
+		return m, nil
+	}
+	if m.confirm || opt.Confirm {
+		m.pending, m.args = opt.Target, args
+		return m, nil
+	}
+	return m, m.run(opt.Target, args)
+}
+
+// run hands the terminal to target's run.
+func (m teaModel) run(target string, args []string) tea.Cmd {
+	return tea.Exec(teaRun{m.runner, target, args}, func(err error) tea.Msg {
+		return teaRunDoneMsg{target, err}
+	})
+}
+
+func (m teaModel) View() string {
+	var b strings.Builder
+	labels := make([]string, len(m.tabs))
+	widths := make([]int, len(m.tabs))
+	for i, t := range m.tabs {
+		labels[i] = tabLabel(t.Name, m.icons)
+		if i == m.tab && m.accessible {
+			labels[i] = "[" + labels[i] + "]"
+		}
+		widths[i] = ansi.StringWidth(labels[i])
+	}
+	first, last := tabBarWindow(widths, m.tab, m.width/2)
+	if first > 0 {
+		b.WriteString("‹ ")
+	}
+	for i := first; i < last; i++ {
+		switch {
+		case i == m.tab && m.accessible:
+			b.WriteString(teaCurrentTabAccessible.Styled(labels[i]) + " ")
+		case i == m.tab:
+			b.WriteString(teaCurrentTab.Styled(labels[i]) + " ")
+		default:
+			b.WriteString(labels[i] + " ")
+		}
+	}
+	if last < len(m.tabs) {
+		b.WriteString("›")
+	}
+	if env := envLabel(m.env, m.runner.Makefile); env != "" {
+		b.WriteString("  " + env)
+	}
+	if m.git != "" {
+		b.WriteString("  git: " + m.git)
+	}
+	b.WriteString("\n\n")
+
+	if m.info != "" {
+		b.WriteString(m.info + "\n\n(press any key to close)\n")
+		return b.String()
+	}
+	if m.pending != "" {
+		b.WriteString("Run " + m.runner.Resolve(m.pending) + "?\n\n" + m.runner.Preview(m.pending, m.args...) + "\n\n(enter or y to run, any other key to cancel)\n")
+		return b.String()
+	}
+	if m.asking.Target != "" {
+		missing := m.runner.MissingVars(m.asking.Target, nil)
+		b.WriteString("Run " + m.asking.Target + ": set " + strings.Join(missing, ", ") + "\n\nArguments: " + m.answer + "█\n\n")
+		if m.status != "" {
+			b.WriteString(m.status + "\n")
+		}
+		b.WriteString("(enter to run, esc to cancel)\n")
+		return b.String()
+	}
+
+	opts := m.visible()
+	rows := m.height - 5 // tab bar, blank lines, search and footer
+	if rows < 1 {
+		rows = len(opts)
+	}
+	start := 0
+	if m.cursor >= rows {
+		start = m.cursor - rows + 1
+	}
+	for i := start; i < len(opts) && i < start+rows; i++ {
+		prefix := "  "
+		if i == m.cursor {
+			prefix = "> "
+		}
+		ci := ciMarker(m.ci, opts[i])
+		outcome := m.runner.Outcomes.Get(opts[i])
+		if m.accessible {
+			ci += outcomeText(outcome)
+		}
+		label := formatLabel(opts[i], m.width-ansi.StringWidth(prefix+ci))
+		switch {
+		case outcome == OutcomePassed && m.accessible:
+			label = teaPassedAccessible.Styled(label)
+		case outcome == OutcomeFailed && m.accessible:
+			label = teaFailedAccessible.Styled(label)
+		case outcome == OutcomePassed:
+			label = teaPassed.Styled(label)
+		case outcome == OutcomeFailed:
+			label = teaFailed.Styled(label)
+		}
+		b.WriteString(prefix + ci + label + "\n")
+	}
+	if len(opts) == 0 {
+		b.WriteString("  (no targets)\n")
+	}
+
+	b.WriteString("\n")
+	mode := "/"
+	if m.depSearch {
+		mode = "needs: "
+	}
+	switch {
+	case m.searching:
+		b.WriteString(mode + m.query + "█  (tab: name/prerequisite)\n")
+	case m.query != "":
+		b.WriteString("filter " + mode + m.query + " (esc to clear)\n")
+	case m.status != "":
+		b.WriteString(m.status + "\n")
+	default:
+		b.WriteString("←/→ tabs  ↑/↓ move  enter run  / search  d by prerequisite  a actions  . internal  i info  V variables  S source  o open dir  q quit")
+		if m.ci != nil {
+			b.WriteString("  " + ciLegend)
+		}
+		if jobs := m.runner.jobFlags(); jobs != nil {
+			b.WriteString("  " + strings.Join(jobs, " "))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// runBubbleTea is the Bubble Tea alternative to runTUI.
+func runBubbleTea(tabs []Tab, runner *Runner, ui uiOptions) {
+	m := teaModel{tabs: tabs, runner: runner, tab: ui.StartTab, icons: ui.TabIcons, status: ui.Notice, confirm: ui.Confirm, accessible: ui.Accessible, env: ui.Env, ci: ui.CIResults, git: gitSummary(runner.Dir())}
+	if _, err := tea.NewProgram(m, tea.WithAltScreen()).Run(); err != nil {
+		fmt.Println(err)
+	}
+}
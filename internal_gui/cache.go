@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"internal_gui/makefile"
+)
+
+// cacheVersion is part of every cache key; bump it when MakeOption or the
+// parsers change so stale entries are ignored.
+const cacheVersion = 12
+
+// sourceStamp identifies one version of a file. A missing file is stamped
+// with a Size of -1.
+type sourceStamp struct {
+	ModTime int64
+	Size    int64
+}
+
+func stampOf(path string) sourceStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return sourceStamp{Size: -1}
+	}
+	return sourceStamp{ModTime: info.ModTime().UnixNano(), Size: info.Size()}
+}
+
+// parseCacheEntry is what is stored for one build file.
+type parseCacheEntry struct {
+	Sources map[string]sourceStamp
+	Options []MakeOption
+}
+
+// parseCachePath returns the cache file for tool's build file at path, in
+// the user cache directory.
+func parseCachePath(tool BuildTool, path string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	sum := sha256.Sum256([]byte(tool.Name + "\x00" + path))
+	name := hex.EncodeToString(sum[:8]) + ".gob"
+	return filepath.Join(dir, "coolbox", "parse", fmt.Sprintf("v%d", cacheVersion), name), nil
+}
+
+// parseCached returns tool's targets for the build file at path, read with
+// opts, reusing the entry in cacheFile while none of the files the last
+// parse depended on have changed. The cache is best-effort: any problem
+// reading or writing it falls back to a normal parse.
+func parseCached(tool BuildTool, path, cacheFile string, opts makefile.Options) ([]MakeOption, error) {
+	if data, err := os.ReadFile(cacheFile); err == nil {
+		var entry parseCacheEntry
+		if gob.NewDecoder(bytes.NewReader(data)).Decode(&entry) == nil && entry.fresh() {
+			diag.debugf("parse cache hit for %s", path)
+			return entry.Options, nil
+		}
+		diag.debugf("parse cache for %s is stale", path)
+	}
+
+	options, sources, err := parseBuildFile(tool, path, opts)
+	if err != nil {
+		return nil, err
+	}
+	entry := parseCacheEntry{Sources: make(map[string]sourceStamp), Options: options}
+	for _, source := range sources {
+		if abs, err := filepath.Abs(source); err == nil {
+			source = abs
+		}
+		entry.Sources[source] = stampOf(source)
+	}
+	var buf bytes.Buffer
+	if gob.NewEncoder(&buf).Encode(entry) == nil {
+		if os.MkdirAll(filepath.Dir(cacheFile), 0o755) == nil {
+			// A torn write only fails to decode next time.
+			os.WriteFile(cacheFile, buf.Bytes(), 0o644)
+		}
+	}
+	return options, nil
+}
+
+// fresh reports whether every source still matches its stamp.
+func (e parseCacheEntry) fresh() bool {
+	return freshStamps(e.Sources)
+}
+
+// freshStamps reports whether every file in stamps still matches its
+// stamp; an empty set is never fresh.
+func freshStamps(stamps map[string]sourceStamp) bool {
+	if len(stamps) == 0 {
+		return false
+	}
+	for source, stamp := range stamps {
+		if stampOf(source) != stamp {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// rootDirTab is the tab of -categorize-by dir for the targets defined in
+// the Makefile's own directory.
+const rootDirTab = "."
+
+// categorize sorts options into tabs according to mode: "name" (or "")
+// buckets them by the words in their names as categorizeOptions does,
+// "dir" makes a tab per directory they are defined in, relative to root,
+// and "section" a tab per "##@ Title" header. In every mode @category
+// annotations take precedence.
+func categorize(options []MakeOption, mode, root string) ([]Tab, error) {
+	switch mode {
+	case "", "name":
+		return categorizeOptions(options), nil
+	case "dir":
+		return categorizeByDir(options, root), nil
+	case "section":
+		return categorizeBySection(options), nil
+	}
+	return nil, fmt.Errorf("unknown categorization %q (want dir, name or section)", mode)
+}
+
+// categorizeByDir groups options by the directory of the file defining
+// them, which matters once includes or fragments pull targets in from
+// subdirectories. The tabs come in the order their first target was
+// defined, so the Makefile's own directory usually leads.
+func categorizeByDir(options []MakeOption, root string) []Tab {
+	return groupOptions(options, func(opt MakeOption) string {
+		if opt.Source == "" {
+			return rootDirTab
+		}
+		dir := filepath.Dir(opt.Source)
+		if rel, err := filepath.Rel(root, dir); err == nil {
+			dir = rel
+		}
+		return filepath.ToSlash(dir)
+	})
+}
+
+// categorizeBySection groups options by the "##@" header above them.
+// Targets before the first header, such as help, land in the Other tab.
+func categorizeBySection(options []MakeOption) []Tab {
+	return groupOptions(options, func(opt MakeOption) string {
+		if opt.Section == "" {
+			return otherTab
+		}
+		return opt.Section
+	})
+}
+
+// groupOptions puts every option without an @category annotation into the
+// tab named by key, adding tabs in the order they are first needed, and
+// then places the annotated ones.
+func groupOptions(options []MakeOption, key func(MakeOption) string) []Tab {
+	var tabs []Tab
+	index := make(map[string]int)
+	for _, opt := range options {
+		if opt.Category != "" {
+			continue
+		}
+		name := key(opt)
+		diag.debugf("categorized %s as %s: grouped by where it is defined", opt.Target, name)
+		i, ok := index[name]
+		if !ok {
+			i = len(tabs)
+			index[name] = i
+			tabs = append(tabs, Tab{Name: name})
+		}
+		tabs[i].Options = append(tabs[i].Options, opt)
+	}
+	return addCategorized(tabs, options)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// changedFiles lists the files under dir that differ from HEAD, plus
+// untracked files, as paths relative to dir. ok is false when dir is not in
+// a git repository.
+func changedFiles(dir string) (files []string, ok bool) {
+	diff, err := exec.Command("git", "-C", dir, "diff", "--name-only", "--relative", "HEAD").Output()
+	if err != nil {
+		return nil, false
+	}
+	untracked, _ := exec.Command("git", "-C", dir, "ls-files", "--others", "--exclude-standard").Output()
+	files = append(strings.Fields(string(diff)), strings.Fields(string(untracked))...)
+	return files, true
+}
+
+// referencesChanged reports whether opt's prerequisites or recipe mention
+// one of the changed files, or a directory or glob covering one. It is a
+// heuristic: only literal paths in the Makefile can be matched.
+func referencesChanged(opt MakeOption, changed []string) bool {
+	if len(changed) == 0 {
+		return false
+	}
+	words := append([]string(nil), opt.Deps...)
+	words = append(words, strings.FieldsFunc(opt.Recipe, func(r rune) bool {
+		return strings.ContainsRune(" \t\n;|&()<>'\"=`", r)
+	})...)
+	for _, word := range words {
+		word = strings.TrimSuffix(strings.TrimPrefix(word, "./"), "/")
+		if word == "" || word == "." || strings.Contains(word, "$") {
+			continue
+		}
+		for _, file := range changed {
+			for path := file; path != "."; path = filepath.Dir(path) {
+				if word == path {
+					return true
+				}
+				if matched, _ := filepath.Match(word, path); matched {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ciLegend explains the markers ciMarker puts before targets.
+const ciLegend = "CI: ✓ passed  ✗ failed"
+
+// loadCIResults reads a CI results file, a JSON object mapping target names
+// to a status such as "passed" or "failure". Statuses that are neither a
+// pass nor a failure, like "skipped", are left out.
+func loadCIResults(path string) (map[string]Outcome, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var statuses map[string]string
+	if err := json.Unmarshal(data, &statuses); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	results := make(map[string]Outcome)
+	for target, status := range statuses {
+		switch strings.ToLower(status) {
+		case "pass", "passed", "success", "succeeded", "ok":
+			results[target] = OutcomePassed
+		case "fail", "failed", "failure", "error":
+			results[target] = OutcomeFailed
+		}
+	}
+	return results, nil
+}
+
+// ciMarker returns the marker for what CI last reported for opt, or "" when
+// CI has no result for it.
+func ciMarker(results map[string]Outcome, opt MakeOption) string {
+	target := opt.Target
+	if opt.AliasOf != "" {
+		target = opt.AliasOf
+	}
+	switch results[target] {
+	case OutcomePassed:
+		return "✓ "
+	case OutcomeFailed:
+		return "✗ "
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommands lists the clipboard writers tried on each platform, in
+// order of preference. Each reads the text to copy from stdin.
+var clipboardCommands = map[string][][]string{
+	"darwin":  {{"pbcopy"}},
+	"windows": {{"clip"}},
+}
+
+// unixClipboardCommands covers Wayland and the common X11 helpers.
+var unixClipboardCommands = [][]string{
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+}
+
+// copyToClipboard puts text on the system clipboard using the first helper
+// found on PATH. The text is streamed over stdin, so large logs are not
+// limited by the size of a command line.
+func copyToClipboard(text string) error {
+	candidates, ok := clipboardCommands[runtime.GOOS]
+	if !ok {
+		candidates = unixClipboardCommands
+	}
+	for _, args := range candidates {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+	return errors.New("no clipboard helper found (install wl-copy, xclip or xsel)")
+}
+
+// saveFailure puts the output of a failed run on the clipboard for
+// reporting it, or in a temporary file when there is no clipboard, and
+// says which for the output pane's title.
+func saveFailure(text string) string {
+	if err := copyToClipboard(text); err == nil {
+		return "copied failure output"
+	}
+	file, err := os.CreateTemp("", "coolbox-failure-*.log")
+	if err != nil {
+		return "could not save failure output: " + err.Error()
+	}
+	defer file.Close()
+	if _, err := file.WriteString(text); err != nil {
+		return "could not save failure output: " + err.Error()
+	}
+	return "failure output saved to " + file.Name()
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName is the per-project config file looked up next to the
+// Makefile when -config is not given.
+const configFileName = ".coolbox.yaml"
+
+// Config holds the optional settings read from the YAML config file.
+type Config struct {
+	// Aliases maps short names to real Makefile targets.
+	Aliases map[string]string `yaml:"aliases"`
+	// Sequences maps a name to targets run in order, like "make a && make b".
+	Sequences map[string][]string `yaml:"sequences"`
+	// Workflows maps a name to a pipeline of targets listed in the
+	// Workflows tab, see Workflow.
+	Workflows map[string]Workflow `yaml:"workflows"`
+	// Profiles maps a name to an alternative set of tabs, selected with
+	// -profile.
+	Profiles map[string][]TabRule `yaml:"profiles"`
+	// CategorizeBy groups the targets without a profile, as -categorize-by
+	// does: "name" (the default), "dir" or "section".
+	CategorizeBy string `yaml:"categorizeBy"`
+	// MakeFlags are passed to every make invocation ahead of the target,
+	// unless -no-default-flags is given.
+	MakeFlags []string `yaml:"makeFlags"`
+	// OutputMaxLines caps the lines kept in the TUI output pane; older lines
+	// are dropped. It defaults to defaultOutputMaxLines.
+	OutputMaxLines int `yaml:"outputMaxLines"`
+	// InternalPattern is a regular expression matching target names to
+	// hide by default. It defaults to defaultInternalPattern.
+	InternalPattern string `yaml:"internalPattern"`
+	// Tabs, when set, defines the tabs and their entries explicitly and the
+	// build file is not read at all, turning CoolBox into a general
+	// launcher. Being YAML, the config may also be written as JSON.
+	Tabs []LauncherTab `yaml:"tabs"`
+	// MaxIncludeDepth overrides how deeply Makefile includes may nest,
+	// see makefile.Options.
+	MaxIncludeDepth int `yaml:"maxIncludeDepth"`
+	// TabIcons maps tab names to the icon or emoji shown before them in
+	// the tab bars, in addition to the built-in ones.
+	TabIcons map[string]string `yaml:"tabIcons"`
+	// Accessible turns on the accessible mode of -accessible.
+	Accessible bool `yaml:"accessible"`
+	// CollapseRepeats folds repeated output lines like -collapse-repeats.
+	CollapseRepeats bool `yaml:"collapseRepeats"`
+	// StickyAlwaysMake keeps the TUI's force rebuild (make -B) on across
+	// runs instead of for the next run only.
+	StickyAlwaysMake bool `yaml:"stickyAlwaysMake"`
+	// Preflight checks the Makefile with make before each run like
+	// -preflight.
+	Preflight bool `yaml:"preflight"`
+	// Overview shows the TUI's startup overview like -overview.
+	Overview bool `yaml:"overview"`
+	// CopyOnFailure saves failed runs' output like -copy-on-failure.
+	CopyOnFailure bool `yaml:"copyOnFailure"`
+	// ProblemPresets names the toolchains whose errors and warnings are
+	// collected from the output, as -problems does, and ProblemPatterns
+	// adds regular expressions of its own.
+	ProblemPresets  []string `yaml:"problemPresets"`
+	ProblemPatterns []string `yaml:"problemPatterns"`
+	// Jobs is the number of jobs make runs at once, as with -j.
+	Jobs int `yaml:"jobs"`
+	// ContainerEnv names the variables passed from CoolBox's environment
+	// into the container targets run in with -container.
+	ContainerEnv []string `yaml:"containerEnv"`
+	// Targets holds settings for individual targets, by name.
+	Targets map[string]TargetConfig `yaml:"targets"`
+	// ConfirmRuns shows what a run would execute and asks before starting
+	// it, as -confirm does.
+	ConfirmRuns bool `yaml:"confirmRuns"`
+	// GUI holds the look of the Fyne front-end; -theme and -font-scale
+	// override it.
+	GUI GUIConfig `yaml:"gui"`
+
+	// userOnly names the aliases, sequences and workflows that only the
+	// user config gives, see loadConfigs.
+	userOnly map[string]bool
+}
+
+// GUIConfig is the gui section of the config file.
+type GUIConfig struct {
+	// Theme is "light", "dark", "high-contrast" or "system" (the default).
+	Theme string `yaml:"theme"`
+	// FontScale multiplies the default text and widget sizes; 0 means 1.
+	FontScale float32 `yaml:"fontScale"`
+}
+
+// TargetConfig is the entry of one target in the targets section.
+type TargetConfig struct {
+	// Tail is a log file, relative to the Makefile's directory, streamed
+	// into the output pane once the target has started, for targets that
+	// launch a daemon. Streaming goes on until the run is cancelled.
+	Tail string `yaml:"tail"`
+	// Container is the image to run the target in, overriding -container.
+	Container string `yaml:"container"`
+	// Cwd is the directory the target runs in, relative to the Makefile's
+	// directory unless absolute; make is pointed back at the Makefile with
+	// -f. Other build tools ignore it.
+	Cwd string `yaml:"cwd"`
+	// Spawn launches the target detached in a tmux window or terminal of
+	// its own instead of the output pane, as -spawn does for every target.
+	Spawn bool `yaml:"spawn"`
+}
+
+// LauncherTab is one explicitly configured tab.
+type LauncherTab struct {
+	Name    string          `yaml:"name"`
+	Entries []LauncherEntry `yaml:"entries"`
+}
+
+// LauncherEntry is a button of a LauncherTab that runs an arbitrary
+// command line through the shell.
+type LauncherEntry struct {
+	Label       string `yaml:"label"`
+	Description string `yaml:"description"`
+	Command     string `yaml:"command"`
+	// Cwd is the directory to run in, relative to the Makefile's; it
+	// defaults to that directory.
+	Cwd string `yaml:"cwd"`
+}
+
+// launcherTabs builds the tabs of an explicit launcher config and the
+// entries keyed by label, which stands in for the target name. Labels must
+// be unique and every entry needs a command.
+func launcherTabs(config []LauncherTab) ([]Tab, map[string]LauncherEntry, error) {
+	tabs := make([]Tab, len(config))
+	entries := make(map[string]LauncherEntry)
+	for i, lt := range config {
+		tabs[i].Name = lt.Name
+		for _, entry := range lt.Entries {
+			if entry.Label == "" || entry.Command == "" {
+				return nil, nil, fmt.Errorf("tab %q: entries need a label and a command", lt.Name)
+			}
+			if _, dup := entries[entry.Label]; dup {
+				return nil, nil, fmt.Errorf("tab %q: duplicate entry label %q", lt.Name, entry.Label)
+			}
+			entries[entry.Label] = entry
+			tabs[i].Options = append(tabs[i].Options, MakeOption{Target: entry.Label, Comment: entry.Description, Recipe: entry.Command, IsPhony: true})
+		}
+	}
+	return tabs, entries, nil
+}
+
+// defaultInternalPattern hides targets with a leading underscore.
+const defaultInternalPattern = `^_`
+
+// markInternal sets Internal on options whose name matches pattern (or
+// defaultInternalPattern when empty) or whose comment starts with the word
+// "internal".
+func markInternal(options []MakeOption, pattern string) error {
+	if pattern == "" {
+		pattern = defaultInternalPattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("internalPattern: %w", err)
+	}
+	for i := range options {
+		words := strings.Fields(options[i].Comment)
+		tagged := len(words) > 0 && strings.EqualFold(strings.TrimRight(words[0], ":"), "internal")
+		// Parsers may already have marked a target, e.g. a Taskfile's
+		// "internal: true".
+		options[i].Internal = options[i].Internal || tagged || re.MatchString(options[i].Target)
+	}
+	return nil
+}
+
+// loadConfig reads the config files at paths into one config, later files
+// overriding earlier ones: a setting a file gives replaces the earlier
+// value, except that maps such as aliases merge key by key, the later
+// file's entry winning, and the gui section merges field by field. Lists
+// are replaced whole. Missing files and empty paths are skipped; with none
+// found the config is empty.
+func loadConfig(paths ...string) (*Config, error) {
+	cfg := &Config{}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			diag.debugf("config %s does not exist", path)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Decoding into the same value gives the semantics above.
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		diag.infof("loaded config %s, merged over the files before it", path)
+	}
+	return cfg, nil
+}
+
+// loadConfigs reads the user config at userPath with the project's at
+// projectPath merged over it, see loadConfig, and notes which aliases,
+// sequences and workflows come from the user config alone: those apply to
+// every project, so the ones naming targets a project lacks are left out
+// there rather than failing it, see withoutUnknownUserEntries.
+func loadConfigs(userPath, projectPath string) (*Config, error) {
+	cfg, err := loadConfig(userPath, projectPath)
+	if err != nil {
+		return nil, err
+	}
+	project, err := loadConfig(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	cfg.userOnly = make(map[string]bool)
+	for name := range cfg.Aliases {
+		if _, ok := project.Aliases[name]; !ok {
+			cfg.userOnly[name] = true
+		}
+	}
+	for name := range cfg.Sequences {
+		if _, ok := project.Sequences[name]; !ok {
+			cfg.userOnly[name] = true
+		}
+	}
+	for name := range cfg.Workflows {
+		if _, ok := project.Workflows[name]; !ok {
+			cfg.userOnly[name] = true
+		}
+	}
+	return cfg, nil
+}
+
+// withoutUnknownUserEntries returns cfg without the user-only aliases that
+// point at a target options lacks or collide with one, and the user-only
+// sequences and workflows with a step options lacks. cfg itself is left
+// alone, so a reload that adds the target brings them back.
+func (cfg *Config) withoutUnknownUserEntries(options []MakeOption) *Config {
+	if len(cfg.userOnly) == 0 {
+		return cfg
+	}
+	targets := make(map[string]bool, len(options))
+	for _, opt := range options {
+		targets[opt.Target] = true
+	}
+	pruned := *cfg
+	pruned.Aliases = make(map[string]string, len(cfg.Aliases))
+	for alias, target := range cfg.Aliases {
+		if cfg.userOnly[alias] && (targets[alias] || !targets[target]) {
+			diag.warnf("user config alias %q does not fit this project's targets, left out", alias)
+			continue
+		}
+		pruned.Aliases[alias] = target
+	}
+	known := func(steps []string) bool {
+		for _, step := range steps {
+			if _, ok := pruned.Aliases[step]; !ok && !targets[step] {
+				return false
+			}
+		}
+		return true
+	}
+	pruned.Sequences = make(map[string][]string, len(cfg.Sequences))
+	for name, steps := range cfg.Sequences {
+		if cfg.userOnly[name] && !known(steps) {
+			diag.warnf("user config sequence %q names targets this project lacks, left out", name)
+			continue
+		}
+		pruned.Sequences[name] = steps
+	}
+	pruned.Workflows = make(map[string]Workflow, len(cfg.Workflows))
+	for name, w := range cfg.Workflows {
+		if cfg.userOnly[name] && !known(w.Steps) {
+			diag.warnf("user config workflow %q names targets this project lacks, left out", name)
+			continue
+		}
+		pruned.Workflows[name] = w
+	}
+	return &pruned
+}
+
+// userConfigPath returns the user-level config file, which applies to
+// every project and is overridden by the project's own, or "" when there is
+// no user config directory.
+func userConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "coolbox", "config.yaml")
+}
+
+// defaultConfigPath returns the project config path for makefile: the
+// YAML file, or a JSON one of the same name when only that exists. A
+// Makefile read over HTTP has no project config, so it returns "".
+func defaultConfigPath(makefile string) string {
+	if isRemoteMakefile(makefile) {
+		return ""
+	}
+	path := filepath.Join(filepath.Dir(makefile), configFileName)
+	jsonPath := strings.TrimSuffix(path, ".yaml") + ".json"
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		if _, err := os.Stat(jsonPath); err == nil {
+			return jsonPath
+		}
+	}
+	return path
+}
+
+// applyAliases inserts an entry for every alias directly after the target it
+// points to. Aliases must not shadow a real target and must point at one.
+func applyAliases(options []MakeOption, aliases map[string]string) ([]MakeOption, error) {
+	if len(aliases) == 0 {
+		return options, nil
+	}
+	targets := make(map[string]bool, len(options))
+	for _, opt := range options {
+		targets[opt.Target] = true
+	}
+	byTarget := make(map[string][]string)
+	for alias, target := range aliases {
+		if targets[alias] {
+			return nil, fmt.Errorf("alias %q collides with a Makefile target", alias)
+		}
+		if !targets[target] {
+			return nil, fmt.Errorf("alias %q points to unknown target %q", alias, target)
+		}
+		byTarget[target] = append(byTarget[target], alias)
+	}
+
+	result := make([]MakeOption, 0, len(options)+len(aliases))
+	for _, opt := range options {
+		result = append(result, opt)
+		names := byTarget[opt.Target]
+		sort.Strings(names)
+		for _, alias := range names {
+			entry := opt
+			entry.Target, entry.AliasOf = alias, opt.Target
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// validateSequences checks that sequence names do not shadow targets or
+// aliases in options and that every step names a known target or alias.
+func validateSequences(options []MakeOption, sequences map[string][]string) error {
+	targets := make(map[string]bool, len(options))
+	for _, opt := range options {
+		targets[opt.Target] = true
+	}
+	for name, steps := range sequences {
+		if targets[name] {
+			return fmt.Errorf("sequence %q collides with a target or alias", name)
+		}
+		for _, step := range steps {
+			if !targets[step] {
+				return fmt.Errorf("sequence %q: unknown target %q", name, step)
+			}
+		}
+	}
+	return nil
+}
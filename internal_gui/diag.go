@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logLevel orders the severity of CoolBox's own diagnostics.
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelOff
+)
+
+var levelNames = map[logLevel]string{levelDebug: "debug", levelInfo: "info", levelWarn: "warn"}
+
+// parseLogLevel reads a -log-level value.
+func parseLogLevel(name string) (logLevel, error) {
+	for level, n := range levelNames {
+		if strings.EqualFold(name, n) {
+			return level, nil
+		}
+	}
+	return levelOff, fmt.Errorf("unknown log level %q (want debug, info or warn)", name)
+}
+
+// diagLogger writes CoolBox's own diagnostics, such as the files parsed,
+// the config loaded and runs starting and ending, as leveled lines. They
+// go to their own writer so they never mix with a command's output.
+type diagLogger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level logLevel
+}
+
+// diag is the process-wide diagnostics logger, silent until -log-level
+// sets it up.
+var diag = &diagLogger{level: levelOff}
+
+// setup makes l write messages of level and above to w.
+func (l *diagLogger) setup(w io.Writer, level logLevel) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w, l.level = w, level
+}
+
+func (l *diagLogger) logf(level logLevel, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level < l.level || l.w == nil {
+		return
+	}
+	fmt.Fprintf(l.w, "%s %-5s %s\n", time.Now().Format("15:04:05.000"), levelNames[level], fmt.Sprintf(format, args...))
+}
+
+func (l *diagLogger) debugf(format string, args ...interface{}) { l.logf(levelDebug, format, args...) }
+func (l *diagLogger) infof(format string, args ...interface{})  { l.logf(levelInfo, format, args...) }
+func (l *diagLogger) warnf(format string, args ...interface{})  { l.logf(levelWarn, format, args...) }
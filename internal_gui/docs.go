@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// defaultDocsFile is the sidecar docs file looked up next to the Makefile
+// when -docs is not given.
+const defaultDocsFile = "targets.md"
+
+// loadDocs reads target descriptions from a sidecar file of
+// "target: description" lines. Markdown list markers and backquotes around
+// the target are allowed, so the file reads well when rendered; other
+// lines, such as headings and prose, are ignored.
+func loadDocs(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	docs := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimSpace(strings.TrimLeft(line, "-*"))
+		target, desc, ok := strings.Cut(line, ":")
+		target = strings.Trim(strings.TrimSpace(target), "`*")
+		desc = strings.TrimSpace(desc)
+		if !ok || target == "" || desc == "" || strings.ContainsAny(target, " \t#") {
+			continue
+		}
+		docs[target] = desc
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return docs, nil
+}
+
+// applyDocs fills in the Comment of options that have none from the
+// sidecar docs file at path. A missing file is only an error when required
+// is set, that is when the path was given explicitly.
+func applyDocs(options []MakeOption, path string, required bool) error {
+	docs, err := loadDocs(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for i := range options {
+		if options[i].Comment == "" {
+			options[i].Comment = docs[options[i].Target]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// targetNameRe matches the target names parseMakefile recognises.
+var targetNameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// appendTarget adds a target called name to the Makefile at path, with
+// comment on the line above and recipe as tab-indented lines. The file is
+// replaced atomically so a failed write never leaves it half-written.
+func appendTarget(path, name, comment, recipe string) error {
+	if !targetNameRe.MatchString(name) {
+		return fmt.Errorf("invalid target name %q: use letters, digits, '-' and '_'", name)
+	}
+	options, err := parseMakefile(path)
+	if err != nil {
+		return err
+	}
+	for _, opt := range options {
+		if opt.Target == name {
+			return fmt.Errorf("target %q already exists", name)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	b.Write(data)
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	for _, line := range strings.Split(strings.TrimSpace(comment), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			b.WriteString("# " + line + "\n")
+		}
+	}
+	b.WriteString(name + ":\n")
+	for _, line := range strings.Split(recipe, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			b.WriteString("\t" + line + "\n")
+		}
+	}
+
+	return writeFileAtomic(path, []byte(b.String()))
+}
+
+// writeFileAtomic replaces path with data via a temp file in the same
+// directory and a rename, keeping the original permissions, or creates it
+// readable by all.
+func writeFileAtomic(path string, data []byte) error {
+	perm := fs.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// Explain asks make for its database entry for target, as printed by
+// "make --print-data-base", so users can see the rule, target-specific
+// variables and state make works from. Only make supports this.
+func (r *Runner) Explain(target string) (string, error) {
+	if len(r.Command) > 0 {
+		return "", errors.New("explain needs make's database; the current runner is " + r.Program())
+	}
+	target = r.Resolve(target)
+	// -q decides whether target is up to date without running anything;
+	// its non-zero status just means "needs updating".
+	args := append(r.makeCommand(), r.Flags...)
+	args = append(args, "--print-data-base", "--question", target)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = r.Dir()
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil && out.Len() == 0 {
+		return "", err
+	}
+	entry, ok := databaseEntry(out.String(), target)
+	if !ok {
+		return "", errors.New("make's database has no entry for " + target)
+	}
+	return entry, nil
+}
+
+// databaseEntry extracts target's entry from the "# Files" section of make
+// --print-data-base output. Entries are separated by blank lines and start
+// with the rule line, possibly preceded by target-specific variables; files
+// make merely knows about are marked "# Not a target:". Hash-table
+// statistics are dropped.
+func databaseEntry(db, target string) (string, bool) {
+	start := strings.Index(db, "\n# Files\n")
+	if start < 0 {
+		return "", false
+	}
+	section := db[start:]
+	if end := strings.Index(section, "\n# files hash-table stats:"); end >= 0 {
+		section = section[:end]
+	}
+	for _, block := range strings.Split(section, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		if len(lines) == 0 || lines[0] == "# Not a target:" || !hasRuleLine(lines, target) {
+			continue
+		}
+		var kept []string
+		for _, line := range lines {
+			if line == "# variable set hash-table stats:" || strings.HasPrefix(line, "# Load=") {
+				continue
+			}
+			kept = append(kept, line)
+		}
+		return strings.Join(kept, "\n"), true
+	}
+	return "", false
+}
+
+// hasRuleLine reports whether lines contain a rule for target, that is a
+// line "target:" or "target::" that is not a variable assignment.
+func hasRuleLine(lines []string, target string) bool {
+	for _, line := range lines {
+		rest, ok := strings.CutPrefix(line, target+":")
+		if !ok {
+			continue
+		}
+		rest = strings.TrimPrefix(rest, ":")
+		if !strings.Contains(rest, "=") {
+			return true
+		}
+	}
+	return false
+}
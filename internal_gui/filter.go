@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// stringsFlag is a flag.Value collecting every use of a repeatable flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// targetFilter is the allowlist and denylist given with -only and
+// -exclude, for wrappers that expose a curated subset of targets.
+type targetFilter struct {
+	only, exclude []func(string) bool
+}
+
+// newTargetFilter compiles the -only and -exclude patterns, as globs or,
+// when syntax is "regex", as regular expressions matched anywhere in the
+// name.
+func newTargetFilter(only, exclude []string, syntax string) (*targetFilter, error) {
+	if syntax != "glob" && syntax != "regex" {
+		return nil, fmt.Errorf("-pattern-syntax must be glob or regex, not %q", syntax)
+	}
+	compile := func(patterns []string) ([]func(string) bool, error) {
+		var matchers []func(string) bool
+		for _, pattern := range patterns {
+			if syntax == "regex" {
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					return nil, err
+				}
+				matchers = append(matchers, re.MatchString)
+				continue
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%q: %w", pattern, err)
+			}
+			pattern := pattern
+			matchers = append(matchers, func(name string) bool {
+				ok, _ := path.Match(pattern, name)
+				return ok
+			})
+		}
+		return matchers, nil
+	}
+	f := &targetFilter{}
+	var err error
+	if f.only, err = compile(only); err != nil {
+		return nil, fmt.Errorf("-only: %w", err)
+	}
+	if f.exclude, err = compile(exclude); err != nil {
+		return nil, fmt.Errorf("-exclude: %w", err)
+	}
+	return f, nil
+}
+
+// allows reports whether target matches an -only pattern, when there are
+// any, and no -exclude pattern.
+func (f *targetFilter) allows(target string) bool {
+	matchesAny := func(matchers []func(string) bool) bool {
+		for _, match := range matchers {
+			if match(target) {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(f.only) == 0 || matchesAny(f.only)) && !matchesAny(f.exclude)
+}
+
+// apply returns the options f allows.
+func (f *targetFilter) apply(options []MakeOption) []MakeOption {
+	var kept []MakeOption
+	for _, opt := range options {
+		if f.allows(opt.Target) {
+			kept = append(kept, opt)
+		}
+	}
+	return kept
+}
+
+// prune drops from cfg the aliases of targets f hides and the sequences
+// and workflows with a hidden step, which would otherwise refer to missing
+// targets.
+func (f *targetFilter) prune(cfg *Config) {
+	for name, w := range cfg.Workflows {
+		for _, step := range w.Steps {
+			if target, ok := cfg.Aliases[step]; ok {
+				step = target
+			}
+			if !f.allows(step) {
+				delete(cfg.Workflows, name)
+				break
+			}
+		}
+	}
+	for name, steps := range cfg.Sequences {
+		for _, step := range steps {
+			if target, ok := cfg.Aliases[step]; ok {
+				step = target
+			}
+			if !f.allows(step) {
+				delete(cfg.Sequences, name)
+				break
+			}
+		}
+	}
+	for alias, target := range cfg.Aliases {
+		if !f.allows(target) {
+			delete(cfg.Aliases, alias)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"runtime"
+)
+
+// errNoTerminal is returned by pickFrontend when CoolBox does not run in a
+// terminal, as when output is piped, rather than opening a window.
+var errNoTerminal = errors.New("not running in a terminal; use -run TARGET to run a target without a UI")
+
+// pickFrontend resolves the -frontend choice. Without a terminal on stdin
+// and stdout no front-end is picked: scripts get the -run semantics. In a
+// terminal, "auto" picks the GUI when a display is available, as on a
+// desktop, and else the terminal UI, as in an SSH session. gui is the older
+// -gui flag, which forces the GUI.
+func pickFrontend(requested string, gui, terminal, display bool) (string, error) {
+	switch {
+	case !terminal:
+		return "", errNoTerminal
+	case gui:
+		return "gui", nil
+	case requested != "auto":
+		return requested, nil
+	case display:
+		return "gui", nil
+	}
+	return "tview", nil
+}
+
+// hasDisplay reports whether a graphical session is likely available to
+// open the GUI on. Windows and macOS always have one; elsewhere an X11 or
+// Wayland display must be set.
+func hasDisplay() bool {
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		return true
+	}
+	return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+}
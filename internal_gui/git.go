@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// gitRefreshInterval is how often the front-ends re-read the git status.
+const gitRefreshInterval = 5 * time.Second
+
+// gitSummary describes the git checkout containing dir as "branch (clean)"
+// or "branch (dirty)". It returns "" when dir is not in a git repository or
+// git is not installed.
+func gitSummary(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	branch := strings.TrimSpace(string(out))
+	status, err := exec.Command("git", "-C", dir, "status", "--porcelain").Output()
+	if err != nil {
+		return branch
+	}
+	if strings.TrimSpace(string(status)) != "" {
+		return branch + " (dirty)"
+	}
+	return branch + " (clean)"
+}
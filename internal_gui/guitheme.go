@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+)
+
+// guiTheme is Fyne's default theme with an optional fixed light or dark
+// variant and scaled sizes.
+type guiTheme struct {
+	variant      fyne.ThemeVariant
+	forced       bool // use variant instead of following the system
+	highContrast bool // white and yellow on black, see highContrastColors
+	scale        float32
+}
+
+// highContrastColors replace the default colors in the high-contrast theme.
+var highContrastColors = map[fyne.ThemeColorName]color.Color{
+	theme.ColorNameBackground:      color.Black,
+	theme.ColorNameForeground:      color.White,
+	theme.ColorNameButton:          color.Gray{Y: 0x30},
+	theme.ColorNameInputBackground: color.Black,
+	theme.ColorNameInputBorder:     color.White,
+	theme.ColorNamePrimary:         color.RGBA{R: 0xff, G: 0xd7, A: 0xff},
+	theme.ColorNameFocus:           color.RGBA{R: 0xff, G: 0xd7, A: 0xff},
+	theme.ColorNameSelection:       color.RGBA{R: 0x80, G: 0x6c, A: 0xff},
+	theme.ColorNameSeparator:       color.White,
+	theme.ColorNameDisabled:        color.Gray{Y: 0xb0},
+	theme.ColorNamePlaceHolder:     color.Gray{Y: 0xc0},
+}
+
+// newGUITheme returns the theme for name ("", "system", "light", "dark" or
+// "high-contrast") with sizes multiplied by scale.
+func newGUITheme(name string, scale float32) (fyne.Theme, error) {
+	if scale <= 0 {
+		return nil, fmt.Errorf("font scale must be positive, got %g", scale)
+	}
+	t := guiTheme{scale: scale}
+	switch name {
+	case "", "system":
+	case "light":
+		t.variant, t.forced = theme.VariantLight, true
+	case "dark":
+		t.variant, t.forced = theme.VariantDark, true
+	case "high-contrast":
+		t.variant, t.forced, t.highContrast = theme.VariantDark, true, true
+	default:
+		return nil, fmt.Errorf("unknown theme %q (want light, dark, high-contrast or system)", name)
+	}
+	return t, nil
+}
+
+func (t guiTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) color.Color {
+	if t.forced {
+		variant = t.variant
+	}
+	if c, ok := highContrastColors[name]; ok && t.highContrast {
+		return c
+	}
+	return theme.DefaultTheme().Color(name, variant)
+}
+
+func (t guiTheme) Font(style fyne.TextStyle) fyne.Resource {
+	return theme.DefaultTheme().Font(style)
+}
+
+func (t guiTheme) Icon(name fyne.ThemeIconName) fyne.Resource {
+	return theme.DefaultTheme().Icon(name)
+}
+
+func (t guiTheme) Size(name fyne.ThemeSizeName) float32 {
+	return theme.DefaultTheme().Size(name) * t.scale
+}
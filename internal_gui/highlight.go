@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// ANSI colors used by highlightWriter.
+const (
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+	ansiReset  = "\x1b[0m"
+)
+
+// highlightWriter colors common structured output line by line: JSON
+// documents on one line are pretty-printed and colored, diff lines are
+// colored from a diff header to the first line that cannot be part of the
+// diff, and go test's PASS, FAIL and ok
+// lines stand out. Lines that already carry colors pass through untouched.
+// Like dirBannerWriter, only a partial line that may still turn out to be
+// highlighted is held back.
+type highlightWriter struct {
+	w       io.Writer
+	inDiff  bool // within a diff, so +/- lines are diff lines
+	pending []byte
+}
+
+func (h *highlightWriter) Write(p []byte) (int, error) {
+	h.pending = append(h.pending, p...)
+	var out []byte
+	for {
+		i := bytes.IndexByte(h.pending, '\n')
+		if i < 0 {
+			break
+		}
+		out = append(out, h.highlight(string(h.pending[:i]))...)
+		out = append(out, '\n')
+		h.pending = h.pending[i+1:]
+	}
+	if !mayHighlight(h.pending) {
+		out = append(out, h.pending...)
+		h.pending = nil
+	}
+	if len(out) > 0 {
+		if _, err := h.w.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// flush writes the partial line held back, if any, uncolored and ends any
+// diff, as the run has ended.
+func (h *highlightWriter) flush() {
+	if len(h.pending) > 0 {
+		h.w.Write(h.pending)
+		h.pending = nil
+	}
+	h.inDiff = false
+}
+
+// diffLinePrefixes start the lines that can be part of a unified diff
+// besides its headers.
+var diffLinePrefixes = []string{"+", "-", " ", "@@", "\\", "diff ", "index ", "new file", "deleted file", "old mode", "new mode", "similarity ", "rename ", "Binary files "}
+
+// isDiffLine reports whether line can be part of a diff.
+func isDiffLine(line string) bool {
+	for _, prefix := range diffLinePrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightPrefixes start the lines highlightWriter may color.
+var highlightPrefixes = []string{"{", "[", "+", "-", "@@", "diff ", "FAIL", "PASS", "ok "}
+
+// mayHighlight reports whether the partial line b could still become a
+// highlighted line.
+func mayHighlight(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	// Prompts such as "[sudo] password:" are not JSON arrays.
+	if len(b) > 1 && b[0] == '[' && (b[1] >= 'a' && b[1] <= 'z' || b[1] >= 'A' && b[1] <= 'Z') && !strings.ContainsRune("tfn", rune(b[1])) {
+		return false
+	}
+	for _, prefix := range highlightPrefixes {
+		n := len(b)
+		if n > len(prefix) {
+			n = len(prefix)
+		}
+		if string(b[:n]) == prefix[:n] {
+			return true
+		}
+	}
+	return false
+}
+
+// highlight returns line, without its newline, colored.
+func (h *highlightWriter) highlight(line string) string {
+	if h.inDiff && !isDiffLine(line) {
+		h.inDiff = false
+	}
+	if strings.Contains(line, "\x1b") {
+		return line
+	}
+	switch {
+	case strings.HasPrefix(line, "diff "), strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "):
+		h.inDiff = true
+		return "\x1b[1m" + line + ansiReset
+	case strings.HasPrefix(line, "@@"):
+		h.inDiff = true
+		return ansiCyan + line + ansiReset
+	case h.inDiff && strings.HasPrefix(line, "+"):
+		return ansiGreen + line + ansiReset
+	case h.inDiff && strings.HasPrefix(line, "-"):
+		return ansiRed + line + ansiReset
+	case line == "FAIL" || strings.HasPrefix(line, "FAIL\t") || strings.HasPrefix(line, "--- FAIL"):
+		return ansiRed + line + ansiReset
+	case line == "PASS" || strings.HasPrefix(line, "ok  \t") || strings.HasPrefix(line, "--- PASS"):
+		return ansiGreen + line + ansiReset
+	}
+	if trimmed := strings.TrimSpace(line); (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		var indented bytes.Buffer
+		if json.Indent(&indented, []byte(trimmed), "", "  ") == nil {
+			return colorJSON(indented.String())
+		}
+	}
+	return line
+}
+
+// colorJSON colors the keys, strings and literals of the valid JSON text s.
+func colorJSON(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end++
+			color := ansiGreen
+			if rest := strings.TrimLeft(s[end:], " \n"); strings.HasPrefix(rest, ":") {
+				color = ansiCyan
+			}
+			b.WriteString(color + s[i:end] + ansiReset)
+			i = end
+		case c == '-' || c >= '0' && c <= '9' || c == 't' || c == 'f' || c == 'n':
+			end := i + 1
+			for end < len(s) && !strings.ContainsRune(",]} \n", rune(s[end])) {
+				end++
+			}
+			b.WriteString(ansiYellow + s[i:end] + ansiReset)
+			i = end
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// defaultTabIcons prefix the built-in tabs; a config's tabIcons add to and
+// override them.
+var defaultTabIcons = map[string]string{
+	"All":           "☰",
+	"Favorites":     "★",
+	"Apps":          "🚀",
+	"Services":      "⚙",
+	"Library":       "📦",
+	"Demo":          "🎬",
+	"Unit Tests":    "🧪",
+	undocumentedTab: "❔",
+}
+
+// tabIcons returns the icon for each tab name, or nil when mode turns icons
+// off. mode is "on", "off" or "auto", which shows them unless the terminal
+// is unlikely to render wide glyphs; terminal is false for the GUI.
+func tabIcons(mode string, config map[string]string, terminal bool) map[string]string {
+	if mode == "off" || (mode == "auto" && terminal && !terminalHasGlyphs()) {
+		return nil
+	}
+	icons := make(map[string]string, len(defaultTabIcons)+len(config))
+	for name, icon := range defaultTabIcons {
+		icons[name] = icon
+	}
+	for name, icon := range config {
+		icons[name] = icon
+	}
+	return icons
+}
+
+// terminalHasGlyphs guesses from the environment whether the terminal can
+// show emoji: it needs a UTF-8 locale, and the Linux console and the old
+// hardware terminals lack the fonts.
+func terminalHasGlyphs() bool {
+	term := os.Getenv("TERM")
+	if term == "linux" || term == "dumb" || strings.HasPrefix(term, "vt") {
+		return false
+	}
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			value = strings.ToLower(value)
+			return strings.Contains(value, "utf-8") || strings.Contains(value, "utf8")
+		}
+	}
+	return false
+}
+
+// tabLabel is a tab name as shown in tab bars, with its icon if
+// there is one.
+func tabLabel(name string, icons map[string]string) string {
+	if icon := icons[name]; icon != "" {
+		return icon + " " + name
+	}
+	return name
+}
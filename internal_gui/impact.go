@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Dependents is the reverse of the prerequisite graph of the parsed
+// options: for each target, the targets naming it as a prerequisite.
+type Dependents map[string][]string
+
+// reverseDeps builds the Dependents of the targets in tabs. A target listed
+// on several tabs is counted once, and aliases, which have no rules of their
+// own, are left out.
+func reverseDeps(tabs []Tab) Dependents {
+	seen := make(map[string]bool)
+	deps := make(Dependents)
+	for _, t := range tabs {
+		for _, opt := range t.Options {
+			if opt.AliasOf != "" || seen[opt.Target] {
+				continue
+			}
+			seen[opt.Target] = true
+			for _, dep := range opt.Deps {
+				if dep != opt.Target {
+					deps[dep] = append(deps[dep], opt.Target)
+				}
+			}
+		}
+	}
+	return deps
+}
+
+// Impact returns, sorted, every target that depends on target directly or
+// through other prerequisites: the targets a change to it may rebuild.
+func (d Dependents) Impact(target string) []string {
+	seen := map[string]bool{target: true}
+	var impact []string
+	queue := []string{target}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, dependent := range d[next] {
+			if !seen[dependent] {
+				seen[dependent] = true
+				impact = append(impact, dependent)
+				queue = append(queue, dependent)
+			}
+		}
+	}
+	sort.Strings(impact)
+	return impact
+}
+
+// impactOf returns the Impact of opt, following an alias to its target.
+func (d Dependents) impactOf(opt MakeOption) []string {
+	if opt.AliasOf != "" {
+		return d.Impact(opt.AliasOf)
+	}
+	return d.Impact(opt.Target)
+}
+
+// maxImpactNames caps the dependents describeImpact names.
+const maxImpactNames = 10
+
+// describeImpact summarizes the Impact of opt for the info views.
+func (d Dependents) describeImpact(opt MakeOption) string {
+	impact := d.impactOf(opt)
+	switch len(impact) {
+	case 0:
+		return "Needed by: nothing (leaf target)"
+	case 1:
+		return "Needed by: 1 target (" + impact[0] + ")"
+	}
+	names := impact
+	if len(names) > maxImpactNames {
+		names = append(names[:maxImpactNames:maxImpactNames], "…")
+	}
+	return fmt.Sprintf("Needed by: %d targets (%s)", len(impact), strings.Join(names, ", "))
+}
@@ -4,22 +4,72 @@ import (
 	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
-	"regexp"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
 	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
 	"github.com/rivo/tview"
+
+	"internal_gui/makefile"
 )
 
 type MakeOption struct {
 	Target  string
 	Comment string
+	// DoubleColon is set for targets defined with "::" rules. Make allows
+	// several such rules for one target; they are listed once.
+	DoubleColon bool
+	// AliasOf is the real target when this entry is a config alias.
+	AliasOf string
+	// Recipe holds the target's recipe lines without their leading tab.
+	Recipe string
+	// Deps lists the prerequisites named on the target's rule lines.
+	Deps []string
+	// Internal marks implementation-detail targets, hidden from the lists
+	// by default; see markInternal.
+	Internal bool
+	// Category is the tab named by an "@category Name" comment above the
+	// target. It overrides the categorization rules.
+	Category string
+	// Section is the title of the "##@" header the target follows in its
+	// file, used by -categorize-by section.
+	Section string
+	// Args pre-fill the TUI's prompt for running the target with arguments,
+	// as an "@args" comment above it suggests.
+	Args string
+	// Requires names the variables the target needs set, from an
+	// "@requires" comment above it, see Runner.MissingVars.
+	Requires []string
+	// Confirm marks targets whose runs are always confirmed first, as an
+	// "@confirm" comment above them asks, whatever uiOptions.Confirm says.
+	Confirm bool
+	// IsDefault marks the target the build tool runs without arguments:
+	// make's default goal, the first just recipe or Taskfile's "default".
+	IsDefault bool
+	// IsPhony marks action targets rather than files: prerequisites of
+	// .PHONY in a Makefile, and every recipe of the other build tools.
+	IsPhony bool
+	// Source is the file the target is defined in when that is not the
+	// Makefile itself: an included file or a fragment.
+	Source string
+	// Line and EndLine span the target's first rule in its file, from the
+	// rule line to the end of its recipe. They are zero for build tools
+	// other than make.
+	Line, EndLine int
 }
 
 type Tab struct {
@@ -28,151 +78,2334 @@ type Tab struct {
 }
 
 func parseMakefile(path string) ([]MakeOption, error) {
-	file, err := os.Open(path)
+	options, _, err := parseMakefileSources(path, makefile.Options{})
+	return options, err
+}
+
+// parseMakefileSources is parseMakefile with opts that also returns the
+// paths the result depends on, see makefile.ParseSources. Source is only
+// set for targets from other files than path itself.
+func parseMakefileSources(path string, opts makefile.Options) ([]MakeOption, []string, error) {
+	targets, sources, err := opts.ParseSources(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return targetOptions(targets, path), sources, nil
+}
+
+// parseBuildFile is tool.Parse that also returns the paths the result
+// depends on. Makefiles are read with opts.
+func parseBuildFile(tool BuildTool, path string, opts makefile.Options) ([]MakeOption, []string, error) {
+	if tool.Name == "make" {
+		return parseMakefileSources(path, opts)
+	}
+	options, err := tool.Parse(path)
+	return options, []string{path}, err
+}
+
+// targetOptions converts the targets parsed from the Makefile at path.
+func targetOptions(targets []makefile.Target, path string) []MakeOption {
+	options := make([]MakeOption, len(targets))
+	for i, t := range targets {
+		options[i] = MakeOption{
+			Target:      t.Name,
+			Comment:     t.Comment,
+			DoubleColon: t.DoubleColon,
+			Recipe:      t.Recipe,
+			Deps:        t.Deps,
+			Category:    t.Category,
+			Section:     t.Section,
+			Args:        t.Args,
+			Requires:    t.Requires,
+			Confirm:     t.Confirm,
+			IsPhony:     t.IsPhony,
+			IsDefault:   t.IsDefault,
+			Line:        t.Line,
+			EndLine:     t.EndLine,
+		}
+		if t.File != path {
+			options[i].Source = t.File
+		}
+	}
+	return options
+}
+
+// dependsOn reports whether one of opt's prerequisites contains query,
+// ignoring case.
+func dependsOn(opt MakeOption, query string) bool {
+	query = strings.ToLower(query)
+	for _, dep := range opt.Deps {
+		if strings.Contains(strings.ToLower(dep), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// mustConfirm reports whether target is marked Confirm in any of tabs.
+func mustConfirm(tabs []Tab, target string) bool {
+	for _, t := range tabs {
+		for _, opt := range t.Options {
+			if opt.Target == target && opt.Confirm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// confirmedSteps returns the steps, in order, that are marked Confirm in
+// tabs. A run of several targets is confirmed as a whole when any of them
+// is.
+func confirmedSteps(tabs []Tab, steps []string) []string {
+	var marked []string
+	for _, step := range steps {
+		if mustConfirm(tabs, step) {
+			marked = append(marked, step)
+		}
+	}
+	return marked
+}
+
+// countTargets returns how many distinct targets tabs list, leaving out
+// internal ones, which are hidden by default.
+func countTargets(tabs []Tab) int {
+	seen := make(map[string]bool)
+	for _, t := range tabs {
+		for _, opt := range t.Options {
+			if !opt.Internal {
+				seen[opt.Target] = true
+			}
+		}
+	}
+	return len(seen)
+}
+
+// envLabel describes the -env environment for the headers, with the file
+// it selected, which is the plain Makefile when there is none for env.
+func envLabel(env, makefile string) string {
+	if env == "" {
+		return ""
+	}
+	return "env: " + env + " (" + filepath.Base(makefile) + ")"
+}
+
+// targetNames returns the set of targets tabs list.
+func targetNames(tabs []Tab) map[string]bool {
+	names := make(map[string]bool)
+	for _, t := range tabs {
+		for _, opt := range t.Options {
+			names[opt.Target] = true
+		}
+	}
+	return names
+}
+
+// targetDiff summarizes the targets added and removed between two loads of
+// the tabs, as "+2 targets (deploy-canary, rollback), -1 (old-build)", or
+// returns "" when the same targets are listed.
+func targetDiff(before, after []Tab) string {
+	old, cur := targetNames(before), targetNames(after)
+	var added, removed []string
+	for name := range cur {
+		if !old[name] {
+			added = append(added, name)
+		}
+	}
+	for name := range old {
+		if !cur[name] {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	var parts []string
+	for _, change := range []struct {
+		sign  string
+		names []string
+	}{{"+", added}, {"-", removed}} {
+		if len(change.names) == 0 {
+			continue
+		}
+		noun := ""
+		if len(parts) == 0 {
+			noun = " target"
+			if len(change.names) > 1 {
+				noun += "s"
+			}
+		}
+		parts = append(parts, fmt.Sprintf("%s%d%s (%s)", change.sign, len(change.names), noun, strings.Join(change.names, ", ")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// defaultGoal returns the option marked IsDefault in any of tabs.
+func defaultGoal(tabs []Tab) (MakeOption, bool) {
+	for _, t := range tabs {
+		for _, opt := range t.Options {
+			if opt.IsDefault {
+				return opt, true
+			}
+		}
+	}
+	return MakeOption{}, false
+}
+
+// nextByLetter returns the index of the first option after from whose
+// target starts with letter, ignoring case and wrapping around, or -1 when
+// none does. Repeated calls with the previous result cycle the matches.
+func nextByLetter(options []MakeOption, from int, letter rune) int {
+	letter = unicode.ToLower(letter)
+	for n := 1; n <= len(options); n++ {
+		i := (from + n) % len(options)
+		if first, _ := utf8.DecodeRuneInString(options[i].Target); unicode.ToLower(first) == letter {
+			return i
+		}
+	}
+	return -1
+}
+
+// targetTokens splits a target name into the words separated by '-', '_'
+// and '.', so "build-test-fixtures" yields build, test and fixtures.
+func targetTokens(name string) []string {
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.'
+	})
+}
+
+// hasToken reports whether tokens contains one of words, also accepting
+// the plural "words" form.
+func hasToken(tokens []string, words ...string) bool {
+	for _, tok := range tokens {
+		for _, w := range words {
+			if tok == w || tok == w+"s" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// helperVerbs lead targets that prepare something rather than run it, so
+// "build-test-fixtures" is a build step and not a test.
+var helperVerbs = []string{"build", "gen", "generate", "install", "deploy", "clean"}
+
+// otherTab is the tab of the built-in categorization for targets that are
+// about the Makefile itself, such as help.
+const otherTab = "Other"
+
+// isHelpTarget reports whether name is the conventional target of
+// self-documenting Makefiles that prints their targets.
+func isHelpTarget(name string) bool {
+	return name == "help"
+}
+
+// Categorize Makefile targets into tabs
+func categorizeOptions(options []MakeOption) []Tab {
+	var apps, services, libs, demos, tests, other []MakeOption
+	for _, opt := range options {
+		if opt.Category != "" {
+			continue
+		}
+		name := opt.Target
+		if opt.AliasOf != "" {
+			name = opt.AliasOf
+		}
+		tokens := targetTokens(name)
+		// The first matching tab wins, so "test-app" is an app and not a
+		// test. Words are matched whole: "build-apparmor" is no app.
+		if isHelpTarget(name) {
+			diag.debugf("categorized %s as %s: the Makefile's help target", opt.Target, otherTab)
+			other = append(other, opt)
+		} else if hasToken(tokens, "app") || hasToken(tokens[:1], "run") {
+			diag.debugf("categorized %s as Apps: app in its name", opt.Target)
+			apps = append(apps, opt)
+		} else if hasToken(tokens, "service") {
+			diag.debugf("categorized %s as Services: service in its name", opt.Target)
+			services = append(services, opt)
+		} else if hasToken(tokens, "lib", "libraries") {
+			diag.debugf("categorized %s as Library: lib in its name", opt.Target)
+			libs = append(libs, opt)
+		} else if hasToken(tokens, "demo") {
+			diag.debugf("categorized %s as Demo: demo in its name", opt.Target)
+			demos = append(demos, opt)
+		} else if hasToken(tokens, "test") && !hasToken(tokens[:1], helperVerbs...) {
+			diag.debugf("categorized %s as Unit Tests: test in its name", opt.Target)
+			tests = append(tests, opt)
+		}
+	}
+	tabs := []Tab{
+		{Name: "Apps", Options: apps},
+		{Name: "Services", Options: services},
+		{Name: "Library", Options: libs},
+		{Name: "Demo", Options: demos},
+		{Name: "Unit Tests", Options: tests},
+	}
+	// Most Makefiles have nothing else for it, so the tab only exists
+	// when there is a help target.
+	if len(other) > 0 {
+		tabs = append(tabs, Tab{Name: otherTab, Options: other})
+	}
+	return addCategorized(tabs, options)
+}
+
+// addCategorized puts the options carrying an @category annotation into the
+// tab of that name, ignoring case, adding tabs after the existing ones as
+// needed.
+func addCategorized(tabs []Tab, options []MakeOption) []Tab {
+	for _, opt := range options {
+		if opt.Category == "" {
+			continue
+		}
+		diag.debugf("categorized %s as %s: @category annotation", opt.Target, opt.Category)
+		i, err := findTab(tabs, opt.Category)
+		if err != nil {
+			i = len(tabs)
+			tabs = append(tabs, Tab{Name: opt.Category})
+		}
+		tabs[i].Options = append(tabs[i].Options, opt)
+	}
+	return tabs
+}
+
+// undocumentedTab is the name of the tab listing targets without a comment.
+const undocumentedTab = "Undocumented"
+
+// undocumented returns the options that have no description. Internal
+// targets are left out since they are not meant to be run directly.
+func undocumented(options []MakeOption) []MakeOption {
+	var missing []MakeOption
+	for _, opt := range options {
+		if opt.Comment == "" && !opt.Internal {
+			missing = append(missing, opt)
+		}
+	}
+	return missing
+}
+
+// pinnedTabs stay in place when tabs are reordered by sortTabs.
+var pinnedTabs = map[string]bool{"All": true, "Favorites": true, undocumentedTab: true}
+
+// sortTabs reorders tabs in place according to mode: "" keeps the order
+// they were built in and "count" puts the most populated tabs first. Ties
+// and pinned tabs keep their original positions.
+func sortTabs(tabs []Tab, mode string) error {
+	switch mode {
+	case "":
+		return nil
+	case "count":
+	default:
+		return fmt.Errorf("unknown tab sort %q (want count)", mode)
+	}
+	var slots []int
+	var movable []Tab
+	for i, t := range tabs {
+		if !pinnedTabs[t.Name] {
+			slots = append(slots, i)
+			movable = append(movable, t)
+		}
+	}
+	sort.SliceStable(movable, func(i, j int) bool {
+		return len(movable[i].Options) > len(movable[j].Options)
+	})
+	for k, i := range slots {
+		tabs[i] = movable[k]
+	}
+	return nil
+}
+
+// tabItems remembers the highlighted item of each tab, by tab name, so
+// returning to a tab restores it.
+type tabItems map[string]int
+
+// restore returns the item to highlight on returning to tab, which now
+// lists count items: the remembered one, or the last if the list shrank.
+func (t tabItems) restore(tab string, count int) int {
+	if idx := t[tab]; idx < count {
+		return idx
+	}
+	return count - 1
+}
+
+// minCommentWidth is the fewest comment characters worth showing in a
+// truncated label; below this only the target name is displayed.
+const minCommentWidth = 8
+
+// tabBarWindow returns the range [start, end) of the tab labels, widths
+// cells wide, that fit in width cells together with current, filling to
+// the right first. Room is kept for the arrows marking hidden tabs. A width
+// of 0 fits them all.
+func tabBarWindow(widths []int, current, width int) (int, int) {
+	if width <= 0 || len(widths) == 0 {
+		return 0, len(widths)
+	}
+	cells := func(i int) int { return widths[i] + 1 }
+	fits := func(start, end int) bool {
+		used := 0
+		for i := start; i < end; i++ {
+			used += cells(i)
+		}
+		if start > 0 {
+			used += 2
+		}
+		if end < len(widths) {
+			used++
+		}
+		return used <= width
+	}
+	start, end := current, current+1
+	for end < len(widths) && fits(start, end+1) {
+		end++
+	}
+	for start > 0 && fits(start-1, end) {
+		start--
+	}
+	return start, end
+}
+
+// formatLabel renders opt as a single-line list label no wider than width
+// columns, counting wide runes such as CJK or emoji as two. Comments that do
+// not fit are cut at a word boundary and end in an ellipsis; when there is
+// no useful room left only the target is shown and the comment stays
+// available through the info modal. A width of zero or less disables
+// truncation.
+func formatLabel(opt MakeOption, width int) string {
+	name := opt.Target
+	if opt.AliasOf != "" {
+		name += " → " + opt.AliasOf
+	}
+	if opt.Comment == "" {
+		return name
+	}
+	full := name + " - " + opt.Comment
+	if width <= 0 || runewidth.StringWidth(full) <= width {
+		return full
+	}
+	room := width - runewidth.StringWidth(name+" - ") - 1 // keep a column for the ellipsis
+	if room < minCommentWidth {
+		return name
+	}
+	var comment string
+	for _, word := range strings.Fields(opt.Comment) {
+		next := word
+		if comment != "" {
+			next = comment + " " + word
+		}
+		if runewidth.StringWidth(next) > room {
+			break
+		}
+		comment = next
+	}
+	if comment == "" {
+		// First word alone is too long, so cut it mid-word.
+		comment = runewidth.Truncate(opt.Comment, room, "")
+	}
+	return name + " - " + comment + "…"
+}
+
+// findTab returns the index of the tab called name, ignoring case.
+func findTab(tabs []Tab, name string) (int, error) {
+	names := make([]string, len(tabs))
+	for i, t := range tabs {
+		if strings.EqualFold(t.Name, name) {
+			return i, nil
+		}
+		names[i] = t.Name
+	}
+	return 0, fmt.Errorf("no tab named %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// uiOptions carries the start-up settings shared by the front-ends.
+type uiOptions struct {
+	// StartTab is the index of the tab shown first.
+	StartTab int
+	// AllowEdit enables actions that modify the Makefile on disk.
+	AllowEdit bool
+	// AllowShell enables the action that runs arbitrary shell commands.
+	AllowShell bool
+	// HideDirBanners drops make's "Entering directory" lines from the
+	// output pane instead of dimming them.
+	HideDirBanners bool
+	// Env is the -env environment, shown in the header.
+	Env string
+	// Report is the -report file, the default for the TUI's w key.
+	Report string
+	// Accessible selects high-contrast colors and adds a text or symbol
+	// cue wherever color conveys a state.
+	Accessible bool
+	// Highlight colors JSON, diffs and go test results in the output pane.
+	Highlight bool
+	// CollapseRepeats folds runs of identical lines in the output pane, see
+	// repeatWriter.
+	CollapseRepeats bool
+	// StickyRebuild keeps the runner's AlwaysMake on once the F key
+	// turns it on; otherwise it only applies to the next run.
+	StickyRebuild bool
+	// CopyOnFailure copies the output pane to the clipboard whenever a run
+	// fails, see saveFailure.
+	CopyOnFailure bool
+	// Problems, when set, picks the errors and warnings out of the output
+	// for the TUI's Problems list.
+	Problems problemMatcher
+	// OutputMaxLines caps the lines kept in the output pane.
+	OutputMaxLines int
+	// TabIcons maps tab names to the icons shown before them; nil shows
+	// names only.
+	TabIcons map[string]string
+	// CIResults holds what a CI run last reported per target, shown as
+	// markers; nil when no results file was given.
+	CIResults map[string]Outcome
+	// Notice, when set, is shown on startup, such as when the build file
+	// has no targets.
+	Notice string
+	// Overview opens the TUI on a summary of the targets, see overviewText,
+	// until a key is pressed.
+	Overview bool
+	// AutoRun holds the -autorun target, or the steps of the sequence it
+	// names, which the TUI starts in the output pane as it opens. Asking
+	// for it on the command line is the confirmation, unless a step is
+	// marked Confirm.
+	AutoRun []string
+	// Confirm previews every run started from the UI, see Runner.Preview,
+	// and starts it only once accepted.
+	Confirm bool
+	// CanSwitch lets the TUI and GUI hand over to each other, see handover.
+	// GUIStarted is set once the GUI has run: Fyne cannot start twice in a
+	// process.
+	CanSwitch  bool
+	GUIStarted bool
+	// EnterDefault makes Enter run the default goal when no target is
+	// highlighted, as when a filter leaves the list empty.
+	EnterDefault bool
+	// Log, when set, receives the full output of every run from the
+	// output pane, however much the pane keeps. LogFile is its name.
+	Log     io.Writer
+	LogFile string
+	// Profile is the categorization profile in use; "" is the built-in one.
+	Profile string
+	// Profiles lists the selectable profiles, see profileNames.
+	Profiles []string
+	// Reload re-reads the Makefile and rebuilds the tabs using profile.
+	Reload func(profile string) ([]Tab, error)
+	// Theme is the Fyne GUI's theme, see newGUITheme.
+	Theme fyne.Theme
+	// Runner is the name of the build tool in use.
+	Runner string
+	// Runners names the build tools detected in the project directory.
+	Runners []string
+	// SwitchRunner points the runner at another build tool and rebuilds the
+	// tabs from its build file using profile.
+	SwitchRunner func(name, profile string) ([]Tab, error)
+}
+
+// loadTabs reads the build file at path with parse, applies the aliases and
+// sequences from cfg, less the user config's that do not fit, and
+// categorizes the result with the named profile, or without one by the
+// given -categorize-by mode. An Undocumented tab is always added last.
+func loadTabs(parse func(string) ([]MakeOption, error), path string, cfg *Config, profile, mode string) ([]Tab, error) {
+	options, err := parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filepath.Base(path), err)
+	}
+	if err := markInternal(options, cfg.InternalPattern); err != nil {
+		return nil, err
+	}
+	cfg = cfg.withoutUnknownUserEntries(options)
+	options, err = applyAliases(options, cfg.Aliases)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	if err := validateSequences(options, cfg.Sequences); err != nil {
+		return nil, err
+	}
+	if err := validateWorkflows(options, cfg); err != nil {
+		return nil, err
+	}
+	var tabs []Tab
+	if rules, ok := cfg.Profiles[profile]; ok {
+		tabs = categorizeWithRules(options, rules)
+	} else {
+		root := filepath.Dir(path)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			// A fragment directory is the root itself.
+			root = path
+		}
+		if tabs, err = categorize(options, mode, root); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.Workflows) > 0 {
+		tabs = append(tabs, workflowTab(cfg.Workflows, options))
+	}
+	return append(tabs, Tab{Name: undocumentedTab, Options: undocumented(options)}), nil
+}
+
+func main() {
+	fileFlag := flag.String("f", "", "Makefile to read, a directory of *.mk fragments, or an http(s) URL to fetch one from for inspection (default: ../Makefile)")
+	remoteDirFlag := flag.String("remote-dir", "", "With an http(s) -f, the local checkout to run the Makefile's targets in")
+	guiFlag := flag.Bool("gui", false, "Launch graphical UI instead of terminal UI")
+	frontendFlag := flag.String("frontend", "auto", "Front-end to use: tview, bubbletea, gui, or auto to pick the GUI when a display is available and else the terminal UI")
+	tabFlag := flag.String("tab", "", "Name of the tab to open first (case-insensitive)")
+	notifyFlag := flag.Bool("notify", false, "Send a desktop notification when a long-running target finishes")
+	notifyAfterFlag := flag.Duration("notify-after", 10*time.Second, "Minimum run time that triggers a -notify notification")
+	configFlag := flag.String("config", "", "Path to the project config file, merged over the user's coolbox/config.yaml (default: "+configFileName+" next to the Makefile)")
+	runFlag := flag.String("run", "", "Run the named target, alias or sequence without a UI and exit with its status")
+	autorunFlag := flag.String("autorun", "", "Open the terminal UI running the named target, alias or sequence in the output pane")
+	keepGoingFlag := flag.Bool("keep-going", false, "Keep running a sequence after a target fails")
+	parallelFlag := flag.Bool("parallel", false, "Run the steps of a sequence at the same time")
+	tagOutputFlag := flag.String("tag-output", "auto", "Prefix output lines with \"[target]\": \"auto\" for sequences, \"always\" or \"never\"")
+	profileFlag := flag.String("profile", "", "Name of the categorization profile from the config to use")
+	markdownFlag := flag.Bool("markdown", false, "Print the categorized targets as a Markdown document and exit")
+	allowEditFlag := flag.Bool("allow-edit", false, "Enable actions that modify the Makefile, such as creating targets")
+	allowShellFlag := flag.Bool("allow-shell", false, "Enable the action that runs arbitrary shell commands in the Makefile directory")
+	hideDirsFlag := flag.Bool("hide-dirs", false, "Hide make's \"Entering/Leaving directory\" lines in the output pane instead of dimming them")
+	jobsFlag := flag.Int("j", 0, "Number of jobs make runs at once, passed as -jN (default from the config's jobs; the TUI's J key changes it)")
+	envFlag := flag.String("env", "", "Read Makefile.NAME for environment NAME instead of the Makefile, when it exists")
+	reportFlag := flag.String("report", "", "On exit, write an HTML report of the session's runs and their output to this file")
+	containerFlag := flag.String("container", "", "Run targets with docker in this image, with the Makefile's directory mounted at "+containerWorkdir)
+	highlightFlag := flag.Bool("highlight", false, "Color JSON, diffs and go test results in the output pane")
+	problemsFlag := flag.String("problems", "", "Collect errors and warnings from the output, by comma-separated presets: "+presetNames())
+	alwaysMakeFlag := flag.Bool("always-make", false, "Pass -B to make on every run so targets are rebuilt whatever their timestamps (the TUI's F key toggles it)")
+	preflightFlag := flag.Bool("preflight", false, "Have make read the Makefile before each run and report its syntax errors instead of running")
+	spawnFlag := flag.Bool("spawn", false, "Launch targets detached in a new tmux window or terminal, or else in the background with a log file, instead of in the output pane")
+	overviewFlag := flag.Bool("overview", false, "Open the TUI on an overview of the targets, their categories and documentation, which any key dismisses")
+	copyOnFailureFlag := flag.Bool("copy-on-failure", false, "Copy the output pane to the clipboard, or else a temporary file, whenever a run fails")
+	collapseFlag := flag.Bool("collapse-repeats", false, "Fold runs of identical output lines into one \"⟲ line (×N)\" line in the output pane")
+	noDefaultFlagsFlag := flag.Bool("no-default-flags", false, "Do not pass the config's makeFlags to make")
+	checkFlag := flag.Bool("check", false, "Validate the Makefile and config, list undocumented targets and exit")
+	maxUndocumentedFlag := flag.Int("max-undocumented", -1, "With -check, fail when more targets than this lack a description (-1 disables)")
+	runnerFlag := flag.String("runner", "", "Build tool to list and run targets with: make, just, task or npm (default: ask when several are found)")
+	noCacheFlag := flag.Bool("no-cache", false, "Always re-parse the build file instead of using the parse cache")
+	replayFlag := flag.Int("replay", 0, "Re-run the last N targets run from the UIs, in order and stopping at the first failure, and exit")
+	benchFlag := flag.String("bench", "", "Run these comma-separated targets, or \"phony\" for every phony target, one after another, print their run times slowest first and exit")
+	benchCSVFlag := flag.String("bench-csv", "", "With -bench, also write the run times to this CSV file")
+	summaryFlag := flag.Bool("summary", false, "With -run, print a final \"coolbox: TARGET exited N in T\" line to stderr")
+	themeFlag := flag.String("theme", "", "GUI theme: light, dark, high-contrast or system (default from config, else system)")
+	accessibleFlag := flag.Bool("accessible", false, "Use high-contrast colors and show states such as the active tab and run outcomes as text, not only by color")
+	fontScaleFlag := flag.Float64("font-scale", 0, "GUI text and widget size multiplier (default from config, else 1)")
+	ptyFlag := flag.Bool("pty", false, "Run commands in the output pane on a pseudo-terminal so they keep colors and progress output")
+	echoFlag := flag.Bool("echo", true, "Print each command, and its directory when different, at the top of its output in the output pane")
+	iconsFlag := flag.String("icons", "auto", "Show icons before tab names: \"on\", \"off\" or \"auto\" to skip them on terminals without emoji")
+	confirmFlag := flag.Bool("confirm", false, "Show the resolved command, arguments and directory and ask before each run from a UI")
+	enterDefaultFlag := flag.Bool("enter-default", false, "In the TUI, run the default goal when Enter is pressed with no target highlighted")
+	docsFlag := flag.String("docs", "", "Read missing target descriptions from this \"target: description\" file (default "+defaultDocsFile+" next to the Makefile)")
+	logFlag := flag.String("log", "", "Append the full output of every run in the output pane to this file")
+	ciResultsFlag := flag.String("ci-results", "", "Mark targets with the status a CI run reported, from a JSON file mapping targets to \"passed\" or \"failed\"")
+	stdinFileFlag := flag.String("stdin-file", "", "With -run, read the target's standard input from this file")
+	recentFlag := flag.Bool("recent", false, "Pick the project from the recently used directories")
+	pickFlag := flag.Bool("pick", false, "Pick the project by fuzzy search over the recent directories and those with a build file under -projects-root")
+	projectsRootFlag := flag.String("projects-root", "", "Directory searched for projects by -pick, remembered for later runs")
+	var onlyFlag, excludeFlag stringsFlag
+	flag.Var(&onlyFlag, "only", "List and run only targets matching this pattern (repeatable)")
+	flag.Var(&excludeFlag, "exclude", "Hide and refuse to run targets matching this pattern (repeatable)")
+	patternSyntaxFlag := flag.String("pattern-syntax", "glob", "Syntax of -only and -exclude patterns: \"glob\" or \"regex\"")
+	categorizeByFlag := flag.String("categorize-by", "", "Group targets into tabs by \"name\", their source \"dir\" relative to the Makefile's, or \"section\" header (##@ Title); default from the config, else name")
+	sortTabsFlag := flag.String("sort-tabs", "", "Order tabs by \"count\" of targets instead of the fixed order")
+	logLevelFlag := flag.String("log-level", "", "Log CoolBox's own diagnostics at this level: debug, info or warn")
+	logFileFlag := flag.String("log-file", "", "Append -log-level diagnostics to this file instead of stderr, which the terminal UIs draw over")
+	flag.Parse()
+
+	if *logLevelFlag != "" {
+		level, err := parseLogLevel(*logLevelFlag)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		var w io.Writer = os.Stderr
+		if *logFileFlag != "" {
+			file, err := os.OpenFile(*logFileFlag, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			defer file.Close()
+			w = file
+		}
+		diag.setup(w, level)
+	}
+
+	state := loadState()
+	makefilePath := "../Makefile"
+	_, statErr := os.Stat(makefilePath)
+	noBuildFile := statErr != nil && len(detectBuildTools(filepath.Dir(makefilePath))) == 0
+	if *projectsRootFlag != "" {
+		root, err := filepath.Abs(*projectsRootFlag)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		state.ProjectsRoot = root
+		state.save()
+	}
+	if *fileFlag != "" {
+		makefilePath = *fileFlag
+	} else if *pickFlag || (noBuildFile && *runFlag == "" && state.ProjectsRoot != "" && isTerminal(os.Stdin)) {
+		// The projects root makes CoolBox a launcher for the workspace,
+		// when there is a terminal to pick on.
+		var found []string
+		if state.ProjectsRoot != "" {
+			found = findProjects(state.ProjectsRoot)
+		}
+		choices, missing := projectChoices(state.Recent, found)
+		if missing > 0 {
+			fmt.Printf("(%d recent directories without a build file left out)\n", missing)
+		}
+		if len(choices) == 0 {
+			fmt.Println("Error: no projects to pick from; give -projects-root or use CoolBox in a project first")
+			os.Exit(1)
+		}
+		if !isTerminal(os.Stdin) {
+			fmt.Println("Error: -pick needs a terminal")
+			os.Exit(1)
+		}
+		dir, ok := pickProject(choices, os.Stdin, os.Stdout)
+		if !ok {
+			os.Exit(1)
+		}
+		makefilePath = filepath.Join(dir, "Makefile")
+	} else if *recentFlag || (noBuildFile && *runFlag == "") {
+		// Without a build file, offer the projects used before.
+		switch {
+		case len(state.Recent) == 0:
+			if *recentFlag {
+				fmt.Println("Error: no recent projects to pick from")
+				os.Exit(1)
+			}
+		case !isTerminal(os.Stdin):
+			if *recentFlag {
+				fmt.Println("Error: -recent needs a terminal to pick the project on")
+				os.Exit(1)
+			}
+		default:
+			i := promptChoice("Recent projects:", "Project", state.Recent, os.Stdin, os.Stdout)
+			makefilePath = filepath.Join(state.Recent[i], "Makefile")
+		}
+	}
+	configPath := *configFlag
+	if configPath == "" {
+		configPath = defaultConfigPath(makefilePath)
+	}
+	cfg, err := loadConfigs(userConfigPath(), configPath)
+	if err != nil {
+		fmt.Println("Error reading config:", err)
+		os.Exit(1)
+	}
+	filter, err := newTargetFilter(onlyFlag, excludeFlag, *patternSyntaxFlag)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	filter.prune(cfg)
+
+	profile := *profileFlag
+	if profile == "" && checkProfile(cfg, state.Profile) == nil {
+		profile = state.Profile
+	}
+	if err := checkProfile(cfg, profile); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	if profile != state.Profile {
+		state.setProfile(profile)
+		state.save()
+	}
+
+	if err := sortTabs(nil, *sortTabsFlag); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	categorizeBy := *categorizeByFlag
+	if categorizeBy == "" {
+		categorizeBy = cfg.CategorizeBy
+	}
+	if _, err := categorize(nil, categorizeBy, ""); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	switch *tagOutputFlag {
+	case "auto", "always", "never":
+	default:
+		fmt.Printf("Error: -tag-output must be auto, always or never, not %q\n", *tagOutputFlag)
+		os.Exit(1)
+	}
+	runner := &Runner{
+		Aliases:      cfg.Aliases,
+		Workflows:    cfg.Workflows,
+		Targets:      cfg.Targets,
+		Container:    *containerFlag,
+		Jobs:         *jobsFlag,
+		ContainerEnv: cfg.ContainerEnv,
+		KeepGoing:    *keepGoingFlag,
+		Parallel:     *parallelFlag,
+		TagOutput:    *tagOutputFlag,
+		Echo:         *echoFlag,
+		PTY:          *ptyFlag,
+		Spawn:        *spawnFlag,
+		Preflight:    *preflightFlag || cfg.Preflight,
+		AlwaysMake:   *alwaysMakeFlag,
+		ParseOptions: makefile.Options{MaxIncludeDepth: cfg.MaxIncludeDepth, Logf: diag.debugf},
+		Outcomes:     &Outcomes{},
+	}
+	// Runs and their output are only kept for a report; the TUI, which
+	// writes one on demand, keeps them too.
+	if *reportFlag != "" {
+		runner.History = &History{}
+	}
+	if *notifyFlag {
+		runner.NotifyAfter = *notifyAfterFlag
+	}
+	if runner.Jobs == 0 {
+		runner.Jobs = cfg.Jobs
+	}
+	if runner.Jobs < 0 {
+		fmt.Println("Error: the job count must not be negative")
+		os.Exit(1)
+	}
+	// useTool points runner at tool's build file next to the Makefile. The
+	// config's makeFlags only apply to make.
+	projectDir := filepath.Dir(makefilePath)
+	remote := isRemoteMakefile(makefilePath)
+	if remote {
+		// The build files around the current directory are not the
+		// fetched Makefile's, so only make and -remote-dir apply.
+		projectDir = *remoteDirFlag
+		if *runnerFlag != "" && *runnerFlag != "make" {
+			fmt.Println("Error: a Makefile fetched over HTTP can only be read with make")
+			os.Exit(1)
+		}
+	} else if *remoteDirFlag != "" {
+		fmt.Println("Error: -remote-dir needs an http(s) URL for -f")
+		os.Exit(1)
+	}
+	var detected []BuildTool
+	if !remote {
+		detected = detectBuildTools(projectDir)
+	}
+	tool, err := findBuildTool("make")
+	if *runnerFlag != "" {
+		tool, err = findBuildTool(*runnerFlag)
+	} else if len(detected) == 1 {
+		tool = detected[0]
+	} else if len(detected) > 1 && len(cfg.Tabs) == 0 {
+		// Reuse the choice made last time in this directory; ask when
+		// there is none and a terminal to ask on.
+		remembered, lookupErr := findBuildTool(state.Runners[stateKey(projectDir)])
+		switch {
+		case lookupErr == nil && remembered.buildFile(projectDir) != "":
+			tool = remembered
+		case isTerminal(os.Stdin) && *runFlag == "":
+			tool = promptBuildTool(detected, os.Stdin, os.Stdout)
+			state.rememberRunner(projectDir, tool.Name)
+		default:
+			// Not remembered: nobody chose it.
+			tool = detected[0]
+		}
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	useTool := func(next BuildTool) error {
+		path := next.buildFile(projectDir)
+		if next.Name == "make" && *fileFlag != "" {
+			path = *fileFlag
+		}
+		if next.Name == "make" && *envFlag != "" && path != "" {
+			path, _ = envMakefile(path, *envFlag)
+		}
+		if path == "" {
+			return fmt.Errorf("no %s build file (%s) in %s", next.Name, strings.Join(next.Files, ", "), projectDir)
+		}
+		tool = next
+		runner.Makefile = path
+		runner.LocalDir = *remoteDirFlag
+		runner.Command = tool.Command
+		runner.Flags = nil
+		if tool.Name == "make" && !*noDefaultFlagsFlag {
+			runner.Flags = cfg.MakeFlags
+		}
+		return nil
+	}
+	if len(cfg.Tabs) > 0 {
+		var err error
+		if _, runner.Launch, err = launcherTabs(cfg.Tabs); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		runner.Makefile = makefilePath
+		detected = nil
+	} else if err := useTool(tool); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	parse := func(path string) (options []MakeOption, err error) {
+		cacheFile, cacheErr := parseCachePath(tool, path)
+		if isRemoteMakefile(path) {
+			// Fetched afresh each time, so a reload picks up changes.
+			options, err = parseRemoteMakefile(path, runner.ParseOptions)
+		} else if *noCacheFlag || cacheErr != nil {
+			options, _, err = parseBuildFile(tool, path, runner.ParseOptions)
+		} else {
+			options, err = parseCached(tool, path, cacheFile, runner.ParseOptions)
+		}
+		if err == nil {
+			state.pruneRunCounts(path, options)
+			docs := *docsFlag
+			if docs == "" && !(remote && projectDir == "") {
+				docs = filepath.Join(projectDir, defaultDocsFile)
+			}
+			if docs != "" {
+				err = applyDocs(options, docs, *docsFlag != "")
+			}
+		}
+		return filter.apply(options), err
+	}
+	load := func(profile string) ([]Tab, error) {
+		if len(cfg.Tabs) > 0 {
+			tabs, _, err := launcherTabs(cfg.Tabs)
+			return tabs, err
+		}
+		tabs, err := loadTabs(parse, runner.Makefile, cfg, profile, categorizeBy)
+		if err != nil {
+			return nil, err
+		}
+		runner.SetRequires(requiredVars(tabs))
+		return tabs, sortTabs(tabs, *sortTabsFlag)
+	}
+	tabs, err := load(profile)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	var notice string
+	if countTargets(tabs) == 0 {
+		notice = "No targets found in " + runner.Makefile
+	}
+
+	if *checkFlag {
+		var missing []MakeOption
+		if i, err := findTab(tabs, undocumentedTab); err == nil {
+			missing = tabs[i].Options
+		}
+		for _, opt := range missing {
+			fmt.Println("undocumented:", opt.Target)
+		}
+		fmt.Printf("%d undocumented target(s)\n", len(missing))
+		if *maxUndocumentedFlag >= 0 && len(missing) > *maxUndocumentedFlag {
+			fmt.Printf("Error: more than %d undocumented target(s)\n", *maxUndocumentedFlag)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *markdownFlag {
+		if err := writeMarkdown(os.Stdout, tabs); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	accessible := *accessibleFlag || cfg.Accessible
+	presets := cfg.ProblemPresets
+	if *problemsFlag != "" {
+		presets = strings.Split(*problemsFlag, ",")
+	}
+	var problems problemMatcher
+	if len(presets) > 0 || len(cfg.ProblemPatterns) > 0 {
+		if problems, err = newProblemMatcher(presets, cfg.ProblemPatterns); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+	}
+	themeName, fontScale := cfg.GUI.Theme, cfg.GUI.FontScale
+	if *themeFlag != "" {
+		themeName = *themeFlag
+	} else if accessible {
+		themeName = "high-contrast"
+	}
+	if *fontScaleFlag != 0 {
+		fontScale = float32(*fontScaleFlag)
+	}
+	if fontScale == 0 {
+		fontScale = 1
+	}
+	guiTheme, err := newGUITheme(themeName, fontScale)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	outputMaxLines := cfg.OutputMaxLines
+	if outputMaxLines <= 0 {
+		outputMaxLines = defaultOutputMaxLines
+	}
+
+	var ciResults map[string]Outcome
+	if *ciResultsFlag != "" {
+		ciResults, err = loadCIResults(*ciResultsFlag)
+		if err != nil {
+			fmt.Println("Error reading CI results:", err)
+			os.Exit(1)
+		}
+	}
+
+	var runners []string
+	for _, t := range detected {
+		runners = append(runners, t.Name)
+	}
+	// -autorun starts its target in the TUI, so auto keeps to the terminal.
+	frontend, frontendErr := pickFrontend(*frontendFlag, *guiFlag, isTerminal(os.Stdin) && isTerminal(os.Stdout), hasDisplay() && *autorunFlag == "")
+	opts := uiOptions{
+		// Creating targets writes Makefile syntax.
+		AllowEdit:       *allowEditFlag && tool.Name == "make" && len(cfg.Tabs) == 0,
+		AllowShell:      *allowShellFlag,
+		HideDirBanners:  *hideDirsFlag,
+		Highlight:       *highlightFlag,
+		CollapseRepeats: *collapseFlag || cfg.CollapseRepeats,
+		CopyOnFailure:   *copyOnFailureFlag || cfg.CopyOnFailure,
+		StickyRebuild:   *alwaysMakeFlag || cfg.StickyAlwaysMake,
+		Problems:        problems,
+		Accessible:      accessible,
+		Report:          *reportFlag,
+		Env:             *envFlag,
+		OutputMaxLines:  outputMaxLines,
+		EnterDefault:    *enterDefaultFlag,
+		Confirm:         *confirmFlag || cfg.ConfirmRuns,
+		Notice:          notice,
+		Overview:        *overviewFlag || cfg.Overview,
+		CIResults:       ciResults,
+		TabIcons:        tabIcons(*iconsFlag, cfg.TabIcons, frontend != "gui"),
+		LogFile:         *logFlag,
+		Profile:         profile,
+		Profiles:        profileNames(cfg),
+		Reload:          load,
+		Theme:           guiTheme,
+		Runner:          tool.Name,
+		Runners:         runners,
+		SwitchRunner: func(name, profile string) ([]Tab, error) {
+			next, err := findBuildTool(name)
+			if err != nil {
+				return nil, err
+			}
+			prev := tool
+			if err := useTool(next); err != nil {
+				return nil, err
+			}
+			tabs, err := load(profile)
+			if err != nil {
+				useTool(prev)
+				return nil, err
+			}
+			state.rememberRunner(projectDir, tool.Name)
+			return tabs, nil
+		},
+	}
+	if *tabFlag != "" {
+		opts.StartTab, err = findTab(tabs, *tabFlag)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+	}
+
+	if *replayFlag > 0 {
+		steps := state.lastRuns(runner.Makefile, *replayFlag)
+		if len(steps) == 0 {
+			fmt.Fprintf(os.Stderr, "coolbox: no runs of %s to replay\n", runner.Makefile)
+			os.Exit(1)
+		}
+		_, err := runner.Replay(steps, terminalStdio)
+		writeSessionReport(*reportFlag, runner)
+		os.Exit(exitCode(err))
+	}
+	if *benchFlag != "" {
+		targets, err := benchTargets(*benchFlag, tabs, runner.Workflows)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "coolbox:", err)
+			os.Exit(1)
+		}
+		if len(targets) == 0 {
+			fmt.Fprintln(os.Stderr, "coolbox: no targets to benchmark")
+			os.Exit(1)
+		}
+		for _, target := range targets {
+			if !filter.allows(runner.Resolve(target)) {
+				fmt.Fprintf(os.Stderr, "coolbox: %s is excluded by -only or -exclude\n", target)
+				os.Exit(1)
+			}
+		}
+		results := runner.Bench(targets, terminalStdio)
+		if *benchCSVFlag != "" {
+			if err := writeBenchFile(*benchCSVFlag, results); err != nil {
+				fmt.Fprintln(os.Stderr, "coolbox:", err)
+				os.Exit(1)
+			}
+		}
+		writeSessionReport(*reportFlag, runner)
+		if benchFailed(results) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if *stdinFileFlag != "" && *runFlag == "" {
+		fmt.Println("Error: -stdin-file needs -run")
+		os.Exit(1)
+	}
+	if *runFlag != "" {
+		if notice != "" {
+			fmt.Fprintln(os.Stderr, "coolbox:", notice)
+			os.Exit(1)
+		}
+		_, isWorkflow := cfg.Workflows[*runFlag]
+		if _, ok := cfg.Sequences[*runFlag]; !ok && !isWorkflow && !filter.allows(runner.Resolve(*runFlag)) {
+			fmt.Fprintf(os.Stderr, "coolbox: %s is excluded by -only or -exclude\n", *runFlag)
+			os.Exit(1)
+		}
+		stdio := terminalStdio
+		if *stdinFileFlag != "" {
+			wd, _ := os.Getwd()
+			file, err := openStdinFile(*stdinFileFlag, wd)
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			stdio.Stdin = file
+		}
+		start := time.Now()
+		var err error
+		if steps, ok := cfg.Sequences[*runFlag]; ok {
+			_, err = runner.RunSequence(steps, stdio)
+		} else {
+			err = runner.Run(*runFlag, stdio)
+		}
+		if *summaryFlag {
+			fmt.Fprintln(os.Stderr, runSummary(*runFlag, err, time.Since(start)))
+		}
+		writeSessionReport(*reportFlag, runner)
+		os.Exit(exitCode(err))
+	}
+
+	if *autorunFlag != "" {
+		if notice != "" {
+			fmt.Fprintln(os.Stderr, "coolbox:", notice)
+			os.Exit(1)
+		}
+		if frontend != "tview" && frontendErr == nil {
+			fmt.Println("Error: -autorun needs the tview front-end")
+			os.Exit(1)
+		}
+		if steps, ok := cfg.Sequences[*autorunFlag]; ok {
+			opts.AutoRun = steps
+		} else if _, ok := cfg.Workflows[*autorunFlag]; ok || filter.allows(runner.Resolve(*autorunFlag)) {
+			opts.AutoRun = []string{*autorunFlag}
+		} else {
+			fmt.Fprintf(os.Stderr, "coolbox: %s is excluded by -only or -exclude\n", *autorunFlag)
+			os.Exit(1)
+		}
+	}
+
+	// Usage is only counted for runs started from a front-end.
+	runner.AfterRun = func(target string, err error) { state.countRun(runner.Makefile, target) }
+
+	if frontendErr != nil {
+		fmt.Fprintln(os.Stderr, "coolbox:", frontendErr)
+		os.Exit(1)
+	}
+	if *logFlag != "" {
+		// Opened once for every front-end the session switches to.
+		logFile, err := os.OpenFile(*logFlag, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "coolbox: opening the -log file:", err)
+			os.Exit(1)
+		}
+		defer logFile.Close()
+		opts.Log = logFile
+	}
+	// Only sessions in a front-end count as using the project: scripted
+	// runs such as -run and -check have exited by now.
+	if len(detected) > 0 || len(cfg.Tabs) > 0 {
+		state.addRecent(projectDir)
+	}
+	// The TUI and GUI can hand over to each other, sharing the runner and
+	// state, as long as there is a terminal to come back to.
+	opts.CanSwitch = isTerminal(os.Stdin)
+	frontends := map[string]func([]Tab, uiOptions) *handover{
+		"gui": func(tabs []Tab, opts uiOptions) *handover { return runGUI(tabs, runner, state, opts) },
+		"bubbletea": func(tabs []Tab, opts uiOptions) *handover {
+			runBubbleTea(tabs, runner, opts)
+			return nil
+		},
+		"tview": func(tabs []Tab, opts uiOptions) *handover { return runTUI(tabs, runner, state, opts) },
+	}
+	if err := runFrontends(frontend, tabs, opts, frontends); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+	writeSessionReport(*reportFlag, runner)
+}
+
+// runFrontends runs the named front-end, then each one the last hands over
+// to, with the tabs as it left them. Launch-only options are dropped after
+// the first.
+func runFrontends(name string, tabs []Tab, opts uiOptions, frontends map[string]func([]Tab, uiOptions) *handover) error {
+	for name != "" {
+		run, ok := frontends[name]
+		if !ok {
+			return fmt.Errorf("unknown front-end %s", name)
+		}
+		next := run(tabs, opts)
+		if name == "gui" {
+			opts.GUIStarted = true
+		}
+		if next == nil {
+			return nil
+		}
+		name, tabs, opts.StartTab = next.Frontend, next.Tabs, next.Tab
+		opts.Notice, opts.AutoRun, opts.Overview = "", nil, false
+	}
+	return nil
+}
+
+// writeSessionReport writes the -report file, if one was asked for, on
+// exit.
+func writeSessionReport(path string, runner *Runner) {
+	if path == "" {
+		return
+	}
+	if err := writeReportFile(path, runner.Dir(), runner.History); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing report:", err)
+	}
+}
+
+// handover is returned by a front-end that was closed for another one to
+// take over, with the tabs as last loaded and the one showing.
+type handover struct {
+	Frontend string
+	Tabs     []Tab
+	Tab      int
+}
+
+// highContrastStyles is the TUI's theme in accessible mode: white and
+// yellow on black.
+var highContrastStyles = tview.Theme{
+	PrimitiveBackgroundColor:    tcell.ColorBlack,
+	ContrastBackgroundColor:     tcell.ColorYellow,
+	MoreContrastBackgroundColor: tcell.ColorWhite,
+	BorderColor:                 tcell.ColorWhite,
+	TitleColor:                  tcell.ColorWhite,
+	GraphicsColor:               tcell.ColorWhite,
+	PrimaryTextColor:            tcell.ColorWhite,
+	SecondaryTextColor:          tcell.ColorYellow,
+	TertiaryTextColor:           tcell.ColorAqua,
+	InverseTextColor:            tcell.ColorBlack,
+	ContrastSecondaryTextColor:  tcell.ColorBlack,
+}
+
+func runTUI(tabs []Tab, runner *Runner, state *State, ui uiOptions) *handover {
+	if ui.Accessible {
+		tview.Styles = highContrastStyles
+	}
+	if runner.History == nil {
+		runner.History = &History{} // for the report the w key writes
+	}
+	app := tview.NewApplication()
+	var next *handover // set when the GUI is to take over
+	tabBar := tview.NewTextView().SetDynamicColors(true)
+	list := tview.NewList()
+	descModal := tview.NewModal().SetText("").AddButtons([]string{"Close"})
+	outputView := tview.NewTextView().SetDynamicColors(true).SetScrollable(true)
+	outputView.SetBorder(true).SetTitle("Output").SetTitleAlign(tview.AlignLeft)
+	outputView.SetChangedFunc(func() { app.Draw() })
+	outputView.SetMaxLines(ui.OutputMaxLines)
+	runner.TermSize = func() (int, int) {
+		_, _, width, height := outputView.GetInnerRect()
+		return width, height
+	}
+	keepScrollOnResize(outputView, runner.Resize)
+	// limit watches the pane's line count to announce truncation; the log
+	// file, if any, gets everything.
+	// problemLog collects the errors and warnings of a run into
+	// problemsList, where picking one scrolls the pane to it.
+	var problemLog *problemWriter
+	var problems []Problem
+	// sourceView shows a target's rule as written, left aligned and
+	// scrollable, which the centered descModal would not keep.
+	sourceView := tview.NewTextView().SetScrollable(true).SetWrap(false)
+	sourceView.SetBorder(true).SetTitleAlign(tview.AlignLeft)
+	problemsList := tview.NewList().ShowSecondaryText(false)
+	problemsList.SetBorder(true).SetTitle("Problems (Enter: show in output, Esc: back)").SetTitleAlign(tview.AlignLeft)
+	if ui.Problems != nil {
+		outputView.SetRegions(true)
+		problemLog = &problemWriter{matcher: ui.Problems, onProblem: func(p Problem) {
+			app.QueueUpdateDraw(func() {
+				problems = append(problems, p)
+				label := "[red]error[-] "
+				if p.Warning {
+					label = "[yellow]warning[-] "
+				}
+				problemsList.AddItem(label+tview.Escape(p.Text), "", 0, nil)
+			})
+		}}
+	}
+	pane := newPaneWriter(outputView, ui.HideDirBanners, ui.Highlight, problemLog)
+	limit := &lineLimitWriter{w: pane, max: ui.OutputMaxLines}
+	limit.onExceed = func() {
+		notice := fmt.Sprintf("Output (truncated to the last %d lines", ui.OutputMaxLines)
+		if ui.LogFile != "" {
+			notice += ", see " + tview.Escape(ui.LogFile)
+		}
+		app.QueueUpdateDraw(func() { outputView.SetTitle(notice + ")") })
+	}
+	output := io.Writer(limit)
+	// repeats, when collapsing, shows the count of a line repeating in the
+	// pane's title until the marker line is written.
+	var repeats *repeatWriter
+	if ui.CollapseRepeats {
+		repeats = &repeatWriter{w: limit, onRepeat: func(count int) {
+			title := "Output"
+			if count > 0 {
+				title = fmt.Sprintf("Output (⟲ last line ×%d)", count)
+			}
+			app.QueueUpdateDraw(func() { outputView.SetTitle(title) })
+		}}
+		output = repeats
+	}
+	// flushOutput writes out the partial line the writers hold back, so
+	// it is neither lost nor run into what comes next.
+	flushOutput := func() {
+		repeats.flush()
+		pane.flush()
+	}
+	// paneNote writes a line of CoolBox's own after a run's output.
+	paneNote := func(format string, args ...interface{}) {
+		flushOutput()
+		fmt.Fprintf(outputView, format, args...)
+	}
+	// copyFailure saves the pane's output, at most ui.OutputMaxLines, after
+	// a failed run for -copy-on-failure and says so in the pane's title.
+	copyFailure := func() {
+		text := outputView.GetText(true)
+		go func() {
+			status := "Output (" + tview.Escape(saveFailure(text)) + ")"
+			app.QueueUpdateDraw(func() { outputView.SetTitle(status) })
+		}()
+	}
+	if ui.Log != nil {
+		output = io.MultiWriter(ui.Log, output)
+	}
+
+	currentTab := ui.StartTab
+	currentProfile := ui.Profile
+	labelWidth := 0  // inner width of the list, tracked from the screen size
+	tabBarWidth := 0 // inner width of the tab bar when last drawn
+	// updateTabBar shows as many tabs as fit around the current one, with
+	// arrows where more are scrolled out of view.
+	updateTabBar := func() {
+		labels := make([]string, len(tabs))
+		widths := make([]int, len(tabs))
+		for i, t := range tabs {
+			labels[i] = tview.Escape(tabLabel(t.Name, ui.TabIcons))
+			if i == currentTab && ui.Accessible {
+				labels[i] = tview.Escape("[" + tabLabel(t.Name, ui.TabIcons) + "]")
+			}
+			widths[i] = tview.TaggedStringWidth(labels[i])
+		}
+		start, end := tabBarWindow(widths, currentTab, tabBarWidth)
+		var bar string
+		if start > 0 {
+			bar = "‹ "
+		}
+		for i := start; i < end; i++ {
+			if i == currentTab && ui.Accessible {
+				bar += "[black:yellow:b]" + labels[i] + "[-:-:-] "
+			} else if i == currentTab {
+				bar += "[yellow]" + labels[i] + "[white] "
+			} else {
+				bar += labels[i] + " "
+			}
+		}
+		if end < len(tabs) {
+			bar += "›"
+		}
+		tabBar.SetText(bar)
+	}
+
+	// dependents is the reverse prerequisite graph of tabs; while
+	// showImpact is set the list shows how many targets depend on each one.
+	dependents := reverseDeps(tabs)
+	showImpact := false
+
+	// queue holds targets picked with Space, in order, for a sequence run.
+	var queue []string
+	queuePos := func(target string) int {
+		for i, t := range queue {
+			if t == target {
+				return i
+			}
+		}
+		return -1
+	}
+	itemLabel := func(opt MakeOption) string {
+		var marker string
+		if pos := queuePos(opt.Target); pos >= 0 {
+			marker = fmt.Sprintf("(%d) ", pos+1)
+		}
+		var count string
+		if n := state.runCount(runner.Makefile, opt); n > 0 {
+			count = fmt.Sprintf(" ×%d", n)
+		}
+		if showImpact {
+			if n := len(dependents.impactOf(opt)); n > 0 {
+				count += fmt.Sprintf(" ↑%d", n)
+			} else {
+				count += " leaf"
+			}
+		}
+		ci := ciMarker(ui.CIResults, opt)
+		outcome := runner.Outcomes.Get(opt)
+		if ui.Accessible {
+			marker += outcomeText(outcome)
+		}
+		label := tview.Escape(marker+formatLabel(opt, labelWidth-runewidth.StringWidth(marker+ci+count))) + "[::d]" + count + "[::-]"
+		switch {
+		case outcome == OutcomePassed && ui.Accessible:
+			label = "[aqua::b]" + label + "[-::-]"
+		case outcome == OutcomeFailed && ui.Accessible:
+			label = "[yellow::b]" + label + "[-::-]"
+		case outcome == OutcomePassed:
+			label = "[green]" + label + "[-]"
+		case outcome == OutcomeFailed:
+			label = "[red]" + label + "[-]"
+		}
+		// The CI marker keeps its own color next to the local outcome.
+		switch ci {
+		case "✓ ":
+			label = "[green]" + ci + "[-]" + label
+		case "✗ ":
+			label = "[red]" + ci + "[-]" + label
+		}
+		return label
+	}
+
+	// changed is the set of files git reports as modified; the list is
+	// narrowed to targets referencing them while onlyChanged is set.
+	var changed []string
+	onlyChanged := false
+	showInternal := false // list targets marked Internal
+	byUsage := false      // order the list by run count
+	onlyPhony := false    // list only action targets, see MakeOption.IsPhony
+	depQuery := ""        // when set, list only targets with a matching prerequisite
+
+	// shown holds the options currently listed, matching the list items.
+	var shown []MakeOption
+	var runTarget func(target string)
+	var setListTitle func()
+	updateList := func() {
+		list.Clear()
+		shown = nil
+		for _, opt := range tabs[currentTab].Options {
+			if opt.Internal && !showInternal {
+				continue
+			}
+			if onlyChanged && !referencesChanged(opt, changed) {
+				continue
+			}
+			if depQuery != "" && !dependsOn(opt, depQuery) {
+				continue
+			}
+			if onlyPhony && !opt.IsPhony {
+				continue
+			}
+			shown = append(shown, opt)
+		}
+		if byUsage {
+			sort.SliceStable(shown, func(i, j int) bool {
+				return state.runCount(runner.Makefile, shown[i]) > state.runCount(runner.Makefile, shown[j])
+			})
+		}
+		for _, opt := range shown {
+			target := opt.Target
+			list.AddItem(itemLabel(opt), "", 0, func() {
+				runTarget(target)
+			})
+		}
+	}
+
+	relabel := func() {
+		for i, opt := range shown {
+			list.SetItemText(i, itemLabel(opt), "")
+		}
+	}
+
+	lastItem := make(tabItems)
+	switchTab := func(i int) {
+		lastItem[tabs[currentTab].Name] = list.GetCurrentItem()
+		currentTab = i
+		updateTabBar()
+		updateList()
+		if n := list.GetItemCount(); n > 0 {
+			list.SetCurrentItem(lastItem.restore(tabs[currentTab].Name, n))
+		}
+	}
+
+	updateTabBar()
+	updateList()
+
+	gitView := tview.NewTextView().SetTextAlign(tview.AlignRight)
+	setGit := func(summary string) {
+		if summary != "" {
+			summary = "git: " + summary
+		}
+		if env := envLabel(ui.Env, runner.Makefile); env != "" {
+			summary = strings.TrimSpace(env + "  " + summary)
+		}
+		gitView.SetText(tview.Escape(summary))
+	}
+	setGit(gitSummary(runner.Dir()))
+	// The refresh stops with the TUI, which may hand over to the GUI.
+	stopGit := make(chan struct{})
+	defer close(stopGit)
+	go func() {
+		ticker := time.NewTicker(gitRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopGit:
+				return
+			case <-ticker.C:
+			}
+			summary := gitSummary(runner.Dir())
+			app.QueueUpdateDraw(func() { setGit(summary) })
+		}
+	}()
+
+	header := tview.NewFlex().
+		AddItem(tabBar, 0, 1, false).
+		AddItem(gitView, 0, 1, false)
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(header, 1, 0, false).
+		AddItem(list, 0, 1, true)
+
+	// The output pane takes state.OutputSplit percent of the height while
+	// open. It opens for every run and stays open across idle periods only
+	// when pinned.
+	outputOpen := state.OutputPinned
+	layoutOutput := func() {
+		flex.RemoveItem(outputView)
+		if outputOpen && state.OutputSplit > 0 {
+			flex.ResizeItem(list, 0, 100-state.OutputSplit)
+			flex.AddItem(outputView, 0, state.OutputSplit, false)
+		}
+	}
+	resizeOutput := func(delta int) {
+		split := state.OutputSplit + delta
+		if split < 0 || split > 90 {
+			return
+		}
+		state.setOutputSplit(split)
+		outputOpen = split > 0
+		if !outputOpen {
+			state.setOutputPinned(false)
+		}
+		state.save()
+		layoutOutput()
+	}
+	// stdinField forwards typed lines to the running command, for recipes
+	// that prompt. It is shown below the output pane during a run.
+	var stdinWriter io.Writer
+	stdinField := tview.NewInputField().SetLabel("stdin> ")
+	stdinField.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter && stdinWriter != nil {
+			text := stdinField.GetText()
+			fmt.Fprintln(stdinWriter, text)
+			// A pseudo-terminal echoes the line itself.
+			if !runner.PTY {
+				fmt.Fprintf(outputView, "[::d]%s[::-]\n", tview.Escape(text))
+			}
+			stdinField.SetText("")
+			return
+		}
+		app.SetFocus(list)
+	})
 
-	scanner := bufio.NewScanner(file)
-	var options []MakeOption
-	var lastComment string
-	targetRe := regexp.MustCompile(`^([a-zA-Z0-9_-]+):`) // target: line
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(strings.TrimSpace(line), "#") {
-			lastComment = strings.TrimSpace(strings.TrimPrefix(line, "#"))
-		} else if m := targetRe.FindStringSubmatch(line); m != nil {
-			options = append(options, MakeOption{Target: m[1], Comment: lastComment})
-			lastComment = ""
+	// endForcedRun turns AlwaysMake off once a run has used it, unless it
+	// is to stay on. started is runner.Started from before the run: a run
+	// refused by preflight or @requires, or spawned, leaves it on.
+	endForcedRun := func(started int) {
+		if runner.AlwaysMake && !ui.StickyRebuild && runner.Started() > started {
+			runner.AlwaysMake = false
+			setListTitle()
 		}
 	}
-	return options, scanner.Err()
-}
+	// runInPane clears the output pane and calls run in the background with
+	// the pane as its output. Its input is typed into stdinField, or read
+	// from stdin when that is given; runInPane closes it. Only one run is
+	// active at a time.
+	running := false
+	quitWhenDone := false // set by the quit confirmation's "Wait" choice
+	runInPane := func(stdin *os.File, run func(stdio Stdio) error) {
+		if running {
+			if stdin != nil {
+				stdin.Close()
+			}
+			return
+		}
+		stdinR := stdin
+		if stdin == nil {
+			// An *os.File keeps exec from copying stdin in a goroutine that
+			// would block Wait until the next line is typed.
+			r, w, err := os.Pipe()
+			if err != nil {
+				fmt.Fprintf(outputView, "[red]%s[-]\n", tview.Escape(err.Error()))
+				return
+			}
+			stdinR, stdinWriter = r, w
+		}
+		running = true
+		outputView.Clear()
+		outputView.SetTitle("Output")
+		if problemLog != nil {
+			problemLog.reset()
+			problems = nil
+			problemsList.Clear()
+			outputView.Highlight()
+		}
+		limit.lines = 0
+		if state.OutputSplit == 0 {
+			state.setOutputSplit(defaultOutputSplit)
+		}
+		outputOpen = true
+		layoutOutput()
+		if stdin == nil {
+			flex.AddItem(stdinField, 1, 0, false)
+		}
+		started := runner.Started()
+		go func() {
+			err := run(Stdio{Stdin: stdinR, Stdout: output, Stderr: output})
+			flushOutput()
+			stdinR.Close()
+			if w, ok := stdinWriter.(*os.File); ok {
+				w.Close()
+			}
+			app.QueueUpdateDraw(func() {
+				running = false
+				stdinWriter = nil
+				flex.RemoveItem(stdinField)
+				if app.GetFocus() == stdinField {
+					app.SetFocus(list)
+				}
+				relabel()
+				endForcedRun(started)
+				if err != nil && ui.CopyOnFailure {
+					copyFailure()
+				}
+				if quitWhenDone {
+					app.Stop()
+				}
+			})
+		}()
+	}
+	// prompt asks for a single line of text, starting from initial, and
+	// passes it to done unless the user cancels.
+	prompt := func(title, label, initial string, done func(text string)) {
+		form := tview.NewForm()
+		back := func() { app.SetRoot(flex, true).SetFocus(list) }
+		form.AddInputField(label, initial, 60, nil, nil).
+			AddButton("OK", func() {
+				text := form.GetFormItem(0).(*tview.InputField).GetText()
+				back()
+				done(text)
+			}).
+			AddButton("Cancel", back)
+		form.SetBorder(true).SetTitle(title).SetTitleAlign(tview.AlignLeft)
+		form.SetCancelFunc(back)
+		app.SetRoot(form, true).SetFocus(form)
+	}
+	showMessage := func(title, text string) {
+		descModal.SetText("[::b]" + title + "[-]\n\n" + text)
+		app.SetRoot(descModal, false).SetFocus(descModal)
+	}
 
-// Categorize Makefile targets into tabs
-func categorizeOptions(options []MakeOption) []Tab {
-	var apps, services, libs, demos, tests []MakeOption
-	for _, opt := range options {
-		name := opt.Target
-		// Only classify as demo or test if not also an app, service, or lib
-		if strings.Contains(name, "app") || strings.HasPrefix(name, "run-") || strings.HasPrefix(name, "build-app") {
-			apps = append(apps, opt)
-		} else if strings.Contains(name, "service") {
-			services = append(services, opt)
-		} else if strings.Contains(name, "lib") || strings.Contains(name, "_libraries") {
-			libs = append(libs, opt)
-		} else if strings.Contains(name, "demo") {
-			demos = append(demos, opt)
-		} else if strings.Contains(name, "test") {
-			tests = append(tests, opt)
+	// runTargetFrom runs target in the pane with stdin as its input and
+	// args after it, see runInPane. The variables target requires that
+	// args leave unset are asked for first, again until all are given.
+	// confirmRun calls run straight away, or with ui.Confirm or for a
+	// target marked Confirm once the preview of target with args is
+	// accepted.
+	confirmModal := tview.NewModal().AddButtons([]string{"Run", "Cancel"})
+	confirmRun := func(target string, args []string, run func()) {
+		if !ui.Confirm && !mustConfirm(tabs, target) {
+			run()
+			return
 		}
+		confirmModal.SetText("[::b]Run " + tview.Escape(runner.Resolve(target)) + "?[-]\n\n" + tview.Escape(runner.Preview(target, args...)))
+		confirmModal.SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			app.SetRoot(flex, true).SetFocus(list)
+			if buttonLabel == "Run" {
+				run()
+			}
+		})
+		app.SetRoot(confirmModal, false).SetFocus(confirmModal)
 	}
-	return []Tab{
-		{Name: "Apps", Options: apps},
-		{Name: "Services", Options: services},
-		{Name: "Library", Options: libs},
-		{Name: "Demo", Options: demos},
-		{Name: "Unit Tests", Options: tests},
+	// confirmSteps is confirmRun for a run of several targets: with
+	// ui.Confirm or when any of steps is marked Confirm, run is called
+	// once the list of steps is accepted.
+	confirmSteps := func(steps []string, run func()) {
+		marked := confirmedSteps(tabs, steps)
+		if !ui.Confirm && len(marked) == 0 {
+			run()
+			return
+		}
+		text := "[::b]Run " + tview.Escape(strings.Join(steps, " → ")) + "?[-]"
+		if len(marked) > 0 {
+			text += "\n\nConfirmation is asked for by " + tview.Escape(strings.Join(marked, ", ")) + "."
+		}
+		confirmModal.SetText(text)
+		confirmModal.SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			app.SetRoot(flex, true).SetFocus(list)
+			if buttonLabel == "Run" {
+				run()
+			}
+		})
+		app.SetRoot(confirmModal, false).SetFocus(confirmModal)
 	}
-}
+	var runTargetFrom func(target string, stdin *os.File, args ...string)
+	runTargetFrom = func(target string, stdin *os.File, args ...string) {
+		if running {
+			if stdin != nil {
+				stdin.Close()
+			}
+			return
+		}
+		if missing := runner.MissingVars(target, args); len(missing) > 0 && stdin == nil {
+			prompt("Run "+tview.Escape(target)+": set "+strings.Join(missing, ", "), "Arguments", withVars(args, missing), func(text string) {
+				args, err := shellWords(text)
+				if err != nil {
+					showMessage("Could not read the arguments", tview.Escape(err.Error()))
+					return
+				}
+				runTargetFrom(target, nil, args...)
+			})
+			return
+		}
+		confirmRun(target, args, func() {
+			runInPane(stdin, func(stdio Stdio) error {
+				err := runner.Run(target, stdio, args...)
+				paneNote("\n[::d]%s %s exited %d[::-]\n", runner.Program(), runner.Resolve(target), exitCode(err))
+				return err
+			})
+		})
+	}
+	runTarget = func(target string) { runTargetFrom(target, nil) }
+	runQueue := func() {
+		if len(queue) == 0 {
+			return
+		}
+		steps := queue
+		confirmSteps(steps, func() {
+			queue = nil
+			relabel()
+			runInPane(nil, func(stdio Stdio) error {
+				_, err := runner.RunSequence(steps, stdio)
+				return err
+			})
+		})
+	}
+	// runInteractive suspends the TUI and runs target on the terminal
+	// itself, for recipes that need full interactive input.
+	runInteractive := func(target string) {
+		confirmRun(target, nil, func() {
+			started := runner.Started()
+			app.Suspend(func() {
+				err := runner.Run(target, terminalStdio)
+				fmt.Printf("\n%s %s exited %d. Press Enter to return.", runner.Program(), runner.Resolve(target), exitCode(err))
+				bufio.NewReader(os.Stdin).ReadString('\n')
+			})
+			relabel()
+			endForcedRun(started)
+		})
+	}
+	layoutOutput()
 
-func main() {
-	guiFlag := flag.Bool("gui", false, "Launch graphical UI instead of terminal UI")
-	flag.Parse()
+	// copyOutput puts the output pane's text on the clipboard and confirms
+	// in the pane's title. The helper runs in the background so a large log
+	// does not stall the UI.
+	copyOutput := func() {
+		text := outputView.GetText(true)
+		if text == "" {
+			return
+		}
+		go func() {
+			status := fmt.Sprintf("Output (copied %d lines)", strings.Count(text, "\n")+1)
+			if err := copyToClipboard(text); err != nil {
+				status = "Output (copy failed: " + tview.Escape(err.Error()) + ")"
+			}
+			app.QueueUpdateDraw(func() { outputView.SetTitle(status) })
+			time.Sleep(3 * time.Second)
+			app.QueueUpdateDraw(func() { outputView.SetTitle("Output") })
+		}()
+	}
 
-	options, err := parseMakefile("../Makefile")
-	if err != nil {
-		fmt.Println("Error reading Makefile:", err)
-		os.Exit(1)
+	setListTitle = func() {
+		title := "[::b]Makefile Options[::-]"
+		if currentProfile != "" {
+			title += " (profile: " + tview.Escape(currentProfile) + ")"
+		}
+		if onlyChanged {
+			title += " (changed)"
+		}
+		if showInternal {
+			title += " (with internal)"
+		}
+		if byUsage {
+			title += " (by usage)"
+		}
+		if onlyPhony {
+			title += " (actions)"
+		}
+		if showImpact {
+			title += " (↑ needed by, leaf: nothing)"
+		}
+		if ui.CIResults != nil {
+			title += " (" + ciLegend + ")"
+		}
+		if jobs := runner.jobFlags(); jobs != nil {
+			title += " (" + strings.Join(jobs, " ") + ")"
+		}
+		if runner.alwaysMakeFlags() != nil {
+			if ui.StickyRebuild {
+				title += " (-B: rebuilding all)"
+			} else {
+				title += " (-B: next run rebuilds all)"
+			}
+		}
+		if depQuery != "" {
+			title += " (needs " + tview.Escape(depQuery) + ")"
+		}
+		list.SetTitle(title)
 	}
 
-	tabs := categorizeOptions(options)
+	// toggleChanged narrows the list to targets touching files changed in
+	// git. Outside a git repository it does nothing.
+	toggleChanged := func() {
+		if !onlyChanged {
+			files, ok := changedFiles(runner.Dir())
+			if !ok {
+				return
+			}
+			changed = files
+		}
+		onlyChanged = !onlyChanged
+		setListTitle()
+		updateList()
+	}
 
-	if *guiFlag {
-		runGUI(tabs)
-	} else {
-		runTUI(tabs)
+	// reloadTabs re-reads the Makefile with profile and redraws the tabs.
+	// The current tab and highlighted target are kept when they still
+	// exist.
+	reloadTabs := func(profile string) error {
+		newTabs, err := ui.Reload(profile)
+		if err != nil {
+			return err
+		}
+		tabName := tabs[currentTab].Name
+		var target string
+		if idx := list.GetCurrentItem(); idx >= 0 && idx < len(shown) {
+			target = shown[idx].Target
+		}
+		runner.Outcomes.ForgetChanged(tabs, newTabs)
+		tabs = newTabs
+		dependents = reverseDeps(tabs)
+		currentProfile = profile
+		currentTab, _ = findTab(tabs, tabName) // the first tab when it is gone
+		setListTitle()
+		updateTabBar()
+		updateList()
+		for i, opt := range shown {
+			if opt.Target == target {
+				list.SetCurrentItem(i)
+			}
+		}
+		return nil
 	}
-}
 
-func runTUI(tabs []Tab) {
-	app := tview.NewApplication()
-	tabBar := tview.NewTextView().SetDynamicColors(true)
-	list := tview.NewList()
-	descModal := tview.NewModal().SetText("").AddButtons([]string{"Close"})
+	// reloadMakefile re-reads the build file on demand and reports the
+	// targets added and removed, or else the number of targets, in the list
+	// title for a moment.
+	reloadMakefile := func() {
+		before := tabs
+		if err := reloadTabs(currentProfile); err != nil {
+			showMessage("Could not reload Makefile", err.Error())
+			return
+		}
+		status := fmt.Sprintf("reloaded %d targets", len(targetNames(tabs)))
+		if diff := targetDiff(before, tabs); diff != "" {
+			status = "reloaded: " + diff
+		}
+		list.SetTitle("[::b]Makefile Options[::-] (" + tview.Escape(status) + ")")
+		go func() {
+			time.Sleep(3 * time.Second)
+			app.QueueUpdateDraw(setListTitle)
+		}()
+	}
 
-	currentTab := 0
-	updateTabBar := func() {
-		var bar string
-		for i, t := range tabs {
-			if i == currentTab {
-				bar += "[yellow]" + t.Name + "[white] "
-			} else {
-				bar += t.Name + " "
+	// cycleProfile switches to the next categorization profile.
+	cycleProfile := func() {
+		if len(ui.Profiles) < 2 {
+			return
+		}
+		next := 0
+		for i, name := range ui.Profiles {
+			if name == currentProfile {
+				next = (i + 1) % len(ui.Profiles)
 			}
 		}
-		tabBar.SetText(bar)
+		if err := reloadTabs(ui.Profiles[next]); err != nil {
+			showMessage("Could not switch profile", err.Error())
+			return
+		}
+		state.setProfile(currentProfile)
+		state.save()
 	}
 
-	updateList := func() {
-		list.Clear()
-		opts := tabs[currentTab].Options
-		for i, opt := range opts {
-			label := opt.Target
-			if opt.Comment != "" {
-				label += " - " + opt.Comment
-			}
-			idx := i // capture for closure
-			list.AddItem(label, "", 0, func() {
-				cmd := exec.Command("make", opts[idx].Target)
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
-				cmd.Run()
+	// showNewTargetForm asks for a target to append to the Makefile and
+	// reloads the tabs once it has been written.
+	showNewTargetForm := func() {
+		form := tview.NewForm()
+		form.AddInputField("Name", "", 40, nil, nil).
+			AddInputField("Comment", "", 60, nil, nil).
+			AddTextArea("Recipe", "", 60, 4, 0, nil).
+			AddButton("Create", func() {
+				name := form.GetFormItemByLabel("Name").(*tview.InputField).GetText()
+				comment := form.GetFormItemByLabel("Comment").(*tview.InputField).GetText()
+				recipe := form.GetFormItemByLabel("Recipe").(*tview.TextArea).GetText()
+				if err := appendTarget(runner.Makefile, strings.TrimSpace(name), comment, recipe); err != nil {
+					showMessage("Could not create target", err.Error())
+					return
+				}
+				if err := reloadTabs(currentProfile); err != nil {
+					showMessage("Could not reload Makefile", err.Error())
+					return
+				}
+				app.SetRoot(flex, true).SetFocus(list)
+			}).
+			AddButton("Cancel", func() {
+				app.SetRoot(flex, true).SetFocus(list)
 			})
-		}
+		form.SetBorder(true).SetTitle("New Makefile target").SetTitleAlign(tview.AlignLeft)
+		form.SetCancelFunc(func() { app.SetRoot(flex, true).SetFocus(list) })
+		app.SetRoot(form, true).SetFocus(form)
 	}
 
-	updateTabBar()
-	updateList()
-
-	flex := tview.NewFlex().SetDirection(tview.FlexRow).
-		AddItem(tabBar, 1, 0, false).
-		AddItem(list, 0, 1, true)
+	list.SetBorder(true).SetTitleAlign(tview.AlignLeft)
+	// Secondary texts are on, if empty, so every item takes two rows.
+	fillListOnResize(list, 2)
+	setListTitle()
 
-	list.SetBorder(true).SetTitle("[::b]Makefile Options").SetTitleAlign(tview.AlignLeft)
-	list.SetDoneFunc(func() { app.Stop() })
+	// quit exits straight away when nothing is running. Otherwise it asks
+	// first, since stopping would interrupt the run.
+	quitModal := tview.NewModal().
+		SetText("A target is still running. Quit and cancel it?").
+		AddButtons([]string{"Quit and cancel", "Keep running", "Wait"})
+	quitModal.SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+		switch buttonLabel {
+		case "Quit and cancel":
+			runner.Cancel()
+			app.Stop()
+			return
+		case "Wait":
+			quitWhenDone = true
+			outputView.SetTitle("Output (quitting when the run finishes)")
+		}
+		app.SetRoot(flex, true).SetFocus(list)
+	})
+	quit := func() {
+		if !running {
+			app.Stop()
+			return
+		}
+		app.SetRoot(quitModal, false).SetFocus(quitModal)
+	}
+	list.SetDoneFunc(quit)
+	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyCtrlC {
+			quit()
+			return nil
+		}
+		return event
+	})
 
 	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		switch event.Key() {
+		case tcell.KeyTab:
+			if running {
+				app.SetFocus(stdinField)
+			}
+			return nil
+		case tcell.KeyF5:
+			reloadMakefile()
+			return nil
+		case tcell.KeyEnter:
+			if idx := list.GetCurrentItem(); ui.EnterDefault && (idx < 0 || idx >= len(shown)) {
+				if goal, ok := defaultGoal(tabs); ok {
+					runTarget(goal.Target)
+				}
+				return nil
+			}
 		case tcell.KeyLeft:
 			if currentTab > 0 {
-				currentTab--
-				updateTabBar()
-				updateList()
+				switchTab(currentTab - 1)
 			}
 			return nil
 		case tcell.KeyRight:
 			if currentTab < len(tabs)-1 {
-				currentTab++
-				updateTabBar()
-				updateList()
+				switchTab(currentTab + 1)
+			}
+			return nil
+		}
+		// 1 to 9 jump to that tab, including ones scrolled out of view.
+		if r := event.Rune(); r >= '1' && r <= '9' {
+			if i := int(r - '1'); i < len(tabs) {
+				switchTab(i)
+			}
+			return nil
+		}
+		// Alt+letter jumps to the next target starting with that letter;
+		// plain letters stay action keys.
+		if event.Key() == tcell.KeyRune && event.Modifiers()&tcell.ModAlt != 0 {
+			if i := nextByLetter(shown, list.GetCurrentItem(), event.Rune()); i >= 0 {
+				list.SetCurrentItem(i)
 			}
 			return nil
 		}
 		switch event.Rune() {
+		case ' ':
+			idx := list.GetCurrentItem()
+			if idx >= 0 && idx < len(shown) {
+				if pos := queuePos(shown[idx].Target); pos >= 0 {
+					queue = append(queue[:pos], queue[pos+1:]...)
+				} else {
+					queue = append(queue, shown[idx].Target)
+				}
+				relabel()
+			}
+			return nil
+		case 'R':
+			reloadMakefile()
+			return nil
+		case 'G':
+			switch {
+			case !ui.CanSwitch:
+			case ui.GUIStarted:
+				showMessage("Cannot reopen the GUI", "The GUI can only start once per session. Restart CoolBox with -gui.")
+			case running:
+				showMessage("Cannot switch to the GUI", "Wait for the run to finish or cancel it with x first.")
+			default:
+				next = &handover{Frontend: "gui", Tabs: tabs, Tab: currentTab}
+				app.Stop()
+			}
+			return nil
+		case 'x':
+			// Stops the run in the output pane, including a log file
+			// being tailed after it.
+			if running {
+				runner.Cancel()
+			}
+			return nil
+		case 'e':
+			// make -p reads the whole Makefile and prints every built-in
+			// rule, so it runs in the background.
+			idx := list.GetCurrentItem()
+			if idx >= 0 && idx < len(shown) {
+				target := shown[idx].Target
+				go func() {
+					entry, err := runner.Explain(target)
+					app.QueueUpdateDraw(func() {
+						if err != nil {
+							showMessage("Could not explain "+tview.Escape(target), tview.Escape(err.Error()))
+							return
+						}
+						showMessage("make -p: "+tview.Escape(target), tview.Escape(entry))
+					})
+				}()
+			}
+			return nil
+		case 'P':
+			switch {
+			case problemLog == nil:
+				showMessage("No problems collected", "Start CoolBox with -problems, or set problemPresets or problemPatterns in the config, to collect errors and warnings from the output.")
+			case len(problems) == 0:
+				showMessage("No problems", "The last run printed no errors or warnings.")
+			default:
+				app.SetRoot(problemsList, true).SetFocus(problemsList)
+			}
+			return nil
+		case 'V':
+			vars, err := runner.Variables()
+			if err != nil {
+				showMessage("No variables", tview.Escape(err.Error()))
+				return nil
+			}
+			showMessage("Variables", tview.Escape(describeVariables(vars, runner.Makefile)))
+			return nil
+		case 'S':
+			// The highlighted target's rule as written in the Makefile.
+			idx := list.GetCurrentItem()
+			if idx < 0 || idx >= len(shown) {
+				return nil
+			}
+			source, err := runner.targetSource(shown[idx])
+			if err != nil {
+				showMessage("No source", tview.Escape(err.Error()))
+				return nil
+			}
+			sourceView.SetTitle("Source: " + tview.Escape(shown[idx].Target) + " (Esc: back)")
+			sourceView.SetText(tview.Escape(source)).ScrollToBeginning()
+			app.SetRoot(sourceView, true).SetFocus(sourceView)
+			return nil
+		case 'd':
+			// A separate mode from name matching: list the targets that
+			// depend on a prerequisite. An empty answer clears it.
+			prompt("Targets depending on", "Prerequisite", "", func(text string) {
+				depQuery = strings.TrimSpace(text)
+				setListTitle()
+				updateList()
+			})
+			return nil
+		case 'u':
+			byUsage = !byUsage
+			setListTitle()
+			updateList()
+			return nil
+		case 'a':
+			onlyPhony = !onlyPhony
+			setListTitle()
+			updateList()
+			return nil
+		case 'I':
+			idx := list.GetCurrentItem()
+			if !running && idx >= 0 && idx < len(shown) {
+				runInteractive(shown[idx].Target)
+			}
+			return nil
+		case 'g':
+			toggleChanged()
+			return nil
+		case '.':
+			showInternal = !showInternal
+			setListTitle()
+			updateList()
+			return nil
+		case 's':
+			runQueue()
+			return nil
 		case 'i', 'm':
 			idx := list.GetCurrentItem()
-			opts := tabs[currentTab].Options
-			if idx >= 0 && idx < len(opts) {
-				desc := opts[idx].Comment
+			if idx >= 0 && idx < len(shown) {
+				desc := markdownTags(shown[idx].Comment)
 				if desc == "" {
 					desc = "No description available."
 				}
-				descModal.SetText("[::b]" + opts[idx].Target + "[-]\n\n" + desc)
+				var details string
+				if recipe := describeRecipe(shown[idx].Recipe); recipe != "" {
+					details += "\n\nRecipe:\n" + recipe
+				}
+				if src := shown[idx].Source; src != "" {
+					details += "\n\nDefined in: " + src
+				}
+				details += "\n\n" + dependents.describeImpact(shown[idx])
+				details += "\n\nRuns: " + strings.Join(runner.CommandLine(shown[idx].Target), " ")
+				descModal.SetText("[::b]" + shown[idx].Target + "[-]\n\n" + desc + tview.Escape(details))
 				app.SetRoot(descModal, false).SetFocus(descModal)
 			}
 			return nil
+		case '+':
+			resizeOutput(10)
+			return nil
+		case '-':
+			resizeOutput(-10)
+			return nil
+		case 'p':
+			outputOpen = !outputOpen
+			state.setOutputPinned(outputOpen)
+			if outputOpen && state.OutputSplit == 0 {
+				state.setOutputSplit(defaultOutputSplit)
+			}
+			state.save()
+			layoutOutput()
+			return nil
+		case 'c':
+			cycleProfile()
+			return nil
+		case 'w':
+			// Write the HTML report of the session so far.
+			path := ui.Report
+			if path == "" {
+				path = defaultReportFile
+			}
+			prompt("Write run report", "File", path, func(path string) {
+				if strings.TrimSpace(path) == "" {
+					return
+				}
+				if err := writeReportFile(path, runner.Dir(), runner.History); err != nil {
+					showMessage("Could not write report", tview.Escape(err.Error()))
+					return
+				}
+				showMessage("Report written", tview.Escape(path))
+			})
+			return nil
+		case 'r':
+			// Replay the last few targets run here, stopping at the first
+			// failure.
+			prompt("Replay the last targets run", "How many", "3", func(text string) {
+				n, err := strconv.Atoi(strings.TrimSpace(text))
+				if err != nil || n <= 0 {
+					showMessage("Invalid count", tview.Escape(text)+" is not a number of runs.")
+					return
+				}
+				steps := state.lastRuns(runner.Makefile, n)
+				if len(steps) == 0 {
+					showMessage("Nothing to replay", "No targets have been run from this Makefile yet.")
+					return
+				}
+				confirmSteps(steps, func() {
+					runInPane(nil, func(stdio Stdio) error {
+						_, err := runner.Replay(steps, stdio)
+						return err
+					})
+				})
+			})
+			return nil
+		case 'B':
+			// Time a set of targets, or every phony one, and report them
+			// slowest first.
+			prompt("Benchmark targets", "Targets (comma-separated, or phony)", "phony", func(text string) {
+				targets, err := benchTargets(strings.TrimSpace(text), tabs, runner.Workflows)
+				if err != nil {
+					showMessage("Cannot benchmark", tview.Escape(err.Error()))
+					return
+				}
+				if len(targets) == 0 {
+					showMessage("Nothing to benchmark", "No targets match "+tview.Escape(text)+".")
+					return
+				}
+				confirmSteps(targets, func() {
+					runInPane(nil, func(stdio Stdio) error {
+						// Failures are part of the report, not the run's outcome.
+						runner.Bench(targets, stdio)
+						return nil
+					})
+				})
+			})
+			return nil
+		case 'b':
+			// Show the blast radius of each target: how many others
+			// depend on it.
+			showImpact = !showImpact
+			setListTitle()
+			updateList()
+			return nil
+		case 'J':
+			// Pick how many jobs make runs at once; 0 or nothing turns -j
+			// off again.
+			initial := runner.Jobs
+			if initial == 0 {
+				initial = runtime.NumCPU()
+			}
+			prompt("Parallel jobs for "+tview.Escape(runner.Program()), "Jobs", strconv.Itoa(initial), func(text string) {
+				text = strings.TrimSpace(text)
+				jobs, err := strconv.Atoi(text)
+				if text == "" {
+					jobs, err = 0, nil
+				}
+				if err != nil || jobs < 0 {
+					showMessage("Invalid job count", tview.Escape(text)+" is not a number of jobs.")
+					return
+				}
+				runner.Jobs = jobs
+				setListTitle()
+			})
+			return nil
+		case 'F':
+			// Force a rebuild whatever the timestamps with make -B, for
+			// the next run or, when sticky, until turned off again.
+			if running {
+				return nil
+			}
+			if len(runner.Launch) > 0 || !isGNUMake(runner.Program()) {
+				showMessage("No force rebuild", tview.Escape(runner.Program())+" has no -B option; only make does.")
+				return nil
+			}
+			runner.AlwaysMake = !runner.AlwaysMake
+			setListTitle()
+			return nil
+		case '<':
+			// Feed a file to the highlighted target, as "make format < file".
+			idx := list.GetCurrentItem()
+			if running || idx < 0 || idx >= len(shown) {
+				return nil
+			}
+			target := shown[idx].Target
+			prompt("Run "+tview.Escape(target)+" with stdin from", "File", "", func(path string) {
+				if strings.TrimSpace(path) == "" {
+					return
+				}
+				file, err := openStdinFile(path, runner.Dir())
+				if err != nil {
+					showMessage("Could not open stdin file", tview.Escape(err.Error()))
+					return
+				}
+				runTargetFrom(target, file)
+			})
+			return nil
+		case 'A':
+			// Run the highlighted target with arguments, such as
+			// "ENV=dev", starting from those its @args comment suggests.
+			idx := list.GetCurrentItem()
+			if running || idx < 0 || idx >= len(shown) {
+				return nil
+			}
+			opt := shown[idx]
+			prompt("Run "+tview.Escape(opt.Target)+" with arguments", "Arguments", opt.Args, func(text string) {
+				args, err := shellWords(text)
+				if err != nil {
+					showMessage("Could not read the arguments", tview.Escape(err.Error()))
+					return
+				}
+				runTargetFrom(opt.Target, nil, args...)
+			})
+			return nil
+		case '!':
+			if ui.AllowShell {
+				prompt("Run shell command in "+runner.Dir(), "Command", "", func(command string) {
+					if strings.TrimSpace(command) == "" {
+						return
+					}
+					runInPane(nil, func(stdio Stdio) error {
+						err := runner.RunShell(command, stdio)
+						paneNote("\n[::d]%s exited %d[::-]\n", tview.Escape(command), exitCode(err))
+						return err
+					})
+				})
+			}
+			return nil
+		case 'n':
+			if ui.AllowEdit {
+				showNewTargetForm()
+			}
+			return nil
+		case 'y':
+			copyOutput()
+			return nil
+		case 'o':
+			dir := runner.Dir()
+			if err := openInFileManager(dir); err != nil {
+				showMessage("Makefile directory", dir+"\n\n"+err.Error())
+			}
+			return nil
 		}
 		return event
 	})
@@ -180,13 +2413,78 @@ func runTUI(tabs []Tab) {
 	descModal.SetDoneFunc(func(buttonIndex int, buttonLabel string) {
 		app.SetRoot(flex, true).SetFocus(list)
 	})
+	problemsList.SetSelectedFunc(func(i int, _, _ string, _ rune) {
+		app.SetRoot(flex, true).SetFocus(list)
+		if !outputOpen {
+			outputOpen = true
+			layoutOutput()
+		}
+		outputView.Highlight(problems[i].Region).ScrollToHighlight()
+	})
+	problemsList.SetDoneFunc(func() {
+		app.SetRoot(flex, true).SetFocus(list)
+	})
+	sourceView.SetDoneFunc(func(tcell.Key) {
+		app.SetRoot(flex, true).SetFocus(list)
+	})
+
+	// Refit labels and tabs whenever the terminal is resized.
+	app.SetBeforeDrawFunc(func(screen tcell.Screen) bool {
+		if _, _, width, _ := tabBar.GetInnerRect(); width != tabBarWidth {
+			tabBarWidth = width
+			updateTabBar()
+		}
+		width, _ := screen.Size()
+		width -= 2 // list border
+		if width != labelWidth {
+			labelWidth = width
+			relabel()
+		}
+		return false
+	})
 
-	if err := app.SetRoot(flex, true).EnableMouse(true).Run(); err != nil {
+	app.SetRoot(flex, true).EnableMouse(true)
+	if ui.Notice != "" {
+		showMessage("Nothing to run", tview.Escape(ui.Notice))
+	} else if ui.Overview {
+		overview := tview.NewTextView().SetText(overviewText(tabs, ui.Runner) + "\nPress any key to continue.")
+		overview.SetBorder(true).SetTitle(" " + tview.Escape(runner.Makefile) + " ").SetTitleAlign(tview.AlignLeft)
+		overview.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			app.SetRoot(flex, true).SetFocus(list)
+			return nil
+		})
+		app.SetRoot(overview, true).SetFocus(overview)
+	}
+	autoRun := func() {
+		steps := ui.AutoRun
+		if len(steps) == 1 {
+			runInPane(nil, func(stdio Stdio) error {
+				err := runner.Run(steps[0], stdio)
+				paneNote("\n[::d]%s %s exited %d[::-]\n", runner.Program(), runner.Resolve(steps[0]), exitCode(err))
+				return err
+			})
+		} else {
+			runInPane(nil, func(stdio Stdio) error {
+				_, err := runner.RunSequence(steps, stdio)
+				return err
+			})
+		}
+	}
+	if len(ui.AutoRun) > 0 {
+		// Only a step marked Confirm is asked about; ui.Confirm is not.
+		if len(confirmedSteps(tabs, ui.AutoRun)) > 0 {
+			confirmSteps(ui.AutoRun, autoRun)
+		} else {
+			autoRun()
+		}
+	}
+	if err := app.Run(); err != nil {
 		fmt.Println(err)
 	}
+	return next
 }
 
-func runGUI(tabs []Tab) {
+func runGUI(tabs []Tab, runner *Runner, state *State, ui uiOptions) (next *handover) {
 	fmt.Println("Launching Fyne GUI...")
 	defer func() {
 		if r := recover(); r != nil {
@@ -194,68 +2492,192 @@ func runGUI(tabs []Tab) {
 		}
 	}()
 	fyneApp := app.New()
+	fyneApp.Settings().SetTheme(ui.Theme)
 	w := fyneApp.NewWindow("Makefile GUI")
+	setTitle := func(summary string) {
+		title := "Makefile GUI"
+		if env := envLabel(ui.Env, runner.Makefile); env != "" {
+			title += " — " + env
+		}
+		if summary != "" {
+			title += " — " + summary
+		}
+		w.SetTitle(title)
+	}
+	setTitle(gitSummary(runner.Dir()))
+	stopGit := make(chan struct{})
+	defer close(stopGit)
+	go func() {
+		ticker := time.NewTicker(gitRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopGit:
+				return
+			case <-ticker.C:
+			}
+			summary := gitSummary(runner.Dir())
+			fyne.Do(func() { setTitle(summary) })
+		}
+	}()
 
 	tabNames := make([]string, len(tabs))
 	for idx, t := range tabs {
-		tabNames[idx] = t.Name
+		tabNames[idx] = tabLabel(t.Name, ui.TabIcons)
 	}
 	tabSelect := widget.NewSelect(tabNames, nil)
-	list := widget.NewList(
-		func() int { return len(tabs[0].Options) },
+	showInternal := false // list targets marked Internal
+	// visible drops internal targets unless showInternal is set.
+	visible := func(opts []MakeOption) []MakeOption {
+		var result []MakeOption
+		for _, opt := range opts {
+			if !opt.Internal || showInternal {
+				result = append(result, opt)
+			}
+		}
+		return result
+	}
+	shown := visible(tabs[0].Options)
+	var list *widget.List
+	list = widget.NewList(
+		func() int { return len(shown) },
 		func() fyne.CanvasObject { return widget.NewButton("", nil) },
 		func(i int, obj fyne.CanvasObject) {
 			btn := obj.(*widget.Button)
-			opt := tabs[0].Options[i]
-			label := opt.Target
-			if opt.Comment != "" {
-				label += " - " + opt.Comment
+			opt := shown[i]
+			switch runner.Outcomes.Get(opt) {
+			case OutcomePassed:
+				btn.Importance = widget.SuccessImportance
+			case OutcomeFailed:
+				btn.Importance = widget.DangerImportance
+			default:
+				btn.Importance = widget.MediumImportance
 			}
-			btn.SetText(label)
-			btn.OnTapped = func() {
-				go func() {
-					cmd := exec.Command("make", opt.Target)
-					cmd.Stdout = os.Stdout
-					cmd.Stderr = os.Stderr
-					cmd.Run()
-				}()
+			text := ciMarker(ui.CIResults, opt) + formatLabel(opt, 0)
+			if ui.Accessible {
+				text = outcomeText(runner.Outcomes.Get(opt)) + text
+			}
+			btn.SetText(text)
+			var start func(args []string)
+			start = func(args []string) {
+				if missing := runner.MissingVars(opt.Target, args); len(missing) > 0 {
+					entry := widget.NewEntry()
+					entry.SetText(withVars(args, missing))
+					items := []*widget.FormItem{widget.NewFormItem("Arguments", entry)}
+					dialog.ShowForm("Run "+opt.Target+": set "+strings.Join(missing, ", "), "Run", "Cancel", items, func(ok bool) {
+						if !ok {
+							return
+						}
+						args, err := shellWords(entry.Text)
+						if err != nil {
+							dialog.ShowError(err, w)
+							return
+						}
+						start(args)
+					}, w)
+					return
+				}
+				run := func() {
+					go func() {
+						runner.Run(opt.Target, Stdio{Stdout: os.Stdout, Stderr: os.Stderr}, args...)
+						fyne.Do(list.Refresh)
+					}()
+				}
+				if !ui.Confirm && !opt.Confirm {
+					run()
+					return
+				}
+				dialog.ShowConfirm("Run "+runner.Resolve(opt.Target)+"?", runner.Preview(opt.Target, args...), func(ok bool) {
+					if ok {
+						run()
+					}
+				}, w)
 			}
+			btn.OnTapped = func() { start(nil) }
 		},
 	)
 
-	tabSelect.OnChanged = func(name string) {
-		for _, t := range tabs {
-			if t.Name == name {
-				list.Length = func() int { return len(t.Options) }
-				list.UpdateItem = func(idx int, obj fyne.CanvasObject) {
-					btn := obj.(*widget.Button)
-					opt := t.Options[idx]
-					label := opt.Target
-					if opt.Comment != "" {
-						label += " - " + opt.Comment
-					}
-					btn.SetText(label)
-					btn.OnTapped = func() {
-						go func() {
-							cmd := exec.Command("make", opt.Target)
-							cmd.Stdout = os.Stdout
-							cmd.Stderr = os.Stderr
-							cmd.Run()
-						}()
-					}
-				}
-				list.Refresh()
-				break
+	tabSelect.OnChanged = func(string) {
+		if i := tabSelect.SelectedIndex(); i >= 0 && i < len(tabs) {
+			shown = visible(tabs[i].Options)
+			list.Refresh()
+		}
+	}
+	tabSelect.SetSelectedIndex(ui.StartTab)
+	internalCheck := widget.NewCheck("Show internal targets", func(on bool) {
+		showInternal = on
+		tabSelect.OnChanged(tabSelect.Selected)
+	})
+
+	content := container.NewVBox()
+	// With more than one build file present, let the user pick the tool
+	// whose targets are listed.
+	if len(ui.Runners) > 1 {
+		current := ui.Runner
+		runnerSelect := widget.NewSelect(ui.Runners, nil)
+		runnerSelect.Selected = current
+		runnerSelect.OnChanged = func(name string) {
+			next, err := ui.SwitchRunner(name, ui.Profile)
+			if err != nil {
+				dialog.ShowError(err, w)
+				runnerSelect.Selected = current
+				runnerSelect.Refresh()
+				return
 			}
+			current = name
+			tabs = next
+			names := make([]string, len(tabs))
+			for idx, t := range tabs {
+				names[idx] = tabLabel(t.Name, ui.TabIcons)
+			}
+			tabSelect.SetOptions(names)
+			tabSelect.SetSelectedIndex(0)
 		}
+		content.Add(widget.NewLabel("Runner:"))
+		content.Add(runnerSelect)
+	}
+
+	closeWindow := func() {
+		size := w.Canvas().Size()
+		state.setWindowSize(size.Width, size.Height)
+		state.save()
+		w.Close()
 	}
 
-	w.SetContent(container.NewVBox(
-		widget.NewLabel("Select Category:"),
-		tabSelect,
-		widget.NewLabel("Makefile Targets:"),
-		list,
-	))
-	w.Resize(fyne.NewSize(600, 400))
+	openDir := widget.NewButton("Open Directory", func() {
+		dir := runner.Dir()
+		if err := openInFileManager(dir); err != nil {
+			fmt.Println("Makefile directory:", dir)
+			dialog.ShowInformation("Makefile directory", dir, w)
+		}
+	})
+
+	content.Add(widget.NewLabel("Select Category:"))
+	content.Add(tabSelect)
+	content.Add(internalCheck)
+	content.Add(widget.NewLabel("Makefile Targets:"))
+	if ui.CIResults != nil {
+		content.Add(widget.NewLabel(ciLegend))
+	}
+	content.Add(list)
+	content.Add(openDir)
+	if ui.CanSwitch {
+		content.Add(widget.NewButton("Switch to Terminal UI", func() {
+			next = &handover{Frontend: "tview", Tabs: tabs, Tab: tabSelect.SelectedIndex()}
+			closeWindow()
+		}))
+	}
+	w.SetContent(content)
+	// Fyne cannot place windows, so only the size is restored.
+	size := fyne.NewSize(600, 400)
+	if state.WindowWidth > 0 && state.WindowHeight > 0 {
+		size = fyne.NewSize(state.WindowWidth, state.WindowHeight)
+	}
+	w.Resize(size)
+	if ui.Notice != "" {
+		dialog.ShowInformation("Nothing to run", ui.Notice, w)
+	}
+	w.SetCloseIntercept(closeWindow)
 	w.ShowAndRun()
+	return next
 }
@@ -0,0 +1,2740 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fyne.io/fyne/v2/theme"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
+	"gopkg.in/yaml.v3"
+
+	"internal_gui/makefile"
+)
+
+// writeMakefile writes content to a Makefile in a fresh temp directory and
+// returns its path.
+func writeMakefile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "Makefile")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseMakefileDoubleColon(t *testing.T) {
+	path := writeMakefile(t, `# Remove build output
+clean::
+	rm -rf build
+
+clean::
+	rm -rf dist
+
+build:
+	go build ./...
+`)
+	options, err := parseMakefile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MakeOption{
+		{Target: "clean", Comment: "Remove build output", DoubleColon: true, Recipe: "rm -rf build\nrm -rf dist", IsDefault: true, Line: 2, EndLine: 3},
+		{Target: "build", Recipe: "go build ./...", Line: 8, EndLine: 9},
+	}
+	if !reflect.DeepEqual(options, want) {
+		t.Errorf("got %+v, want %+v", options, want)
+	}
+}
+
+func TestApplyAliases(t *testing.T) {
+	options := []MakeOption{{Target: "run-integration-test-suite", Comment: "Integration"}, {Target: "build"}}
+
+	got, err := applyAliases(options, map[string]string{"it": "run-integration-test-suite"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alias := MakeOption{Target: "it", Comment: "Integration", AliasOf: "run-integration-test-suite"}
+	if len(got) != 3 || !reflect.DeepEqual(got[1], alias) {
+		t.Fatalf("got %+v, want alias %+v after its target", got, alias)
+	}
+
+	if _, err := applyAliases(options, map[string]string{"build": "run-integration-test-suite"}); err == nil {
+		t.Error("alias shadowing a real target: expected error")
+	}
+	if _, err := applyAliases(options, map[string]string{"x": "missing"}); err == nil {
+		t.Error("alias to unknown target: expected error")
+	}
+}
+
+func TestSummarizeSequence(t *testing.T) {
+	results := []StepResult{
+		{Target: "clean"},
+		{Target: "build", Err: errors.New("boom")},
+		{Target: "test", Skipped: true},
+	}
+	want := "sequence: clean ok, build failed (exit 1), test skipped"
+	if got := summarizeSequence(results); got != want {
+		t.Errorf("summarizeSequence = %q, want %q", got, want)
+	}
+}
+
+func TestParseMakefileBOM(t *testing.T) {
+	path := writeMakefile(t, "\ufeffall:\n\techo all\n")
+	options, err := parseMakefile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(options) != 1 || options[0].Target != "all" {
+		t.Errorf("got %+v, want the single target all", options)
+	}
+}
+
+func TestAppendTarget(t *testing.T) {
+	path := writeMakefile(t, "build:\n\tgo build ./...")
+
+	if err := appendTarget(path, "lint", "Run the linters", "go vet ./...\n\tstaticcheck ./..."); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "build:\n\tgo build ./...\n\n# Run the linters\nlint:\n\tgo vet ./...\n\tstaticcheck ./...\n"
+	if string(data) != want {
+		t.Errorf("Makefile = %q, want %q", data, want)
+	}
+
+	if err := appendTarget(path, "build", "", "true"); err == nil {
+		t.Error("duplicate target: expected error")
+	}
+	if err := appendTarget(path, "bad name", "", "true"); err == nil {
+		t.Error("invalid name: expected error")
+	}
+}
+
+func TestCategorizeWithRules(t *testing.T) {
+	options := []MakeOption{{Target: "deploy-prod"}, {Target: "tf-plan"}, {Target: "build"}}
+	rules := []TabRule{
+		{Name: "Deploy", Contains: []string{"deploy"}},
+		{Name: "Infra", Prefix: []string{"tf-"}},
+	}
+	tabs := categorizeWithRules(options, rules)
+	if len(tabs) != 2 || len(tabs[0].Options) != 1 || len(tabs[1].Options) != 1 {
+		t.Fatalf("got %+v", tabs)
+	}
+	if tabs[0].Options[0].Target != "deploy-prod" || tabs[1].Options[0].Target != "tf-plan" {
+		t.Errorf("got %+v", tabs)
+	}
+}
+
+func TestParseMakefileIncludes(t *testing.T) {
+	path := writeMakefile(t, "build:\n\tgo build\ninclude extra.mk\n-include optional.mk\n")
+	extra := filepath.Join(filepath.Dir(path), "extra.mk")
+	if err := os.WriteFile(extra, []byte("# Lint the code\nlint:\n\tgo vet\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	options, err := parseMakefile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(options) != 2 || options[1].Target != "lint" || options[1].Comment != "Lint the code" {
+		t.Errorf("got %+v, want build and lint from extra.mk", options)
+	}
+}
+
+func TestParseMakefileBrokenIncludeError(t *testing.T) {
+	path := writeMakefile(t, "build:\n\tgo build\n\ninclude missing.mk\n")
+	_, err := parseMakefile(path)
+	if err == nil {
+		t.Fatal("expected an error for a missing include")
+	}
+	if want := path + ":4: include "; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not contain %q", err, want)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("error %q does not wrap fs.ErrNotExist", err)
+	}
+}
+
+func TestParseMakefileScannerErrorLine(t *testing.T) {
+	path := writeMakefile(t, "build:\n"+strings.Repeat("x", bufio.MaxScanTokenSize+1)+"\n")
+	_, err := parseMakefile(path)
+	if err == nil {
+		t.Fatal("expected an error for an over-long line")
+	}
+	if want := path + ":2: "; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not contain %q", err, want)
+	}
+}
+
+func TestReferencesChanged(t *testing.T) {
+	changed := []string{"src/parser/lexer.go", "docs/intro.md"}
+	tests := []struct {
+		opt  MakeOption
+		want bool
+	}{
+		{MakeOption{Target: "parser", Deps: []string{"src/parser/lexer.go"}}, true},
+		{MakeOption{Target: "build", Recipe: "go build ./src/..."}, false},
+		{MakeOption{Target: "build", Recipe: "go build -o bin/app ./src"}, true},
+		{MakeOption{Target: "docs", Recipe: "mkdocs build docs/*.md"}, true},
+		{MakeOption{Target: "clean", Recipe: "rm -rf build"}, false},
+	}
+	for _, tt := range tests {
+		if got := referencesChanged(tt.opt, changed); got != tt.want {
+			t.Errorf("referencesChanged(%+v) = %v, want %v", tt.opt, got, tt.want)
+		}
+	}
+}
+
+func TestDescribeRecipe(t *testing.T) {
+	got := describeRecipe("@echo building\n-@rm -f tmp\ngo build")
+	want := "@   echo building\n-@  rm -f tmp\n    go build\n\n@ not echoed, - errors ignored\n"
+	if got != want {
+		t.Errorf("describeRecipe = %q, want %q", got, want)
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	tabs := []Tab{
+		{Name: "Apps", Options: []MakeOption{{Target: "run-app", Comment: "Run a | b"}}},
+		{Name: "Empty"},
+	}
+	var b strings.Builder
+	if err := writeMarkdown(&b, tabs); err != nil {
+		t.Fatal(err)
+	}
+	want := "# Makefile targets\n\n## Apps\n\n| Target | Description |\n| --- | --- |\n| `run-app` | Run a \\| b |\n"
+	if b.String() != want {
+		t.Errorf("writeMarkdown = %q, want %q", b.String(), want)
+	}
+}
+
+func TestParseMakefileRuleLineVariants(t *testing.T) {
+	path := writeMakefile(t, "build:   \n"+
+		"lint: # Run linters\n"+
+		"# Preceding wins over a single hash\n"+
+		"fmt: # inline\n"+
+		"# Preceding loses to a double hash\n"+
+		"vet: ## Vet the code\n"+
+		"test : unit # Run tests\n"+
+		"VERSION := 1.0\n"+
+		"CFLAGS ::= -O2\n")
+	options, err := parseMakefile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MakeOption{
+		{Target: "build", IsDefault: true, Line: 1, EndLine: 1},
+		{Target: "lint", Comment: "Run linters", Line: 2, EndLine: 2},
+		{Target: "fmt", Comment: "Preceding wins over a single hash", Line: 4, EndLine: 4},
+		{Target: "vet", Comment: "Vet the code", Line: 6, EndLine: 6},
+		{Target: "test", Comment: "Run tests", Deps: []string{"unit"}, Line: 7, EndLine: 7},
+	}
+	if !reflect.DeepEqual(options, want) {
+		t.Errorf("got %+v\nwant %+v", options, want)
+	}
+}
+
+func TestCategorizeOptionsTests(t *testing.T) {
+	tests := []struct {
+		target string
+		isTest bool
+	}{
+		{"test", true},
+		{"unit-tests", true},
+		{"test_parser", true},
+		{"deploy-latest", false},
+		{"attest", false},
+		{"build-test-fixtures", false},
+	}
+	for _, tt := range tests {
+		tabs := categorizeOptions([]MakeOption{{Target: tt.target}})
+		got := len(tabs[len(tabs)-1].Options) == 1
+		if got != tt.isTest {
+			t.Errorf("%s in Unit Tests = %v, want %v", tt.target, got, tt.isTest)
+		}
+	}
+}
+
+func TestCategorizeOptionsApps(t *testing.T) {
+	tests := []struct {
+		target string
+		isApp  bool
+	}{
+		{"build-app", true},
+		{"build-apps", true},
+		{"test-app", true},
+		{"run-server", true},
+		{"build-apparmor", false},
+		{"rerun-tests", false},
+	}
+	for _, tt := range tests {
+		tabs := categorizeOptions([]MakeOption{{Target: tt.target}})
+		i, err := findTab(tabs, "Apps")
+		if got := err == nil && len(tabs[i].Options) == 1; got != tt.isApp {
+			t.Errorf("%s in Apps = %v, want %v", tt.target, got, tt.isApp)
+		}
+	}
+}
+
+func TestFindTab(t *testing.T) {
+	tabs := []Tab{{Name: "All"}, {Name: "Apps"}, {Name: "Unit Tests"}}
+	if i, err := findTab(tabs, "unit tests"); err != nil || i != 2 {
+		t.Errorf("findTab(unit tests) = %d, %v, want 2", i, err)
+	}
+	_, err := findTab(tabs, "Docs")
+	if err == nil || !strings.Contains(err.Error(), "available: All, Apps, Unit Tests") {
+		t.Errorf("findTab(Docs) error = %v, want the available tabs listed", err)
+	}
+}
+
+func TestRunNotifies(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("stubs notify-send, which only Linux and the BSDs use")
+	}
+	bin := t.TempDir()
+	log := filepath.Join(bin, "notified")
+	stub := "#!/bin/sh\nprintf '%s|%s\\n' \"$1\" \"$2\" >> " + log + "\n"
+	if err := os.WriteFile(filepath.Join(bin, "notify-send"), []byte(stub), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	r := &Runner{Makefile: writeMakefile(t, "build:\n\t@true\nfail:\n\t@false\n")}
+	r.Run("build", Stdio{})
+	r.NotifyAfter = time.Nanosecond
+	r.Run("build", Stdio{})
+	r.Run("fail", Stdio{Stderr: io.Discard})
+	got, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "CoolBox|" + r.Program() + " build succeeded after 0s\nCoolBox|" + r.Program() + " fail failed after 0s\n"
+	if string(got) != want {
+		t.Errorf("notifications:\n%s\nwant only the runs with NotifyAfter set:\n%s", got, want)
+	}
+	if got := appleScriptEscape(`say "hi" \ bye`); got != `say \"hi\" \\ bye` {
+		t.Errorf("appleScriptEscape = %s", got)
+	}
+	if got := powerShellEscape("it's"); got != "it''s" {
+		t.Errorf("powerShellEscape = %s", got)
+	}
+}
+
+func TestOpenInFileManager(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("stubs xdg-open, which only Linux and the BSDs use")
+	}
+	if dir := (&Runner{Makefile: "Makefile"}).Dir(); !filepath.IsAbs(dir) {
+		t.Errorf("Dir of a relative Makefile = %q, want it absolute", dir)
+	}
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	if err := openInFileManager(bin); err == nil || !strings.Contains(err.Error(), "xdg-open") {
+		t.Errorf("openInFileManager without an opener = %v, want it named in an error", err)
+	}
+	opened := filepath.Join(bin, "opened")
+	stub := "#!/bin/sh\nprintf '%s' \"$1\" > " + opened + ".tmp && mv " + opened + ".tmp " + opened + "\n"
+	if err := os.WriteFile(filepath.Join(bin, "xdg-open"), []byte(stub), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+"/bin:/usr/bin")
+	if err := openInFileManager("/some/dir"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := os.ReadFile(opened)
+		if err == nil {
+			if string(got) != "/some/dir" {
+				t.Errorf("xdg-open got %q, want /some/dir", got)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("xdg-open was not run")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGitSummary(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	if got := gitSummary(dir); got != "" {
+		t.Errorf("gitSummary outside a repository = %q, want empty", got)
+	}
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	git("init", "-q")
+	git("checkout", "-q", "-b", "feature")
+	if err := os.WriteFile(filepath.Join(dir, "Makefile"), []byte("build:\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "Makefile")
+	git("commit", "-qm", "Add Makefile")
+	if got := gitSummary(dir); got != "feature (clean)" {
+		t.Errorf("gitSummary after committing = %q, want feature (clean)", got)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Makefile"), []byte("build:\n\tgo build\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := gitSummary(dir); got != "feature (dirty)" {
+		t.Errorf("gitSummary with a modified file = %q, want feature (dirty)", got)
+	}
+}
+
+func TestCategorizeHelp(t *testing.T) {
+	tabs := categorizeOptions([]MakeOption{{Target: "help", Comment: "Show this help"}, {Target: "help-app"}})
+	i, err := findTab(tabs, otherTab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tabs[i].Options) != 1 || tabs[i].Options[0].Target != "help" {
+		t.Errorf("%s tab = %+v, want only help", otherTab, tabs[i].Options)
+	}
+	if _, err := findTab(categorizeOptions([]MakeOption{{Target: "build-app"}}), otherTab); err == nil {
+		t.Errorf("%s tab present without a help target", otherTab)
+	}
+}
+
+func TestMarkInternal(t *testing.T) {
+	options := []MakeOption{
+		{Target: "_setup"},
+		{Target: "helper", Comment: "Internal: used by build"},
+		{Target: "build", Comment: "Build the internal tools"},
+	}
+	if err := markInternal(options, ""); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, true, false} {
+		if options[i].Internal != want {
+			t.Errorf("%s Internal = %v, want %v", options[i].Target, options[i].Internal, want)
+		}
+	}
+}
+
+func TestSortTabs(t *testing.T) {
+	opts := func(n int) []MakeOption { return make([]MakeOption, n) }
+	tabs := []Tab{
+		{Name: "All", Options: opts(1)},
+		{Name: "Apps", Options: opts(1)},
+		{Name: "Services", Options: opts(3)},
+		{Name: "Library", Options: opts(1)},
+		{Name: "Demo", Options: opts(2)},
+	}
+	if err := sortTabs(tabs, "count"); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tab := range tabs {
+		got = append(got, tab.Name)
+	}
+	want := []string{"All", "Services", "Demo", "Apps", "Library"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sortTabs = %v, want %v", got, want)
+	}
+	if err := sortTabs(tabs, "name"); err == nil {
+		t.Error("sortTabs accepted an unknown mode")
+	}
+}
+
+func TestDirBannerWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &dirBannerWriter{w: &buf}
+	for _, chunk := range []string{"make[1]: Enter", "ing directory '/src/lib'\ncc -c a.c\n", "Are you sure? [y/N] "} {
+		w.Write([]byte(chunk))
+	}
+	want := "\x1b[2mmake[1]: Entering directory '/src/lib'\x1b[0m\ncc -c a.c\nAre you sure? [y/N] "
+	if buf.String() != want {
+		t.Errorf("dimmed output = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	w = &dirBannerWriter{w: &buf, hide: true}
+	w.Write([]byte("make: Leaving directory '/src'\nmake: *** [all] Error 1\n"))
+	if want := "make: *** [all] Error 1\n"; buf.String() != want {
+		t.Errorf("hidden output = %q, want %q", buf.String(), want)
+	}
+
+	// A run ending on what may still be a banner keeps it.
+	buf.Reset()
+	w.Write([]byte("mak"))
+	w.flush()
+	if buf.String() != "mak" || w.pending != nil {
+		t.Errorf("flushed output = %q, want the held-back partial line", buf.String())
+	}
+}
+
+func TestHighlightWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &highlightWriter{w: &buf}
+	for _, chunk := range []string{"- not a diff\n--- a/x\n+++ b/x\n@@ -1 +1 @@\n-old\n+new\n", `{"a": [1, tr`, "ue]}\nok  \tpkg\t0.1s\n[sudo] password: "} {
+		w.Write([]byte(chunk))
+	}
+	want := "- not a diff\n" +
+		"\x1b[1m--- a/x\x1b[0m\n\x1b[1m+++ b/x\x1b[0m\n\x1b[36m@@ -1 +1 @@\x1b[0m\n" +
+		"\x1b[31m-old\x1b[0m\n\x1b[32m+new\x1b[0m\n" +
+		"{\n  \x1b[36m\"a\"\x1b[0m: [\n    \x1b[33m1\x1b[0m,\n    \x1b[33mtrue\x1b[0m\n  ]\n}\n" +
+		"\x1b[32mok  \tpkg\t0.1s\x1b[0m\n[sudo] password: "
+	if buf.String() != want {
+		t.Errorf("highlighted output = %q, want %q", buf.String(), want)
+	}
+
+	// A diff ends at the first line that cannot be part of it, and with
+	// the run.
+	buf.Reset()
+	w = &highlightWriter{w: &buf}
+	w.Write([]byte("@@ -1 +1 @@\n+new\nDone\n+ not a diff\n@@ -2 +2 @@\n{\"a\""))
+	w.flush()
+	w.Write([]byte("-after\n"))
+	want = "\x1b[36m@@ -1 +1 @@\x1b[0m\n\x1b[32m+new\x1b[0m\nDone\n+ not a diff\n\x1b[36m@@ -2 +2 @@\x1b[0m\n{\"a\"-after\n"
+	if buf.String() != want {
+		t.Errorf("output after the diff = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestUndocumented(t *testing.T) {
+	got := undocumented([]MakeOption{
+		{Target: "build", Comment: "Build everything"},
+		{Target: "lint"},
+		{Target: "_helper", Internal: true},
+	})
+	if len(got) != 1 || got[0].Target != "lint" {
+		t.Errorf("undocumented = %+v, want only lint", got)
+	}
+}
+
+func TestParseOtherBuildFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		parse   func(string) ([]MakeOption, error)
+		content string
+		want    []MakeOption
+	}{
+		{"justfile", parseJustfile, `set shell := ["bash", "-c"]
+version := "1.0"
+
+# Build the binary
+build: gen
+    go build ./...
+
+[private]
+gen:
+    go generate ./...
+`, []MakeOption{
+			{Target: "build", Comment: "Build the binary", Deps: []string{"gen"}, Recipe: "go build ./...", IsPhony: true, IsDefault: true},
+			{Target: "gen", Internal: true, Recipe: "go generate ./...", IsPhony: true},
+		}},
+		{"Taskfile", parseTaskfile, `version: '3'
+tasks:
+  lint:
+    desc: Run the linters
+    deps: [fmt]
+    cmds:
+      - golangci-lint run
+  fmt:
+    internal: true
+    cmds:
+      - cmd: gofmt -w .
+`, []MakeOption{
+			{Target: "lint", Comment: "Run the linters", Deps: []string{"fmt"}, Recipe: "golangci-lint run", IsPhony: true},
+			{Target: "fmt", Internal: true, Recipe: "gofmt -w .", IsPhony: true},
+		}},
+		{"package.json", parsePackageJSON, `{"scripts": {"test": "jest", "build": "tsc"}}`, []MakeOption{
+			{Target: "build", Recipe: "tsc", IsPhony: true},
+			{Target: "test", Recipe: "jest", IsPhony: true},
+		}},
+	}
+	for _, tt := range tests {
+		got, err := tt.parse(writeMakefile(t, tt.content))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetectBuildTools(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"Makefile", "GNUmakefile", "justfile"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("build:\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found := detectBuildTools(dir)
+	if len(found) != 2 || found[0].Name != "make" || found[1].Name != "just" {
+		t.Fatalf("detectBuildTools = %+v, want make and just", found)
+	}
+	// make reads GNUmakefile ahead of Makefile, so that is the one listed.
+	if got, want := found[0].buildFile(dir), filepath.Join(dir, "GNUmakefile"); got != want {
+		t.Errorf("make's build file = %q, want %q", got, want)
+	}
+}
+
+func TestParseCachedInvalidatesOnInclude(t *testing.T) {
+	path := writeMakefile(t, "include extra.mk\nbuild:\n")
+	extra := filepath.Join(filepath.Dir(path), "extra.mk")
+	if err := os.WriteFile(extra, []byte("lint:\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tool := buildTools[0]
+	cacheFile := filepath.Join(t.TempDir(), "cache.gob")
+	targets := func() []string {
+		t.Helper()
+		options, err := parseCached(tool, path, cacheFile, makefile.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, opt := range options {
+			names = append(names, opt.Target)
+		}
+		return names
+	}
+	if got := targets(); !reflect.DeepEqual(got, []string{"lint", "build"}) {
+		t.Fatalf("cold parse = %v", got)
+	}
+	if got := targets(); !reflect.DeepEqual(got, []string{"lint", "build"}) {
+		t.Fatalf("warm parse = %v", got)
+	}
+	if err := os.WriteFile(extra, []byte("lint:\nvet:\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := targets(); !reflect.DeepEqual(got, []string{"lint", "vet", "build"}) {
+		t.Errorf("parse after editing include = %v", got)
+	}
+}
+
+// largeMakefile writes a Makefile with n documented targets.
+func largeMakefile(b *testing.B, n int) string {
+	var content strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&content, "## Build component %d\ncomponent-%d: dep-%d\n\t@echo building %d\n\t$(CC) -o out/%d src/%d.c\n\n", i, i, i, i, i, i)
+	}
+	path := filepath.Join(b.TempDir(), "Makefile")
+	if err := os.WriteFile(path, []byte(content.String()), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+func BenchmarkParseCold(b *testing.B) {
+	path := largeMakefile(b, 20000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseMakefile(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseWarm(b *testing.B) {
+	path := largeMakefile(b, 20000)
+	cacheFile := filepath.Join(b.TempDir(), "cache.gob")
+	if _, err := parseCached(buildTools[0], path, cacheFile, makefile.Options{}); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseCached(buildTools[0], path, cacheFile, makefile.Options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRunSummary(t *testing.T) {
+	got := runSummary("build", nil, 4230*time.Millisecond)
+	if want := "coolbox: build exited 0 in 4.2s"; got != want {
+		t.Errorf("runSummary = %q, want %q", got, want)
+	}
+}
+
+func TestParseMakefileRecipePrefix(t *testing.T) {
+	path := writeMakefile(t, `.RECIPEPREFIX = >
+# Build the binary
+build:
+> # not a target comment
+> go build ./...
+
+test:
+>go test ./...
+`)
+	options, err := parseMakefile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MakeOption{
+		{Target: "build", Comment: "Build the binary", Recipe: " # not a target comment\n go build ./...", IsDefault: true, Line: 3, EndLine: 5},
+		{Target: "test", Recipe: "go test ./...", Line: 7, EndLine: 8},
+	}
+	if !reflect.DeepEqual(options, want) {
+		t.Errorf("parseMakefile =\n %+v\nwant\n %+v", options, want)
+	}
+}
+
+func TestCategoryAnnotation(t *testing.T) {
+	path := writeMakefile(t, `# Ship to production
+# @category Deploy
+release:
+
+# @category apps
+tool:
+
+demo-ui:
+`)
+	options, err := parseMakefile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options[0].Comment != "Ship to production" || options[0].Category != "Deploy" {
+		t.Errorf("release = %+v", options[0])
+	}
+	tabs := categorizeOptions(options)
+	got := make(map[string][]string)
+	for _, tab := range tabs {
+		for _, opt := range tab.Options {
+			got[tab.Name] = append(got[tab.Name], opt.Target)
+		}
+	}
+	want := map[string][]string{"Apps": {"tool"}, "Demo": {"demo-ui"}, "Deploy": {"release"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("categorizeOptions = %v, want %v", got, want)
+	}
+}
+
+func TestCategorizeByDir(t *testing.T) {
+	path := writeMakefile(t, "build:\ninclude common.mk services/api/api.mk\n# @category Deploy\nrelease:\n")
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(filepath.Join(dir, "services", "api"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"common.mk":           "lint:\n",
+		"services/api/api.mk": "api-test:\napi-run:\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	options, err := parseMakefile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tabs, err := categorize(options, "dir", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tab := range tabs {
+		var names []string
+		for _, opt := range tab.Options {
+			names = append(names, opt.Target)
+		}
+		got = append(got, tab.Name+": "+strings.Join(names, " "))
+	}
+	want := []string{".: build lint", "services/api: api-test api-run", "Deploy: release"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("categorize(dir) = %q, want %q", got, want)
+	}
+
+	if _, err := categorize(nil, "size", dir); err == nil {
+		t.Error("categorize accepted an unknown mode")
+	}
+}
+
+func TestRunCounts(t *testing.T) {
+	s := &State{}
+	s.countRun("Makefile", "build")
+	s.countRun("Makefile", "build")
+	s.countRun("Makefile", "lint")
+	if n := s.runCount("Makefile", MakeOption{Target: "b", AliasOf: "build"}); n != 2 {
+		t.Errorf("alias run count = %d, want 2", n)
+	}
+	s.pruneRunCounts("Makefile", []MakeOption{{Target: "build"}})
+	if n := s.runCount("Makefile", MakeOption{Target: "lint"}); n != 0 {
+		t.Errorf("run count of removed target = %d, want 0", n)
+	}
+	if got := s.lastRuns("Makefile", 3); !reflect.DeepEqual(got, []string{"build", "build"}) {
+		t.Errorf("last runs after pruning = %q, want only build's", got)
+	}
+}
+
+func TestRunShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh commands")
+	}
+	path := writeMakefile(t, "")
+	r := &Runner{Makefile: path}
+	var out bytes.Buffer
+	if err := r.RunShell("pwd && echo $((1 + 2))", Stdio{Stdout: &out}); err != nil {
+		t.Fatal(err)
+	}
+	dir, _ := filepath.EvalSymlinks(filepath.Dir(path))
+	if want := dir + "\n3\n"; out.String() != want {
+		t.Errorf("RunShell output = %q, want %q from the Makefile directory", out.String(), want)
+	}
+	var exitErr *exec.ExitError
+	if err := r.RunShell("exit 3", Stdio{}); !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("RunShell(exit 3) = %v, want exit status 3", err)
+	}
+	remote := &Runner{Makefile: "https://example.com/Makefile"}
+	if err := remote.RunShell("true", Stdio{}); !errors.Is(err, errRemoteRun) {
+		t.Errorf("RunShell for a remote Makefile = %v, want errRemoteRun", err)
+	}
+}
+
+func TestCopyToClipboard(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("stubs xclip, which only Linux and the BSDs use")
+	}
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	if err := copyToClipboard("lost"); err == nil {
+		t.Error("copyToClipboard without a helper succeeded")
+	}
+	copied := filepath.Join(bin, "copied")
+	// Only shell builtins, since PATH holds nothing but the stub.
+	stub := "#!/bin/sh\necho \"$*\" > " + copied + "\nwhile IFS= read -r line; do echo \"$line\"; done >> " + copied + "\n"
+	if err := os.WriteFile(filepath.Join(bin, "xclip"), []byte(stub), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyToClipboard("make: *** [build] Error 1\n"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(copied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "-selection clipboard\nmake: *** [build] Error 1\n"; string(got) != want {
+		t.Errorf("xclip got %q, want %q", got, want)
+	}
+}
+
+func TestFirstOnPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stubs programs as shell scripts")
+	}
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	if got := firstOnPath(windowsMakes); got != "make" {
+		t.Errorf("firstOnPath with none installed = %q, want make", got)
+	}
+	for _, name := range []string{"nmake", "mingw32-make"} {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := firstOnPath(windowsMakes); got != "mingw32-make" {
+		t.Errorf("firstOnPath = %q, want mingw32-make, preferred over nmake", got)
+	}
+	if got := shellCommand("echo hi").Args; !reflect.DeepEqual(got, []string{"sh", "-c", "echo hi"}) {
+		t.Errorf("shellCommand args = %q, want sh -c", got)
+	}
+}
+
+func TestRunnerCancel(t *testing.T) {
+	r := &Runner{Makefile: writeMakefile(t, "")}
+	done := make(chan error)
+	go func() { done <- r.RunShell("sleep 10 & wait", Stdio{}) }()
+	for {
+		r.mu.Lock()
+		n := len(r.active)
+		r.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	r.Cancel()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("cancelled run reported success")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Cancel did not stop the run")
+	}
+}
+
+func TestPromptBuildTool(t *testing.T) {
+	tools := buildTools[:2]
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"\n", "make"},
+		{"2\n", "just"},
+		{"7\njust\n", "just"},
+		{"", "make"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if got := promptBuildTool(tools, strings.NewReader(tt.input), &out); got.Name != tt.want {
+			t.Errorf("promptBuildTool(%q) = %s, want %s", tt.input, got.Name, tt.want)
+		}
+	}
+}
+
+func TestDatabaseEntry(t *testing.T) {
+	db := `# Variables
+
+build = not a rule
+
+# Files
+
+# Not a target:
+build.c:
+#  Implicit rule search has not been done.
+
+# makefile (from 'Makefile', line 3)
+build: CFLAGS := -O2
+build: dep.c
+#  Phony target (prerequisite of .PHONY).
+# variable set hash-table stats:
+# Load=1/32=3%, Rehash=0, Collisions=0/5=0%
+#  recipe to execute (from 'Makefile', line 6):
+	@echo $(CFLAGS)
+
+# files hash-table stats:
+`
+	got, ok := databaseEntry(db, "build")
+	want := `# makefile (from 'Makefile', line 3)
+build: CFLAGS := -O2
+build: dep.c
+#  Phony target (prerequisite of .PHONY).
+#  recipe to execute (from 'Makefile', line 6):
+	@echo $(CFLAGS)`
+	if !ok || got != want {
+		t.Errorf("databaseEntry = %q, %v\nwant %q", got, ok, want)
+	}
+	if _, ok := databaseEntry(db, "dep.c"); ok {
+		t.Error("databaseEntry found a target that is only a prerequisite")
+	}
+}
+
+func TestDependsOn(t *testing.T) {
+	opt := MakeOption{Target: "build", Deps: []string{"generate-proto", "vendor"}}
+	for query, want := range map[string]bool{"generate": true, "VENDOR": true, "lint": false} {
+		if got := dependsOn(opt, query); got != want {
+			t.Errorf("dependsOn(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestParseMakefileFragmentDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Makefile.d")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"20-test.mk":  "# Run tests\ntest: build\n",
+		"10-build.mk": "# Build it\nbuild:\n",
+		"notes.txt":   "ignored:\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	options, err := parseMakefile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MakeOption{
+		{Target: "build", Comment: "Build it", Source: filepath.Join(dir, "10-build.mk"), IsDefault: true, Line: 2, EndLine: 2},
+		{Target: "test", Comment: "Run tests", Deps: []string{"build"}, Source: filepath.Join(dir, "20-test.mk"), Line: 2, EndLine: 2},
+	}
+	if !reflect.DeepEqual(options, want) {
+		t.Errorf("parseMakefile(dir) =\n %+v\nwant\n %+v", options, want)
+	}
+
+	r := &Runner{Makefile: dir}
+	got := strings.Join(r.CommandLine("test"), " ")
+	if want := "make -f Makefile.d/10-build.mk -f Makefile.d/20-test.mk test"; got != want {
+		t.Errorf("CommandLine = %q, want %q", got, want)
+	}
+	if _, err := parseMakefile(t.TempDir()); err == nil {
+		t.Error("parseMakefile accepted a directory without fragments")
+	}
+}
+
+func TestParseMakefileBinary(t *testing.T) {
+	path := writeMakefile(t, "\x7fELF\x02\x01\x01\x00\x00\x00all: x\n")
+	_, err := parseMakefile(path)
+	if err == nil || !strings.Contains(err.Error(), "not a text Makefile") {
+		t.Errorf("parseMakefile(binary) error = %v, want not a text Makefile", err)
+	}
+}
+
+func TestLineLimitWriter(t *testing.T) {
+	var buf bytes.Buffer
+	calls := 0
+	w := &lineLimitWriter{w: &buf, max: 2, onExceed: func() { calls++ }}
+	for _, chunk := range []string{"a\nb\n", "c\n", "d\n"} {
+		w.Write([]byte(chunk))
+	}
+	if calls != 1 {
+		t.Errorf("onExceed called %d times, want 1", calls)
+	}
+	if buf.String() != "a\nb\nc\nd\n" {
+		t.Errorf("output = %q, want everything passed through", buf.String())
+	}
+}
+
+func TestLauncherConfigJSON(t *testing.T) {
+	makefile := writeMakefile(t, "")
+	dir := filepath.Dir(makefile)
+	os.Mkdir(filepath.Join(dir, "web"), 0o755)
+	config := `{"tabs": [{"name": "Dev", "entries": [
+		{"label": "where", "command": "pwd", "cwd": "web", "description": "Print the directory"}]}]}`
+	if err := os.WriteFile(filepath.Join(dir, ".coolbox.json"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(defaultConfigPath(makefile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tabs, entries, err := launcherTabs(cfg.Tabs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Tab{{Name: "Dev", Options: []MakeOption{{Target: "where", Comment: "Print the directory", Recipe: "pwd", IsPhony: true}}}}
+	if !reflect.DeepEqual(tabs, want) {
+		t.Errorf("launcherTabs = %+v, want %+v", tabs, want)
+	}
+	var out bytes.Buffer
+	r := &Runner{Makefile: makefile, Launch: entries}
+	if err := r.Run("where", Stdio{Stdout: &out}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); filepath.Base(got) != "web" {
+		t.Errorf("entry ran in %q, want the web directory", got)
+	}
+
+	cfg.Tabs = append(cfg.Tabs, cfg.Tabs[0])
+	if _, _, err := launcherTabs(cfg.Tabs); err == nil {
+		t.Error("launcherTabs accepted a duplicate label")
+	}
+}
+
+func TestNextByLetter(t *testing.T) {
+	opts := []MakeOption{{Target: "build"}, {Target: "Bench"}, {Target: "clean"}, {Target: "bundle"}}
+	var got []int
+	for i, n := 0, 0; n < 4; n++ {
+		i = nextByLetter(opts, i, 'b')
+		got = append(got, i)
+	}
+	if want := []int{1, 3, 0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("jumps to b = %v, want %v", got, want)
+	}
+	if i := nextByLetter(opts, 0, 'z'); i != -1 {
+		t.Errorf("nextByLetter(z) = %d, want -1", i)
+	}
+}
+
+func TestRunnerEcho(t *testing.T) {
+	makefile := writeMakefile(t, "")
+	r := &Runner{Makefile: makefile, Command: []string{"echo"}, Flags: []string{"ENV=prod", "a b"}, Echo: true}
+	var out bytes.Buffer
+	if err := r.Run("build", Stdio{Stdout: &out}); err != nil {
+		t.Fatal(err)
+	}
+	first, _, _ := strings.Cut(out.String(), "\n")
+	if !strings.HasPrefix(first, "$ cd ") || !strings.HasSuffix(first, " && echo ENV=prod 'a b' build") {
+		t.Errorf("echoed %q, want a cd and the quoted command line", first)
+	}
+}
+
+func TestRunnerContainer(t *testing.T) {
+	makefile := writeMakefile(t, "")
+	dir := filepath.Dir(makefile)
+	r := &Runner{
+		Makefile:     makefile,
+		Flags:        []string{"-s"},
+		Container:    "golang:1.20",
+		ContainerEnv: []string{"GOFLAGS"},
+		Targets:      map[string]TargetConfig{"lint": {Container: "linter"}},
+		Launch:       map[string]LauncherEntry{"serve": {Command: "npm start", Cwd: "web"}},
+	}
+	want := []string{"docker", "run", "--rm", "-i", "--init", "-v", dir + ":/src", "-w", "/src", "-e", "GOFLAGS", "golang:1.20", "make", "-s", "build"}
+	if got := r.CommandLine("build"); !reflect.DeepEqual(got, want) {
+		t.Errorf("CommandLine(build) = %q, want %q", got, want)
+	}
+	if got := r.CommandLine("lint"); got[len(got)-4] != "linter" {
+		t.Errorf("CommandLine(lint) = %q, want the target's own image", got)
+	}
+	want = []string{"docker", "run", "--rm", "-i", "--init", "-v", dir + ":/src", "-w", "/src/web", "-e", "GOFLAGS", "golang:1.20", "sh", "-c", "npm start"}
+	if got := r.CommandLine("serve"); !reflect.DeepEqual(got, want) {
+		t.Errorf("CommandLine(serve) = %q, want %q", got, want)
+	}
+	if r.Program() == "docker" {
+		t.Error("Program() names docker instead of the build tool")
+	}
+}
+
+func TestRunnerCwd(t *testing.T) {
+	makefile := writeMakefile(t, "where:\n\t@pwd\n")
+	dir, _ := filepath.EvalSymlinks(filepath.Dir(makefile))
+	if err := os.MkdirAll(filepath.Join(dir, "services", "api"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	r := &Runner{Makefile: filepath.Join(dir, "Makefile"), Targets: map[string]TargetConfig{"where": {Cwd: "services/api"}}, Aliases: map[string]string{"w": "where"}}
+	want := []string{makeProgram(), "-f", filepath.Join("..", "..", "Makefile"), "where"}
+	if got := r.CommandLine("w"); !reflect.DeepEqual(got, want) {
+		t.Errorf("CommandLine(w) = %q, want %q", got, want)
+	}
+	if preview := r.Preview("w"); !strings.Contains(preview, "Directory: "+filepath.Join(dir, "services", "api")+"\n") {
+		t.Errorf("preview %q lacks the target's directory", preview)
+	}
+	var out bytes.Buffer
+	if err := r.Run("w", Stdio{Stdout: &out, Stderr: &out}); err != nil {
+		t.Fatalf("Run: %v\n%s", err, out.String())
+	}
+	if got := strings.TrimSpace(out.String()); got != filepath.Join(dir, "services", "api") {
+		t.Errorf("ran in %q, want services/api", got)
+	}
+	if got := r.CommandLine("other"); len(got) != 2 {
+		t.Errorf("CommandLine(other) = %q, want make without -f", got)
+	}
+	r.Command = []string{"task"}
+	if got := r.targetDir("w"); got != dir {
+		t.Errorf("targetDir(w) for task = %q, want the build file's directory", got)
+	}
+}
+
+func TestRunnerPreview(t *testing.T) {
+	makefile := writeMakefile(t, "")
+	r := &Runner{Makefile: makefile, Command: []string{"echo"}, Flags: []string{"-s", "ENV=prod"}, Aliases: map[string]string{"b": "build"}}
+	preview := r.Preview("b")
+	for _, want := range []string{
+		"Arguments: -s ENV=prod build\n",
+		"Directory: " + filepath.Dir(makefile) + "\n",
+		"Overrides: ENV=prod\n",
+	} {
+		if !strings.Contains(preview, want) {
+			t.Errorf("preview %q lacks %q", preview, want)
+		}
+	}
+	if preview := r.Preview("b", "TAG=v1"); !strings.Contains(preview, "Arguments: -s ENV=prod build TAG=v1\n") || !strings.Contains(preview, "Overrides: ENV=prod TAG=v1\n") {
+		t.Errorf("preview with args = %q", preview)
+	}
+
+	var out bytes.Buffer
+	if err := r.Run("b", Stdio{Stdout: &out}, "TAG=v1"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "-s ENV=prod build TAG=v1\n"; out.String() != want {
+		t.Errorf("Run with args printed %q, want %q", out.String(), want)
+	}
+}
+
+func TestShellWords(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"  ENV=dev   -j4 ", []string{"ENV=dev", "-j4"}},
+		{`MSG='hello world' TAG="v1 \"rc\"" a\ b ''`, []string{"MSG=hello world", `TAG=v1 "rc"`, "a b", ""}},
+		{`"$HOME" '\'`, []string{"$HOME", `\`}},
+	}
+	for _, tt := range tests {
+		if got, err := shellWords(tt.in); err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("shellWords(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+	args := []string{"MSG=it's here", "", "plain", `a"b`}
+	var quoted []string
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	if got, err := shellWords(strings.Join(quoted, " ")); err != nil || !reflect.DeepEqual(got, args) {
+		t.Errorf("shellWords of shellQuote'd args = %q, %v, want %q", got, err, args)
+	}
+	for _, in := range []string{`MSG='open`, `TAG="open`} {
+		if _, err := shellWords(in); err == nil {
+			t.Errorf("shellWords(%q) gave no error", in)
+		}
+	}
+}
+
+func TestRunSpawnInBackground(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("spawned targets open a Terminal window on macOS")
+	}
+	for _, name := range []string{"TMUX", "DISPLAY", "WAYLAND_DISPLAY"} {
+		t.Setenv(name, "")
+	}
+	r := &Runner{Makefile: writeMakefile(t, ""), Command: []string{"echo"}, Targets: map[string]TargetConfig{"serve": {Spawn: true}}}
+	var out bytes.Buffer
+	if err := r.Run("serve", Stdio{Stdout: &out, Stderr: &out}); err != nil {
+		t.Fatal(err)
+	}
+	_, log, ok := strings.Cut(strings.TrimSpace(out.String()), "logging to ")
+	if !strings.HasPrefix(out.String(), "Spawned serve in the background") || !ok {
+		t.Fatalf("output = %q, want where serve was spawned", out.String())
+	}
+	defer os.Remove(log)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+		if data, _ := os.ReadFile(log); string(data) == "serve\n" {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("log holds %q, want the target's output", data)
+		}
+	}
+
+	out.Reset()
+	if err := r.Run("build", Stdio{Stdout: &out}); err != nil || out.String() != "build\n" {
+		t.Errorf("build ran with output %q, %v; want it run in place", out.String(), err)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	if _, err := exec.LookPath("make"); err != nil {
+		t.Skip("make is not installed")
+	}
+	path := writeMakefile(t, "build:\necho built\n")
+	r := &Runner{Makefile: path, Preflight: true}
+	var out bytes.Buffer
+	err := r.Run("build", Stdio{Stdout: &out, Stderr: &out})
+	if err == nil || !strings.Contains(out.String(), "Makefile:2: *** missing separator") || strings.Contains(out.String(), "built") {
+		t.Fatalf("Run = %v with output %q, want the syntax error and no run", err, out.String())
+	}
+	// make given as the command is still make; in a container it is not
+	// the host's.
+	if err := (&Runner{Makefile: path, Preflight: true, Command: []string{"make"}}).preflight("build"); err == nil {
+		t.Error("preflight with make as the command found no error")
+	}
+	if err := (&Runner{Makefile: path, Preflight: true, Container: "alpine"}).preflight("build"); err != nil {
+		t.Errorf("preflight in a container = %v, want it skipped", err)
+	}
+
+	// The fix, which also changes the file's size, is picked up although
+	// the result is cached.
+	if err := os.WriteFile(path, []byte("build:\n\t@echo built\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := r.Run("build", Stdio{Stdout: &out, Stderr: &out}); err != nil || out.String() != "built\n" {
+		t.Errorf("Run after the fix = %v with output %q", err, out.String())
+	}
+}
+
+func TestRunSequenceTagged(t *testing.T) {
+	r := &Runner{Makefile: writeMakefile(t, ""), Command: []string{"echo"}, Parallel: true}
+	var out bytes.Buffer
+	if _, err := r.RunSequence([]string{"build", "test"}, Stdio{Stdout: &out, Stderr: &out}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"[build] build\n", "[test] test\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output %q lacks %q", out.String(), want)
+		}
+	}
+
+	r.Parallel, r.TagOutput = false, "never"
+	out.Reset()
+	r.RunSequence([]string{"build", "test"}, Stdio{Stdout: &out, Stderr: &out})
+	if strings.Contains(out.String(), "[build] ") {
+		t.Errorf("output %q tagged with -tag-output never", out.String())
+	}
+}
+
+// overlapWriter records whether two writes to it were ever in progress at
+// once.
+type overlapWriter struct {
+	busy, overlapped int32
+}
+
+func (w *overlapWriter) Write(p []byte) (int, error) {
+	if !atomic.CompareAndSwapInt32(&w.busy, 0, 1) {
+		atomic.StoreInt32(&w.overlapped, 1)
+		return len(p), nil
+	}
+	time.Sleep(time.Millisecond)
+	atomic.StoreInt32(&w.busy, 0)
+	return len(p), nil
+}
+
+func TestRunParallelUntagged(t *testing.T) {
+	path := writeMakefile(t, "a b c:\n\t@for i in 1 2 3 4 5; do echo $@$$i; sleep 0.01; done\n")
+	r := &Runner{Makefile: path, Parallel: true, TagOutput: "never"}
+	var w overlapWriter
+	if _, err := r.RunSequence([]string{"a", "b", "c"}, Stdio{Stdout: &w, Stderr: &w}); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&w.overlapped) != 0 {
+		t.Error("parallel steps wrote to the output at the same time")
+	}
+}
+
+func TestTagWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &tagWriter{w: &buf, tag: "[x] ", mu: &sync.Mutex{}}
+	w.Write([]byte("a\nb"))
+	w.Write([]byte("c\n\nd"))
+	w.flush()
+	if want := "[x] a\n[x] bc\n[x] \n[x] d\n"; buf.String() != want {
+		t.Errorf("tagged output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestRunnerPTY(t *testing.T) {
+	r := &Runner{Makefile: writeMakefile(t, ""), PTY: true}
+	var out bytes.Buffer
+	if err := r.RunShell("test -t 1 && echo tty || echo pipe", Stdio{Stdout: &out, Stderr: &out}); err != nil {
+		t.Fatal(err)
+	}
+	want := "tty\n"
+	if pair, ok := r.openPTY(); ok {
+		pair.pty.Close()
+		pair.tty.Close()
+	} else {
+		want = "pipe\n"
+	}
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestApplyDocs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.md")
+	docs := "# Targets\n\n- `build`: Compile everything\n* test: Run the tests\nSome prose: not a target\n"
+	if err := os.WriteFile(path, []byte(docs), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	options := []MakeOption{{Target: "build"}, {Target: "test", Comment: "Inline wins"}, {Target: "lint"}}
+	if err := applyDocs(options, path, true); err != nil {
+		t.Fatal(err)
+	}
+	want := []MakeOption{{Target: "build", Comment: "Compile everything"}, {Target: "test", Comment: "Inline wins"}, {Target: "lint"}}
+	if !reflect.DeepEqual(options, want) {
+		t.Errorf("applyDocs = %+v, want %+v", options, want)
+	}
+	if err := applyDocs(options, path+".missing", false); err != nil {
+		t.Errorf("optional missing docs file: %v", err)
+	}
+	if err := applyDocs(options, path+".missing", true); err == nil {
+		t.Error("required missing docs file was not an error")
+	}
+}
+
+func TestParseMakefilePhony(t *testing.T) {
+	path := writeMakefile(t, ".PHONY: test clean # actions\nbuild: main.o\n\tcc -o build main.o\ntest: build\n\t./build --test\n.PHONY: lint\nlint:\n")
+	got, err := parseMakefile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	phony := make(map[string]bool)
+	for _, opt := range got {
+		phony[opt.Target] = opt.IsPhony
+	}
+	if want := map[string]bool{"build": false, "test": true, "lint": true}; !reflect.DeepEqual(phony, want) {
+		t.Errorf("phony targets = %v, want %v", phony, want)
+	}
+}
+
+func TestTabIcons(t *testing.T) {
+	t.Setenv("TERM", "linux")
+	if icons := tabIcons("auto", nil, true); icons != nil {
+		t.Errorf("auto icons on the Linux console = %v, want none", icons)
+	}
+	icons := tabIcons("auto", map[string]string{"Docs": "📚", "Apps": "A"}, false)
+	for name, want := range map[string]string{"Docs": "📚 Docs", "Apps": "A Apps", "Library": "📦 Library", "Other": "Other"} {
+		if got := tabLabel(name, icons); got != want {
+			t.Errorf("tabLabel(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestAddRecent(t *testing.T) {
+	s := &State{}
+	for i := 0; i < maxRecent+2; i++ {
+		s.addRecent(fmt.Sprintf("/p%d", i))
+	}
+	s.addRecent("/p5")
+	if len(s.Recent) != maxRecent || s.Recent[0] != "/p5" || s.Recent[1] != fmt.Sprintf("/p%d", maxRecent+1) {
+		t.Errorf("Recent = %v", s.Recent)
+	}
+	if strings.Count(strings.Join(s.Recent, " "), "/p5 ") > 1 {
+		t.Errorf("Recent lists /p5 twice: %v", s.Recent)
+	}
+}
+
+func TestGUIThemeAndWindowSize(t *testing.T) {
+	dark, err := newGUITheme("dark", 1.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := theme.DefaultTheme().Color(theme.ColorNameBackground, theme.VariantDark)
+	if got := dark.Color(theme.ColorNameBackground, theme.VariantLight); got != want {
+		t.Errorf("dark theme background under a light system = %v, want the dark %v", got, want)
+	}
+	if got, want := dark.Size(theme.SizeNameText), theme.DefaultTheme().Size(theme.SizeNameText)*1.5; got != want {
+		t.Errorf("text size at scale 1.5 = %g, want %g", got, want)
+	}
+	contrast, err := newGUITheme("high-contrast", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contrast.Color(theme.ColorNameBackground, theme.VariantLight); got != color.Black {
+		t.Errorf("high-contrast background = %v, want black", got)
+	}
+	if _, err := newGUITheme("sepia", 1); err == nil {
+		t.Error("newGUITheme accepted an unknown theme")
+	}
+	if _, err := newGUITheme("", 0); err == nil {
+		t.Error("newGUITheme accepted a zero font scale")
+	}
+
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	s := loadState()
+	s.setWindowSize(800, 600)
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+	if s := loadState(); s.WindowWidth != 800 || s.WindowHeight != 600 {
+		t.Errorf("window size after reloading the state = %gx%g, want 800x600", s.WindowWidth, s.WindowHeight)
+	}
+}
+
+func TestStateConcurrentSaves(t *testing.T) {
+	s := &State{path: filepath.Join(t.TempDir(), "coolbox", "state.json")}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.countRun("Makefile", "build")
+			s.setOutputSplit(10 * i)
+			s.save()
+		}(i)
+	}
+	wg.Wait()
+	s.setOutputPinned(true)
+	if err := s.save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved State
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("state file %q does not parse: %v", data, err)
+	}
+	if got := saved.RunCounts[stateKey("Makefile")]["build"]; got != 8 || !saved.OutputPinned {
+		t.Errorf("saved %d runs and pinned %v, want 8 and true", got, saved.OutputPinned)
+	}
+}
+
+func TestRunStdinFile(t *testing.T) {
+	makefile := writeMakefile(t, "")
+	dir := filepath.Dir(makefile)
+	if err := os.WriteFile(filepath.Join(dir, "input.txt"), []byte("from the file\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := openStdinFile("input.txt", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	r := &Runner{Makefile: makefile}
+	var out bytes.Buffer
+	if err := r.RunShell("cat", Stdio{Stdin: file, Stdout: &out}); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "from the file\n" {
+		t.Errorf("output = %q, want the file's content", out.String())
+	}
+	if _, err := openStdinFile(".", dir); err == nil {
+		t.Error("openStdinFile accepted a directory")
+	}
+}
+
+func TestCountTargets(t *testing.T) {
+	tabs := []Tab{
+		{Name: "All", Options: []MakeOption{{Target: "build"}, {Target: "helper", Internal: true}}},
+		{Name: "Apps", Options: []MakeOption{{Target: "build"}}},
+	}
+	if n := countTargets(tabs); n != 1 {
+		t.Errorf("countTargets = %d, want 1", n)
+	}
+	if n := countTargets([]Tab{{Name: "All"}}); n != 0 {
+		t.Errorf("countTargets of empty tabs = %d, want 0", n)
+	}
+}
+
+// TestRunWithoutTargets runs main in a child process: -run on a build file
+// that lists no targets must fail rather than exit 0 having done nothing.
+func TestRunWithoutTargets(t *testing.T) {
+	if path := os.Getenv("COOLBOX_TEST_RUN_MAKEFILE"); path != "" {
+		os.Args = []string{"coolbox", "-f", path, "-run", "build"}
+		main()
+		return
+	}
+	path := writeMakefile(t, "VERSION = 1\n")
+	config := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRunWithoutTargets$")
+	cmd.Env = append(os.Environ(), "COOLBOX_TEST_RUN_MAKEFILE="+path, "HOME="+config, "XDG_CONFIG_HOME="+config)
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("-run without targets = %v, want exit status 1; output:\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "No targets found in "+path) {
+		t.Errorf("output %q does not say there are no targets", out)
+	}
+}
+
+// TestDefaultMakeFlags runs main in a child process: the project config's
+// makeFlags reach make unless -no-default-flags is given.
+func TestDefaultMakeFlags(t *testing.T) {
+	if path := os.Getenv("COOLBOX_TEST_FLAGS_MAKEFILE"); path != "" {
+		os.Args = []string{"coolbox", "-f", path, "-run", "build"}
+		if os.Getenv("COOLBOX_TEST_NO_DEFAULT_FLAGS") != "" {
+			os.Args = append(os.Args, "-no-default-flags")
+		}
+		main()
+		return
+	}
+	path := writeMakefile(t, "FLAVOR ?= plain\nbuild:\n\t@echo flavor=$(FLAVOR)\n")
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), configFileName), []byte("makeFlags: [FLAVOR=spicy]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := t.TempDir()
+	for _, tt := range []struct{ noDefault, want string }{{"", "flavor=spicy"}, {"1", "flavor=plain"}} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestDefaultMakeFlags$")
+		cmd.Env = append(os.Environ(), "COOLBOX_TEST_FLAGS_MAKEFILE="+path, "COOLBOX_TEST_NO_DEFAULT_FLAGS="+tt.noDefault, "HOME="+config, "XDG_CONFIG_HOME="+config)
+		out, err := cmd.CombinedOutput()
+		if err != nil || !strings.Contains(string(out), tt.want) {
+			t.Errorf("-no-default-flags=%v: %v, output %q, want %s", tt.noDefault != "", err, out, tt.want)
+		}
+	}
+}
+
+func TestRunFrontends(t *testing.T) {
+	var log []string
+	frontend := func(name string, next *handover) func([]Tab, uiOptions) *handover {
+		return func(tabs []Tab, opts uiOptions) *handover {
+			log = append(log, fmt.Sprintf("%s tab=%d tabs=%d guiStarted=%v notice=%q", name, opts.StartTab, len(tabs), opts.GUIStarted, opts.Notice))
+			return next
+		}
+	}
+	reloaded := []Tab{{Name: "All"}, {Name: "Apps"}, {Name: "Docs"}}
+	guiRuns := 0
+	frontends := map[string]func([]Tab, uiOptions) *handover{
+		"tview": frontend("tview", &handover{Frontend: "gui", Tabs: reloaded, Tab: 2}),
+		"gui": func(tabs []Tab, opts uiOptions) *handover {
+			guiRuns++
+			return frontend("gui", &handover{Frontend: "last", Tabs: tabs, Tab: 1})(tabs, opts)
+		},
+		"last": frontend("last", nil),
+	}
+	err := runFrontends("tview", []Tab{{Name: "All"}}, uiOptions{Notice: "No targets"}, frontends)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`tview tab=0 tabs=1 guiStarted=false notice="No targets"`,
+		`gui tab=2 tabs=3 guiStarted=false notice=""`,
+		`last tab=1 tabs=3 guiStarted=true notice=""`,
+	}
+	if !reflect.DeepEqual(log, want) || guiRuns != 1 {
+		t.Errorf("front-ends ran as\n%s\nwant\n%s", strings.Join(log, "\n"), strings.Join(want, "\n"))
+	}
+	if err := runFrontends("web", nil, uiOptions{}, frontends); err == nil || !strings.Contains(err.Error(), "unknown front-end web") {
+		t.Errorf("runFrontends(web) = %v, want an unknown front-end error", err)
+	}
+}
+
+// TestAutoRunRefused runs main in child processes: -autorun must exit 1
+// rather than open a front-end that cannot or may not run the target.
+func TestAutoRunRefused(t *testing.T) {
+	if args := os.Getenv("COOLBOX_TEST_AUTORUN_ARGS"); args != "" {
+		os.Args = append([]string{"coolbox"}, strings.Fields(args)...)
+		main()
+		return
+	}
+	path := writeMakefile(t, "build:\n\t@true\ndeploy:\n\t@true\n")
+	empty := writeMakefile(t, "VERSION = 1\n")
+	config := t.TempDir()
+	tests := []struct {
+		args, want string
+		tty        bool // without a terminal every front-end is refused
+	}{
+		{"-f " + path + " -frontend bubbletea -autorun build", "-autorun needs the tview front-end", true},
+		{"-f " + path + " -frontend tview -exclude deploy -autorun deploy", "deploy is excluded by -only or -exclude", false},
+		{"-f " + empty + " -frontend tview -autorun build", "No targets found in " + empty, false},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestAutoRunRefused$")
+		cmd.Env = append(os.Environ(), "COOLBOX_TEST_AUTORUN_ARGS="+tt.args, "HOME="+config, "XDG_CONFIG_HOME="+config)
+		var out bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &out, &out
+		wait := cmd.Run
+		if tt.tty {
+			pair, ok := (&Runner{PTY: true}).openPTY()
+			if !ok {
+				t.Logf("skipping %q: no pseudo-terminal", tt.args)
+				continue
+			}
+			w, err := startOnPTY(cmd, pair)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wait = w
+		}
+		err := wait()
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 || !strings.Contains(out.String(), tt.want) {
+			t.Errorf("coolbox %s = %v, output %q, want exit status 1 and %q", tt.args, err, out.String(), tt.want)
+		}
+	}
+
+	// The run is started once, by the front-end opened first.
+	var autoRuns [][]string
+	record := func(next *handover) func([]Tab, uiOptions) *handover {
+		return func(tabs []Tab, opts uiOptions) *handover {
+			autoRuns = append(autoRuns, opts.AutoRun)
+			return next
+		}
+	}
+	frontends := map[string]func([]Tab, uiOptions) *handover{
+		"tview": record(&handover{Frontend: "gui"}),
+		"gui":   record(nil),
+	}
+	if err := runFrontends("tview", nil, uiOptions{AutoRun: []string{"build"}}, frontends); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(autoRuns, [][]string{{"build"}, nil}) {
+		t.Errorf("AutoRun per front-end = %q, want build for the first only", autoRuns)
+	}
+}
+
+func TestLoadCIResults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ci.json")
+	if err := os.WriteFile(path, []byte(`{"build": "success", "test": "FAILED", "lint": "skipped"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	results, err := loadCIResults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		opt  MakeOption
+		want string
+	}{
+		{MakeOption{Target: "build"}, "✓ "},
+		{MakeOption{Target: "t", AliasOf: "test"}, "✗ "},
+		{MakeOption{Target: "lint"}, ""},
+		{MakeOption{Target: "not-in-the-file"}, ""},
+	}
+	for _, tt := range tests {
+		if got := ciMarker(results, tt.opt); got != tt.want {
+			t.Errorf("ciMarker(%s) = %q, want %q", tt.opt.Target, got, tt.want)
+		}
+	}
+}
+
+func TestTabItemsRestore(t *testing.T) {
+	items := tabItems{"All": 3, "Apps": 5}
+	if got := items.restore("All", 10); got != 3 {
+		t.Errorf("restore(All) = %d, want the remembered 3", got)
+	}
+	if got := items.restore("Apps", 4); got != 3 {
+		t.Errorf("restore(Apps) after the tab shrank to 4 items = %d, want the last, 3", got)
+	}
+	if got := items.restore("Docs", 2); got != 0 {
+		t.Errorf("restore of a tab never left = %d, want 0", got)
+	}
+}
+
+func TestFormatLabel(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment string
+		width   int
+		want    string
+	}{
+		{"fits", "Compile it", 0, "build - Compile it"},
+		{"exact fit", "Compile it", 18, "build - Compile it"},
+		{"word boundary", "Compile the whole tree", 24, "build - Compile the…"},
+		{"too narrow", "Compile the whole tree", 12, "build"},
+		{"mid word", "Compilation", 18, "build - Compilati…"},
+		{"wide exact fit", "ビルドする", 18, "build - ビルドする"},
+		{"wide word boundary", "ビルド する", 17, "build - ビルド…"},
+		{"wide mid word", "ビルドする", 17, "build - ビルドす…"},
+		{"emoji", "🚀 ship it now", 18, "build - 🚀 ship…"},
+	}
+	for _, tt := range tests {
+		got := formatLabel(MakeOption{Target: "build", Comment: tt.comment}, tt.width)
+		if got != tt.want {
+			t.Errorf("%s: formatLabel = %q, want %q", tt.name, got, tt.want)
+		}
+		if tt.width > 0 && runewidth.StringWidth(got) > tt.width {
+			t.Errorf("%s: %q is %d columns wide, more than %d", tt.name, got, runewidth.StringWidth(got), tt.width)
+		}
+	}
+}
+
+func TestTabBarWindow(t *testing.T) {
+	widths := []int{3, 4, 8, 7, 4} // All, Apps, Services, Library, Demo
+	tests := []struct {
+		current, width int
+		start, end     int
+	}{
+		{0, 0, 0, 5},
+		{0, 100, 0, 5},
+		{0, 12, 0, 2}, // "All Apps " and the right arrow
+		{4, 16, 3, 5}, // left arrow, "Library Demo "
+		{2, 12, 2, 3}, // only the current tab fits between the arrows
+	}
+	for _, tt := range tests {
+		start, end := tabBarWindow(widths, tt.current, tt.width)
+		if start != tt.start || end != tt.end {
+			t.Errorf("tabBarWindow(current %d, width %d) = %d, %d, want %d, %d", tt.current, tt.width, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestTailFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	offset := fileSize(path)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("started\n")
+	f.Close()
+
+	stop := make(chan struct{})
+	close(stop)
+	var out bytes.Buffer
+	tailFile(path, offset, &out, stop)
+	if out.String() != "started\n" {
+		t.Errorf("tailed %q, want only the appended line", out.String())
+	}
+
+	// A log rotated to a shorter file is read from the start.
+	if got := copyFrom(path, 100, &out); got != int64(len("old\nstarted\n")) {
+		t.Errorf("offset after rotation = %d", got)
+	}
+}
+
+func TestRunnerTailCancel(t *testing.T) {
+	makefile := writeMakefile(t, "")
+	r := &Runner{Makefile: makefile, Command: []string{"echo"}, Targets: map[string]TargetConfig{"up": {Tail: "up.log"}}}
+	done := make(chan error)
+	var out safeBuffer
+	go func() { done <- r.Run("up", Stdio{Stdout: &out}) }()
+	for !strings.Contains(out.String(), "Tailing ") {
+		time.Sleep(10 * time.Millisecond)
+	}
+	r.Cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// safeBuffer is a bytes.Buffer that output can be written to while a test
+// reads it.
+type safeBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestTargetFilter(t *testing.T) {
+	options := []MakeOption{{Target: "build"}, {Target: "deploy-prod"}, {Target: "deploy-staging"}, {Target: "test"}}
+	names := func(opts []MakeOption) []string {
+		var out []string
+		for _, opt := range opts {
+			out = append(out, opt.Target)
+		}
+		return out
+	}
+	tests := []struct {
+		only, exclude []string
+		syntax        string
+		want          []string
+	}{
+		{nil, nil, "glob", []string{"build", "deploy-prod", "deploy-staging", "test"}},
+		{[]string{"deploy-*", "test"}, nil, "glob", []string{"deploy-prod", "deploy-staging", "test"}},
+		{[]string{"deploy-*"}, []string{"*-prod"}, "glob", []string{"deploy-staging"}},
+		{nil, []string{"^deploy"}, "regex", []string{"build", "test"}},
+	}
+	for _, tt := range tests {
+		f, err := newTargetFilter(tt.only, tt.exclude, tt.syntax)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := names(f.apply(options)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("only %q exclude %q (%s) = %q, want %q", tt.only, tt.exclude, tt.syntax, got, tt.want)
+		}
+	}
+
+	if _, err := newTargetFilter([]string{"["}, nil, "glob"); err == nil {
+		t.Error("bad glob accepted")
+	}
+	if _, err := newTargetFilter(nil, nil, "regexp"); err == nil {
+		t.Error("unknown pattern syntax accepted")
+	}
+
+	f, _ := newTargetFilter(nil, []string{"deploy-*"}, "glob")
+	cfg := &Config{
+		Aliases:   map[string]string{"b": "build", "dp": "deploy-prod"},
+		Sequences: map[string][]string{"ci": {"b", "test"}, "release": {"build", "dp"}},
+	}
+	f.prune(cfg)
+	if _, ok := cfg.Aliases["dp"]; ok || cfg.Aliases["b"] != "build" {
+		t.Errorf("aliases after prune = %v", cfg.Aliases)
+	}
+	if _, ok := cfg.Sequences["release"]; ok || cfg.Sequences["ci"] == nil {
+		t.Errorf("sequences after prune = %v", cfg.Sequences)
+	}
+}
+
+func TestLoadConfigMerge(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user.yaml")
+	project := filepath.Join(dir, "project.yaml")
+	os.WriteFile(user, []byte("aliases: {b: build, t: test}\nmakeFlags: [-j4]\ngui: {theme: dark, fontScale: 1.5}\n"), 0o644)
+	os.WriteFile(project, []byte("aliases: {t: test-all}\nmakeFlags: [-s]\ngui: {theme: light}\n"), 0o644)
+	cfg, err := loadConfig(user, project, filepath.Join(dir, "missing.yaml"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"b": "build", "t": "test-all"}; !reflect.DeepEqual(cfg.Aliases, want) {
+		t.Errorf("aliases = %v, want %v", cfg.Aliases, want)
+	}
+	if !reflect.DeepEqual(cfg.MakeFlags, []string{"-s"}) {
+		t.Errorf("makeFlags = %q, want the project's", cfg.MakeFlags)
+	}
+	if cfg.GUI != (GUIConfig{Theme: "light", FontScale: 1.5}) {
+		t.Errorf("gui = %+v, want the project's theme and the user's font scale", cfg.GUI)
+	}
+}
+
+func TestLoadConfigsUserEntries(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user.yaml")
+	if err := os.WriteFile(user, []byte("aliases: {b: build, t: test}\nsequences: {ship: [b, deploy]}\nworkflows: {ci: [t]}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := writeMakefile(t, "test:\n\t@echo testing\n")
+	project := filepath.Join(filepath.Dir(path), configFileName)
+	if err := os.WriteFile(project, []byte("aliases: {tt: test}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfigs(user, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The user's b alias and ship sequence name targets this project
+	// lacks, so they are left out instead of failing the project.
+	tabs, err := loadTabs(parseMakefile, path, cfg, "", "")
+	if err != nil {
+		t.Fatalf("loadTabs with a user alias to a missing target: %v", err)
+	}
+	listed := make(map[string]bool)
+	for _, tab := range tabs {
+		for _, opt := range tab.Options {
+			listed[opt.Target] = true
+		}
+	}
+	var names []string
+	for name := range listed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if want := []string{"ci", "t", "test", "tt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("listed %q, want %q", names, want)
+	}
+	if _, ok := cfg.Aliases["b"]; !ok {
+		t.Error("the loaded config lost the user's b alias, which a reload may need")
+	}
+
+	// The project's own entries are still checked.
+	if err := os.WriteFile(project, []byte("aliases: {d: deploy}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = loadConfigs(user, project); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadTabs(parseMakefile, path, cfg, "", ""); err == nil {
+		t.Error("loadTabs accepted a project alias to a missing target")
+	}
+}
+
+func TestReloadTabs(t *testing.T) {
+	path := writeMakefile(t, "build-app:\n\t@true\ntest:\n\t@true\n")
+	cacheFile := filepath.Join(t.TempDir(), "cache.gob")
+	parse := func(path string) ([]MakeOption, error) {
+		return parseCached(buildTools[0], path, cacheFile, makefile.Options{})
+	}
+	before, err := loadTabs(parse, path, &Config{}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("build-app:\n\t@true\n# Ship it\ndeploy-service:\n\t@true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	after, err := loadTabs(parse, path, &Config{}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := targetDiff(before, after), "+1 target (deploy-service), -1 (test)"; got != want {
+		t.Errorf("reload diff = %q, want %q", got, want)
+	}
+	if i, err := findTab(after, "Apps"); err != nil || after[i].Options[0].Target != "build-app" {
+		t.Errorf("Apps tab after reload = %v, want it kept with build-app", err)
+	}
+	if i, err := findTab(after, "Services"); err != nil || after[i].Options[0].Comment != "Ship it" {
+		t.Errorf("Services tab after reload = %v, want the new deploy-service", err)
+	}
+}
+
+func TestTargetDiff(t *testing.T) {
+	tabs := func(names ...string) []Tab {
+		var opts []MakeOption
+		for _, name := range names {
+			opts = append(opts, MakeOption{Target: name})
+		}
+		return []Tab{{Name: "All", Options: opts}, {Name: "Other", Options: opts[:1]}}
+	}
+	tests := []struct {
+		before, after []Tab
+		want          string
+	}{
+		{tabs("build", "test"), tabs("build", "test"), ""},
+		{tabs("build", "old-build"), tabs("build", "rollback", "deploy-canary"), "+2 targets (deploy-canary, rollback), -1 (old-build)"},
+		{tabs("build", "lint"), tabs("build"), "-1 target (lint)"},
+	}
+	for _, tt := range tests {
+		if got := targetDiff(tt.before, tt.after); got != tt.want {
+			t.Errorf("targetDiff = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestRunnerJobs(t *testing.T) {
+	r := &Runner{Makefile: writeMakefile(t, ""), Command: []string{"make"}, Flags: []string{"-s"}, Jobs: 4}
+	if got, want := r.CommandLine("build"), []string{"make", "-s", "-j4", "build"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CommandLine = %q, want %q", got, want)
+	}
+	r.Command = []string{"task"}
+	if got, want := r.CommandLine("build"), []string{"task", "-s", "--concurrency", "4", "build"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CommandLine = %q, want %q", got, want)
+	}
+	for _, command := range [][]string{{"just"}, {"npm", "run"}, {"nmake"}} {
+		r.Command = command
+		if jobs := r.jobFlags(); jobs != nil {
+			t.Errorf("jobFlags for %s = %q, want none", command[0], jobs)
+		}
+	}
+
+	// A launcher config takes no jobs, also for a target it does not list.
+	r.Command = []string{"make"}
+	r.Launch = map[string]LauncherEntry{"serve": {Label: "serve", Command: "echo serving"}}
+	if jobs, line := r.jobFlags(), r.CommandLine("build"); jobs != nil || !reflect.DeepEqual(line, []string{"make", "-s", "build"}) {
+		t.Errorf("with a launcher config, jobFlags = %q and CommandLine = %q, want no jobs", jobs, line)
+	}
+}
+
+func TestRunnerAlwaysMake(t *testing.T) {
+	r := &Runner{Makefile: writeMakefile(t, ""), Command: []string{"make"}, Jobs: 2, AlwaysMake: true}
+	if got, want := r.CommandLine("build"), []string{"make", "-j2", "-B", "build"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CommandLine = %q, want %q", got, want)
+	}
+	for _, command := range [][]string{{"task"}, {"nmake"}} {
+		r.Command = command
+		if flags := r.alwaysMakeFlags(); flags != nil {
+			t.Errorf("alwaysMakeFlags for %s = %q, want none", command[0], flags)
+		}
+	}
+
+	// Only runs that start the build tool count, for the one-shot -B.
+	r = &Runner{Makefile: writeMakefile(t, "build:\n\t@true\n")}
+	r.SetRequires(map[string][]string{"build": {"COOLBOX_TEST_UNSET"}})
+	r.Run("build", Stdio{})
+	if got := r.Started(); got != 0 {
+		t.Errorf("Started after a refused run = %d, want 0", got)
+	}
+	r.SetRequires(nil)
+	if err := r.Run("build", Stdio{}); err != nil || r.Started() != 1 {
+		t.Errorf("Run = %v, Started = %d, want 1", err, r.Started())
+	}
+}
+
+func TestAccessibleTeaView(t *testing.T) {
+	r := &Runner{Makefile: writeMakefile(t, ""), Outcomes: &Outcomes{}}
+	r.Outcomes.Record("build", nil)
+	r.Outcomes.Record("test", errors.New("exit status 1"))
+	tabs := []Tab{{Name: "All", Options: []MakeOption{{Target: "build"}, {Target: "test"}}}, {Name: "Other"}}
+	view := teaModel{tabs: tabs, runner: r, accessible: true}.View()
+	for _, want := range []string{"[All]", "(ok) ", "(failed) "} {
+		if !strings.Contains(view, want) {
+			t.Errorf("accessible view lacks %q:\n%s", want, view)
+		}
+	}
+	if view := (teaModel{tabs: tabs, runner: r}).View(); strings.Contains(view, "(ok) ") || strings.Contains(view, "[All]") {
+		t.Errorf("default view has accessible cues:\n%s", view)
+	}
+}
+
+// teaKeys sends each of keys to m as a key press, "enter" and the like by
+// name and anything else as typed runes.
+func teaKeys(m teaModel, keys ...string) (teaModel, tea.Cmd) {
+	named := map[string]tea.KeyType{"enter": tea.KeyEnter, "esc": tea.KeyEsc, "left": tea.KeyLeft, "right": tea.KeyRight, "up": tea.KeyUp, "down": tea.KeyDown, "tab": tea.KeyTab, "backspace": tea.KeyBackspace}
+	var cmd tea.Cmd
+	for _, key := range keys {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		if typ, ok := named[key]; ok {
+			msg = tea.KeyMsg{Type: typ}
+		}
+		var model tea.Model
+		model, cmd = m.Update(msg)
+		m = model.(teaModel)
+	}
+	return m, cmd
+}
+
+func TestTeaModelNavigation(t *testing.T) {
+	r := &Runner{Makefile: writeMakefile(t, ""), Outcomes: &Outcomes{}}
+	tabs := []Tab{
+		{Name: "All", Options: []MakeOption{{Target: "build", Comment: "Compile"}, {Target: "test"}, {Target: "_gen", Internal: true}}},
+		{Name: "Docs", Options: []MakeOption{{Target: "docs"}}},
+	}
+	m := teaModel{tabs: tabs, runner: r}
+	m, _ = teaKeys(m, "down", "down")
+	if m.cursor != 1 || m.current().Target != "test" {
+		t.Errorf("after two downs the cursor is on %q, want test, the last shown", m.current().Target)
+	}
+	m, _ = teaKeys(m, ".")
+	if len(m.visible()) != 3 {
+		t.Errorf("with internal targets shown, %d are listed, want 3", len(m.visible()))
+	}
+	m, _ = teaKeys(m, "right")
+	if m.tab != 1 || m.cursor != 0 || m.current().Target != "docs" {
+		t.Errorf("after right, tab %d cursor %d, want the Docs tab from the top", m.tab, m.cursor)
+	}
+	m, _ = teaKeys(m, "right", "left", "up")
+	if m.tab != 0 || m.cursor != 0 {
+		t.Errorf("after right past the end, left and up: tab %d cursor %d, want 0 and 0", m.tab, m.cursor)
+	}
+	if view := m.View(); !strings.Contains(view, teaCurrentTab.Styled("All")) || !strings.Contains(view, "> build - Compile") {
+		t.Errorf("view does not show the All tab current and build highlighted:\n%s", view)
+	}
+}
+
+func TestTeaModelFilter(t *testing.T) {
+	r := &Runner{Makefile: writeMakefile(t, ""), Outcomes: &Outcomes{}}
+	tabs := []Tab{{Name: "All", Options: []MakeOption{
+		{Target: "build", Comment: "Compile the app"},
+		{Target: "test", Comment: "Run the tests", Deps: []string{"build"}},
+		{Target: "lint"},
+	}}}
+	m, _ := teaKeys(teaModel{tabs: tabs, runner: r}, "/", "t", "e", "s")
+	if !m.searching || m.query != "tes" {
+		t.Fatalf("searching %v with query %q, want tes", m.searching, m.query)
+	}
+	if view := m.View(); !strings.Contains(view, "/tes█") || strings.Contains(view, "lint") {
+		t.Errorf("search view:\n%s", view)
+	}
+	m, _ = teaKeys(m, "backspace", "enter")
+	if m.searching || len(m.visible()) != 1 || m.current().Target != "test" {
+		t.Errorf("after backspace and enter, searching %v and %d shown, want test alone", m.searching, len(m.visible()))
+	}
+	if view := m.View(); !strings.Contains(view, "filter /te (esc to clear)") {
+		t.Errorf("filtered view lacks the filter line:\n%s", view)
+	}
+	m, _ = teaKeys(m, "esc", "d", "b", "u", "i", "l", "d", "enter")
+	if len(m.visible()) != 1 || m.current().Target != "test" {
+		t.Errorf("by prerequisite build, %d shown, want test alone", len(m.visible()))
+	}
+	m, _ = teaKeys(m, "esc")
+	if m.query != "" || len(m.visible()) != 3 {
+		t.Errorf("after esc, query %q and %d shown, want every target", m.query, len(m.visible()))
+	}
+}
+
+func TestTeaModelRun(t *testing.T) {
+	r := &Runner{Makefile: writeMakefile(t, "build:\n\t@true\n"), Outcomes: &Outcomes{}}
+	tabs := []Tab{{Name: "All", Options: []MakeOption{{Target: "build"}, {Target: "deploy", Confirm: true}}}}
+	m, cmd := teaKeys(teaModel{tabs: tabs, runner: r}, "enter")
+	if cmd == nil || m.pending != "" {
+		t.Fatalf("enter on build gave command %v, pending %q, want the run started", cmd, m.pending)
+	}
+
+	// A target marked Confirm shows its preview first.
+	m, cmd = teaKeys(m, "down", "enter")
+	if cmd != nil || m.pending != "deploy" || !strings.Contains(m.View(), "Run deploy?") {
+		t.Fatalf("enter on deploy: pending %q, view:\n%s", m.pending, m.View())
+	}
+	m, cmd = teaKeys(m, "n")
+	if cmd != nil || m.pending != "" {
+		t.Errorf("declining the preview gave command %v, pending %q", cmd, m.pending)
+	}
+	m, _ = teaKeys(m, "enter")
+	if m, cmd = teaKeys(m, "y"); cmd == nil {
+		t.Error("accepting the preview did not start the run")
+	}
+
+	// The finished run is reported in the footer.
+	model, _ := m.Update(teaRunDoneMsg{target: "deploy", err: errors.New("exit status 2")})
+	m = model.(teaModel)
+	if want := r.Program() + " deploy exited 1"; !strings.Contains(m.View(), want) {
+		t.Errorf("view lacks %q:\n%s", want, m.View())
+	}
+}
+
+func TestTeaModelRequires(t *testing.T) {
+	r := &Runner{Makefile: writeMakefile(t, "# @requires ENV\ndeploy:\n\t@true\n"), Outcomes: &Outcomes{}}
+	r.SetRequires(map[string][]string{"deploy": {"ENV"}})
+	t.Setenv("ENV", "")
+	tabs := []Tab{{Name: "All", Options: []MakeOption{{Target: "deploy"}}}}
+	m, cmd := teaKeys(teaModel{tabs: tabs, runner: r}, "enter")
+	if cmd != nil || m.asking.Target != "deploy" || m.answer != "ENV=" || !strings.Contains(m.View(), "Run deploy: set ENV") {
+		t.Fatalf("enter on deploy gave command %v, answer %q, view:\n%s", cmd, m.answer, m.View())
+	}
+	m, cmd = teaKeys(m, "esc")
+	if cmd != nil || m.asking.Target != "" {
+		t.Errorf("esc gave command %v, still asking for %q", cmd, m.asking.Target)
+	}
+
+	// An unterminated quote is reported and keeps the prompt open.
+	m, _ = teaKeys(m, "enter", "'", "enter")
+	if m.asking.Target != "deploy" || !strings.Contains(m.View(), "Could not read the arguments") {
+		t.Errorf("bad arguments: asking for %q, view:\n%s", m.asking.Target, m.View())
+	}
+	m, _ = teaKeys(m, "backspace", "dev")
+	if m, cmd = teaKeys(m, "enter"); cmd == nil || m.asking.Target != "" {
+		t.Errorf("ENV=dev gave command %v, asking for %q, want the run started", cmd, m.asking.Target)
+	}
+}
+
+func TestMarkdownTags(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Run `make build` **first**", "Run [yellow]make build[-] [::b]first[::-]"},
+		{"Steps:\n- lint\n  * vet the `[red]` tags", "Steps:\n  • lint\n    • vet the [yellow][red[][-] tags"},
+		{"2 ** 3, a `lone tick and ** spaced **", "2 ** 3, a `lone tick and ** spaced **"},
+		{"[white] stays text", "[white[] stays text"},
+	}
+	for _, tt := range tests {
+		if got := markdownTags(tt.in); got != tt.want {
+			t.Errorf("markdownTags(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	r := &Runner{Makefile: writeMakefile(t, ""), Command: []string{"echo"}, History: &History{}}
+	var out bytes.Buffer
+	r.Run("build", Stdio{Stdout: &out, Stderr: &out})
+	r.Run("<deploy>", terminalStdio)
+	runs := r.History.Runs()
+	if len(runs) != 2 || string(runs[0].Output) != "build\n" || runs[1].Output != nil {
+		t.Fatalf("history = %+v", runs)
+	}
+
+	var page bytes.Buffer
+	if err := writeReport(&page, r.Dir(), runs); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<h2>build</h2>", "<dd>echo build</dd>", "<pre>build\n</pre>", "&lt;deploy&gt;", "output not captured"} {
+		if !strings.Contains(page.String(), want) {
+			t.Errorf("report lacks %q", want)
+		}
+	}
+}
+
+func TestANSIHTML(t *testing.T) {
+	in := "\x1b[1;31mFAIL\x1b[0m <x>\n10%\r50%\r100%\n\x1b[38;5;208mhi\x1b[m\x1b[2K"
+	want := `<span class="b c31">FAIL</span> &lt;x&gt;` + "\n100%\nhi"
+	if got := ansiHTML([]byte(in)); got != want {
+		t.Errorf("ansiHTML = %q, want %q", got, want)
+	}
+}
+
+func TestEnvMakefile(t *testing.T) {
+	dir := t.TempDir()
+	touch := func(names ...string) {
+		for _, name := range names {
+			if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	base := filepath.Join(dir, "Makefile")
+	touch("Makefile", "build.mk", "build.mk.prod", "makefile.dev", "GNUmakefile.dev", "Makefile.dev", "Makefile.prod")
+	os.Mkdir(filepath.Join(dir, "Makefile.ci"), 0o755)
+	tests := []struct {
+		base, env string
+		want      string
+		found     bool
+	}{
+		{base, "prod", "Makefile.prod", true},
+		{base, "dev", "Makefile.dev", true},
+		{filepath.Join(dir, "GNUmakefile"), "dev", "GNUmakefile.dev", true},
+		{filepath.Join(dir, "build.mk"), "prod", "build.mk.prod", true},
+		{filepath.Join(dir, "build.mk"), "dev", "build.mk", false},
+		{base, "staging", "Makefile", false},
+		{base, "ci", "Makefile", false}, // a directory is not a Makefile
+	}
+	for _, tt := range tests {
+		got, found := envMakefile(tt.base, tt.env)
+		if filepath.Base(got) != tt.want || found != tt.found {
+			t.Errorf("envMakefile(%s, %s) = %s, %v, want %s, %v", filepath.Base(tt.base), tt.env, filepath.Base(got), found, tt.want, tt.found)
+		}
+	}
+
+	// Without base.env, make's names are tried in make's order.
+	os.Remove(filepath.Join(dir, "Makefile.dev"))
+	if got, _ := envMakefile(base, "dev"); filepath.Base(got) != "GNUmakefile.dev" {
+		t.Errorf("envMakefile(Makefile, dev) = %s, want GNUmakefile.dev", filepath.Base(got))
+	}
+}
+
+func TestReverseDeps(t *testing.T) {
+	tabs := []Tab{
+		{Name: "Build", Options: []MakeOption{
+			{Target: "generate"},
+			{Target: "build", Deps: []string{"generate", "build"}},
+			{Target: "test", Deps: []string{"build"}},
+		}},
+		{Name: "Release", Options: []MakeOption{
+			{Target: "release", Deps: []string{"test", "docs"}},
+			{Target: "build", Deps: []string{"generate"}}, // listed twice
+			{Target: "ship", AliasOf: "release", Deps: []string{"generate"}},
+		}},
+	}
+	deps := reverseDeps(tabs)
+	tests := []struct {
+		opt  MakeOption
+		want []string
+		desc string
+	}{
+		{MakeOption{Target: "generate"}, []string{"build", "release", "test"}, "Needed by: 3 targets (build, release, test)"},
+		{MakeOption{Target: "test"}, []string{"release"}, "Needed by: 1 target (release)"},
+		{MakeOption{Target: "docs"}, []string{"release"}, "Needed by: 1 target (release)"},
+		{MakeOption{Target: "release"}, nil, "Needed by: nothing (leaf target)"},
+		{MakeOption{Target: "ship", AliasOf: "test"}, []string{"release"}, "Needed by: 1 target (release)"},
+	}
+	for _, tt := range tests {
+		if got := deps.impactOf(tt.opt); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("impactOf(%s) = %q, want %q", tt.opt.Target, got, tt.want)
+		}
+		if got := deps.describeImpact(tt.opt); got != tt.desc {
+			t.Errorf("describeImpact(%s) = %q, want %q", tt.opt.Target, got, tt.desc)
+		}
+	}
+
+	// Cycles end.
+	cyclic := reverseDeps([]Tab{{Options: []MakeOption{{Target: "a", Deps: []string{"b"}}, {Target: "b", Deps: []string{"a"}}}}})
+	if got := cyclic.Impact("a"); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("Impact(a) in a cycle = %q, want [b]", got)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	path := writeMakefile(t, "a:\n\t@echo a\nb:\n\t@false\nc:\n\t@echo c\n")
+	state := &State{}
+	for _, target := range []string{"c", "a", "b", "c"} {
+		state.countRun(path, target)
+	}
+	if got := state.lastRuns(path, 3); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Fatalf("lastRuns(3) = %q, want [a b c]", got)
+	}
+	if got := state.lastRuns(path, 10); len(got) != 4 {
+		t.Errorf("lastRuns(10) = %q, want all 4 runs", got)
+	}
+
+	// Parallel and KeepGoing do not apply to a replay.
+	runner := &Runner{Makefile: path, Parallel: true, KeepGoing: true}
+	var out bytes.Buffer
+	results, err := runner.Replay(state.lastRuns(path, 3), Stdio{Stdout: &out, Stderr: &out})
+	if err == nil {
+		t.Fatal("Replay succeeded, want b's failure")
+	}
+	if results[0].Err != nil || results[1].Err == nil || !results[2].Skipped {
+		t.Errorf("Replay results = %+v, want a passed, b failed, c skipped", results)
+	}
+	if strings.Contains(out.String(), "\nc\n") {
+		t.Errorf("Replay ran c after b failed:\n%s", out.String())
+	}
+
+	for i := 0; i < maxLastRuns+5; i++ {
+		state.countRun(path, "a")
+	}
+	if n := len(state.LastRuns[stateKey(path)]); n != maxLastRuns {
+		t.Errorf("LastRuns kept %d runs, want %d", n, maxLastRuns)
+	}
+}
+
+func TestDiagLogger(t *testing.T) {
+	if _, err := parseLogLevel("verbose"); err == nil {
+		t.Error("parseLogLevel(verbose) succeeded")
+	}
+	level, err := parseLogLevel("INFO")
+	if err != nil || level != levelInfo {
+		t.Fatalf("parseLogLevel(INFO) = %v, %v", level, err)
+	}
+	var buf bytes.Buffer
+	l := &diagLogger{}
+	l.setup(&buf, level)
+	l.debugf("hidden %d", 1)
+	l.infof("shown %d", 2)
+	l.warnf("shown %d", 3)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "info  shown 2") || !strings.HasSuffix(lines[1], "warn  shown 3") {
+		t.Errorf("log at info =\n%s", buf.String())
+	}
+
+	// The parser reports its includes through the Logf parse option.
+	buf.Reset()
+	l.setup(&buf, levelDebug)
+	path := writeMakefile(t, "-include missing.mk\ninclude $(EXTRA)\nall:\n")
+	if _, _, err := parseMakefileSources(path, makefile.Options{Logf: l.debugf}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"parsing " + path, "optional include " + filepath.Join(filepath.Dir(path), "missing.mk") + " does not exist", "skipping include $(EXTRA)"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("debug log lacks %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestDescribeVariables(t *testing.T) {
+	vars := []makefile.Variable{
+		{Name: "PREFIX", Op: "?=", Value: "/usr", File: "Makefile"},
+		{Name: "BIN", Op: "=", Value: "$(PREFIX)/bin", File: "Makefile"},
+		{Name: "CFLAGS", Op: "+=", Value: "-g", File: "mk/flags.mk", Line: 3},
+	}
+	want := "PREFIX ?= /usr\nBIN = $(PREFIX)/bin\n    → /usr/bin\nCFLAGS += -g  (flags.mk:3)"
+	if got := describeVariables(vars, "Makefile"); got != want {
+		t.Errorf("describeVariables =\n%s\nwant\n%s", got, want)
+	}
+	if _, err := (&Runner{Command: []string{"just"}}).Variables(); err == nil {
+		t.Error("Variables succeeded for just")
+	}
+}
+
+func TestRepeatWriter(t *testing.T) {
+	var out bytes.Buffer
+	var counts []int
+	r := &repeatWriter{w: &out, onRepeat: func(n int) { counts = append(counts, n) }}
+	for _, chunk := range []string{"start\nretry", "ing\nretrying\n", "retrying\nre", "try failed\n", "dot", "\ndot\n"} {
+		r.Write([]byte(chunk))
+	}
+	r.flush()
+	want := "start\nretrying\n\x1b[2m⟲ retrying (×3)\x1b[0m\nretry failed\ndot\n\x1b[2m⟲ dot (×2)\x1b[0m\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+	if !reflect.DeepEqual(counts, []int{2, 3, 0, 2, 0}) {
+		t.Errorf("onRepeat counts = %v, want [2 3 0 2 0]", counts)
+	}
+
+	// After a flush a line is not a repeat of the previous run's.
+	out.Reset()
+	r.Write([]byte("dot\n"))
+	if out.String() != "dot\n" {
+		t.Errorf("after flush, output = %q, want %q", out.String(), "dot\n")
+	}
+	var nilWriter *repeatWriter
+	nilWriter.flush()
+}
+
+func TestReflowRow(t *testing.T) {
+	text := "aaaa bbbb\n[red]cc[-]\n\n[\"l1\"]dddd eeee ffff[\"\"]\ngg"
+	if got := wrappedRows(text, 5); got != 8 {
+		t.Errorf("wrappedRows at 5 = %d, want 8", got)
+	}
+	if got := wrappedRows(text+"\n", 5); got != 8 {
+		t.Errorf("wrappedRows at 5 with a final newline = %d, want 8", got)
+	}
+	tests := []struct {
+		row, oldWidth, newWidth, want int
+	}{
+		{2, 5, 20, 1},  // cc, after the unwrapped first line
+		{5, 5, 20, 3},  // the middle of dddd eeee ffff keeps its line
+		{4, 5, 10, 3},  // dddd eeee ffff, now after one row less
+		{3, 20, 5, 4},  // dddd eeee ffff from the top of its wrapped rows
+		{0, 20, 5, 0},  // the top stays the top
+		{99, 20, 5, 8}, // past the end
+	}
+	for _, tt := range tests {
+		if got := reflowRow(text, tt.row, tt.oldWidth, tt.newWidth); got != tt.want {
+			t.Errorf("reflowRow(row %d, %d -> %d) = %d, want %d", tt.row, tt.oldWidth, tt.newWidth, got, tt.want)
+		}
+	}
+}
+
+func TestWorkflows(t *testing.T) {
+	var cfg Config
+	data := "workflows:\n  check: [lint, test]\n  release:\n    description: Ship it\n    steps: [fail, build]\n    stopOnError: false\n"
+	if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Workflows["check"]; !reflect.DeepEqual(got.Steps, []string{"lint", "test"}) || got.keepGoing() {
+		t.Errorf("check = %+v, want steps lint, test stopping on error", got)
+	}
+	if got := cfg.Workflows["release"]; got.Description != "Ship it" || !got.keepGoing() {
+		t.Errorf("release = %+v, want its description and going on past failures", got)
+	}
+
+	options := []MakeOption{{Target: "lint"}, {Target: "test"}, {Target: "fail"}, {Target: "build", Confirm: true}}
+	if err := validateWorkflows(options, &cfg); err != nil {
+		t.Errorf("validateWorkflows: %v", err)
+	}
+	for _, bad := range []Config{
+		{Workflows: map[string]Workflow{"lint": {Steps: []string{"test"}}}},
+		{Workflows: map[string]Workflow{"ci": {Steps: []string{"test"}}}, Sequences: map[string][]string{"ci": {"lint"}}},
+		{Workflows: map[string]Workflow{"ci": {Steps: []string{"deploy"}}}},
+		{Workflows: map[string]Workflow{"ci": {}}},
+	} {
+		if err := validateWorkflows(options, &bad); err == nil {
+			t.Errorf("validateWorkflows(%+v) succeeded", bad)
+		}
+	}
+
+	tab := workflowTab(cfg.Workflows, options)
+	if tab.Name != workflowsTab || len(tab.Options) != 2 || tab.Options[0].Target != "check" || tab.Options[0].Comment != "lint → test" || tab.Options[1].Comment != "Ship it" {
+		t.Errorf("workflowTab = %+v", tab)
+	}
+	if tab.Options[0].Confirm || !tab.Options[1].Confirm {
+		t.Errorf("workflowTab = %+v, want only release, with its @confirm build step, marked Confirm", tab)
+	}
+	if got := confirmedSteps([]Tab{tab, {Options: options}}, []string{"lint", "release", "build"}); !reflect.DeepEqual(got, []string{"release", "build"}) {
+		t.Errorf("confirmedSteps = %q, want release and build", got)
+	}
+
+	path := writeMakefile(t, "lint:\n\t@echo linting\ntest:\n\t@echo testing\nfail:\n\t@false\nbuild:\n\t@echo building\n")
+	runner := &Runner{Makefile: path, Workflows: cfg.Workflows, Outcomes: &Outcomes{}}
+	var out bytes.Buffer
+	err := runner.Run("release", Stdio{Stdout: &out, Stderr: &out})
+	if err == nil || !strings.Contains(out.String(), "building") {
+		t.Errorf("release: err = %v, output:\n%s\nwant the failure and build still run", err, out.String())
+	}
+	if got := runner.Outcomes.Get(MakeOption{Target: "release"}); got != OutcomeFailed {
+		t.Errorf("release outcome = %v, want failed", got)
+	}
+	if !strings.Contains(runner.Preview("check"), "Workflow: lint → test") {
+		t.Errorf("Preview(check) = %q", runner.Preview("check"))
+	}
+}
+
+func TestProblemWriter(t *testing.T) {
+	if _, err := newProblemMatcher([]string{"cobol"}, nil); err == nil {
+		t.Error("newProblemMatcher accepted an unknown preset")
+	}
+	matcher, err := newProblemMatcher([]string{"gcc", "go", "rust"}, []string{`^ERROR `})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	var found []Problem
+	p := &problemWriter{w: &out, matcher: matcher, onProblem: func(pr Problem) { found = append(found, pr) }}
+	var w io.Writer = escapeWriter{p}
+	for _, chunk := range []string{"cc -c main.c\nmain.c:3:1: warn", "ing: unused\n", "\x1b[31merror[E0308]\x1b[0m: mismatched types\n", "ok\n", "    x_test.go:12: got 1\nERROR disk full\n"} {
+		w.Write([]byte(chunk))
+	}
+	want := []Problem{
+		{Region: "l2", Text: "main.c:3:1: warning: unused", Warning: true},
+		{Region: "l3", Text: "error[E0308]: mismatched types"},
+		{Region: "l5", Text: "    x_test.go:12: got 1"},
+		{Region: "l6", Text: "ERROR disk full"},
+	}
+	if !reflect.DeepEqual(found, want) {
+		t.Errorf("problems =\n%+v\nwant\n%+v", found, want)
+	}
+	if !strings.HasPrefix(out.String(), `["l1"]cc -c main.c[""]`+"\n"+`["l2"]main.c:3:1: warn`) {
+		t.Errorf("output does not wrap lines in regions:\n%s", out.String())
+	}
+	p.reset()
+	w.Write([]byte("main.c:1: error: x\n"))
+	if last := found[len(found)-1]; last.Region != "l1" {
+		t.Errorf("after reset, region = %s, want l1", last.Region)
+	}
+}
+
+func TestPickFrontend(t *testing.T) {
+	tests := []struct {
+		requested         string
+		gui, term, screen bool
+		want              string
+		err               error
+	}{
+		{"auto", false, true, true, "gui", nil},
+		{"auto", false, true, false, "tview", nil},
+		{"auto", false, false, true, "", errNoTerminal},
+		{"auto", false, false, false, "", errNoTerminal},
+		{"auto", true, true, false, "gui", nil},
+		{"auto", true, false, true, "", errNoTerminal},
+		{"bubbletea", false, true, true, "bubbletea", nil},
+	}
+	for _, tt := range tests {
+		got, err := pickFrontend(tt.requested, tt.gui, tt.term, tt.screen)
+		if got != tt.want || err != tt.err {
+			t.Errorf("pickFrontend(%q, gui %v, terminal %v, display %v) = %q, %v, want %q, %v", tt.requested, tt.gui, tt.term, tt.screen, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestBench(t *testing.T) {
+	path := writeMakefile(t, ".PHONY: fast slow fail\nfast:\n\t@true\nslow:\n\t@sleep 0.2\nfail:\n\t@false\nout.txt:\n\t@touch out.txt\n")
+	tabs := []Tab{{Name: "All", Options: []MakeOption{
+		{Target: "fast", IsPhony: true},
+		{Target: "slow", IsPhony: true},
+		{Target: "quick", IsPhony: true, AliasOf: "fast"},
+		{Target: "fail", IsPhony: true},
+		{Target: "deploy", IsPhony: true, Confirm: true},
+		{Target: "release", IsPhony: true, Requires: []string{"TOKEN"}},
+		{Target: "out.txt"},
+	}}, workflowTab(map[string]Workflow{"check": {Steps: []string{"fast", "fail"}}}, nil)}
+	if got, err := benchTargets("phony", tabs, map[string]Workflow{"check": {}}); err != nil || !reflect.DeepEqual(got, []string{"fast", "slow", "fail"}) {
+		t.Errorf("benchTargets(phony) = %q, %v, want [fast slow fail]", got, err)
+	}
+	if got, err := benchTargets(" slow, ,fast", tabs, nil); err != nil || !reflect.DeepEqual(got, []string{"slow", "fast"}) {
+		t.Errorf("benchTargets(list) = %q, %v, want [slow fast]", got, err)
+	}
+	for _, spec := range []string{"fast,missing", "check"} {
+		if got, err := benchTargets(spec, tabs, map[string]Workflow{"check": {}}); err == nil {
+			t.Errorf("benchTargets(%q) = %q, want an error for a target not listed", spec, got)
+		}
+	}
+
+	runner := &Runner{Makefile: path}
+	var out bytes.Buffer
+	results := runner.Bench([]string{"fast", "fail", "slow"}, Stdio{Stdout: &out, Stderr: &out})
+	if len(results) != 3 || results[0].Target != "slow" {
+		t.Fatalf("Bench results = %+v, want all three, slow first", results)
+	}
+	for _, res := range results {
+		if want := map[string]int{"fail": 2}[res.Target]; res.ExitCode != want {
+			t.Errorf("%s exit code = %d, want %d", res.Target, res.ExitCode, want)
+		}
+	}
+	if !strings.Contains(out.String(), "total") {
+		t.Errorf("Bench output has no report:\n%s", out.String())
+	}
+	if !benchFailed(results) || benchFailed(results[:1]) {
+		t.Errorf("benchFailed(%+v) is wrong", results)
+	}
+
+	var csv bytes.Buffer
+	if err := writeBenchCSV(&csv, []BenchResult{{Target: "slow", Duration: 1500 * time.Millisecond, ExitCode: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "target,seconds,exit_code\nslow,1.500,2\n"; csv.String() != want {
+		t.Errorf("CSV = %q, want %q", csv.String(), want)
+	}
+}
+
+func TestRemoteMakefile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/Makefile" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, "# Build it\nbuild:\n\t@echo built\n")
+	}))
+	defer server.Close()
+
+	if !isRemoteMakefile(server.URL+"/Makefile") || isRemoteMakefile("../Makefile") {
+		t.Error("isRemoteMakefile does not tell URLs from paths")
+	}
+	if path := defaultConfigPath(server.URL + "/Makefile"); path != "" {
+		t.Errorf("defaultConfigPath for a remote Makefile = %q, want none", path)
+	}
+	options, err := parseRemoteMakefile(server.URL+"/Makefile", makefile.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(options) != 1 || options[0].Target != "build" || options[0].Comment != "Build it" || options[0].Source != "" {
+		t.Errorf("parseRemoteMakefile = %+v, want build", options)
+	}
+	if _, err := parseRemoteMakefile(server.URL+"/missing", makefile.Options{}); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("parseRemoteMakefile(missing) error = %v, want the 404 status", err)
+	}
+
+	// Without a local checkout nothing runs; with one, its Makefile does.
+	runner := &Runner{Makefile: server.URL + "/Makefile"}
+	if err := runner.Run("build", Stdio{}); err != errRemoteRun {
+		t.Errorf("Run without -remote-dir = %v, want errRemoteRun", err)
+	}
+	runner.LocalDir = filepath.Dir(writeMakefile(t, "build:\n\t@echo local\n"))
+	var out bytes.Buffer
+	if err := runner.Run("build", Stdio{Stdout: &out}); err != nil || out.String() != "local\n" {
+		t.Errorf("Run with -remote-dir = %v, output %q, want the checkout's build", err, out.String())
+	}
+}
+
+func TestTargetSource(t *testing.T) {
+	path := writeMakefile(t, "VAR = 1\n\n# Build it\nbuild: gen  ## inline\n\t@echo  \"$(VAR)\"\n\t-rm -f x\n\ntest:\n\ttest:\n")
+	options, err := parseMakefile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := &Runner{Makefile: path}
+	source, err := runner.targetSource(options[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "4 │ build: gen  ## inline\n5 │ \t@echo  \"$(VAR)\"\n6 │ \t-rm -f x"; source != want {
+		t.Errorf("targetSource(build) = %q, want %q", source, want)
+	}
+	if got, want := sourceLines("a\nb\nc\nd\ne\nf\ng\nh\ni\nj:\n", 9, 10), " 9 │ i\n10 │ j:"; got != want {
+		t.Errorf("sourceLines = %q, want the gutter padded to %q", got, want)
+	}
+	if _, err := runner.targetSource(MakeOption{Target: "just-recipe"}); err == nil {
+		t.Error("targetSource succeeded without a line")
+	}
+}
+
+func TestPickProject(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"api", "web/frontend", "web/frontend/sub", ".cache/old", "node_modules/dep", "a/b/c/deep"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, dir, "Makefile"), []byte("build:\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found := findProjects(root)
+	want := []string{filepath.Join(root, "api"), filepath.Join(root, "web/frontend")}
+	if !reflect.DeepEqual(found, want) {
+		t.Errorf("findProjects = %q, want %q", found, want)
+	}
+
+	gone := filepath.Join(root, "gone")
+	choices, missing := projectChoices([]string{want[1], gone}, found)
+	if !reflect.DeepEqual(choices, []string{want[1], want[0]}) || missing != 1 {
+		t.Errorf("projectChoices = %q, %d missing, want the recent project first and gone left out", choices, missing)
+	}
+	if got := fuzzyFilter("fe", []string{"/src/coffee/app", "/src/frontend"}); !reflect.DeepEqual(got, []string{"/src/frontend", "/src/coffee/app"}) {
+		t.Errorf("fuzzyFilter(fe) = %q, want the word start first", got)
+	}
+
+	var out bytes.Buffer
+	if dir, ok := pickProject(choices, strings.NewReader("api\n1\n"), &out); !ok || dir != want[0] {
+		t.Errorf("pickProject = %q, %v, want %q", dir, ok, want[0])
+	}
+	if dir, ok := pickProject(choices, strings.NewReader("zzz\n\n"), io.Discard); ok {
+		t.Errorf("pickProject picked %q with no match and then the end of input", dir)
+	}
+	if !strings.Contains(out.String(), "1) "+want[0]) {
+		t.Errorf("picker output %q does not list the match", out.String())
+	}
+}
+
+func TestMissingVars(t *testing.T) {
+	path := writeMakefile(t, "REGION ?= eu\nTOKEN ?=\n# @requires ENV REGION TOKEN\ndeploy:\n\t@echo deploying\n")
+	options, err := parseMakefile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := &Runner{Makefile: path}
+	runner.SetRequires(requiredVars([]Tab{{Name: "All", Options: options}}))
+	t.Setenv("ENV", "")
+	t.Setenv("TOKEN", "")
+	if got := runner.MissingVars("deploy", nil); !reflect.DeepEqual(got, []string{"ENV", "TOKEN"}) {
+		t.Errorf("MissingVars = %q, want ENV and TOKEN", got)
+	}
+	var stderr bytes.Buffer
+	if err := runner.Run("deploy", Stdio{Stdout: io.Discard, Stderr: &stderr}, "ENV=dev"); err == nil || !strings.Contains(stderr.String(), "deploy requires TOKEN") {
+		t.Errorf("Run without TOKEN = %v, stderr %q, want it refused", err, stderr.String())
+	}
+	t.Setenv("TOKEN", "secret")
+	var out bytes.Buffer
+	if err := runner.Run("deploy", Stdio{Stdout: &out}, "ENV:=dev"); err != nil || out.String() != "deploying\n" {
+		t.Errorf("Run with every variable set = %v, output %q", err, out.String())
+	}
+	if got := runner.requiredEnv("deploy"); !reflect.DeepEqual(got, []string{"TOKEN=secret"}) {
+		t.Errorf("requiredEnv = %q, want TOKEN from the environment", got)
+	}
+	if got := withVars([]string{"ENV=dev", "MSG=hi there"}, []string{"TOKEN"}); got != "ENV=dev 'MSG=hi there' TOKEN=" {
+		t.Errorf("withVars = %q", got)
+	}
+
+	// A variable set only in an included env file counts as set.
+	dir := t.TempDir()
+	path = filepath.Join(dir, "Makefile")
+	if err := os.WriteFile(path, []byte("-include .env\n# @requires ENV\ndeploy:\n\t@echo deploying\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("ENV = staging\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runner = &Runner{Makefile: path}
+	runner.SetRequires(map[string][]string{"deploy": {"ENV"}})
+	if got := runner.MissingVars("deploy", nil); len(got) > 0 {
+		t.Errorf("MissingVars with ENV in the env file = %q, want none", got)
+	}
+}
+
+func TestOverviewText(t *testing.T) {
+	build := MakeOption{Target: "build", Comment: "Compile", IsDefault: true}
+	lint := MakeOption{Target: "lint"}
+	tabs := []Tab{
+		{Name: "All", Options: []MakeOption{build, lint}},
+		{Name: "Build", Options: []MakeOption{build}},
+		{Name: "Misc", Options: []MakeOption{lint}},
+		{Name: "Empty"},
+		{Name: undocumentedTab, Options: []MakeOption{lint}},
+	}
+	got := overviewText(tabs, "make")
+	for _, want := range []string{"Targets:       2\n", "Documented:    1, undocumented: 1\n", "Default goal:  build\n", "Build tool:    make\n", "  Build                1\n  Misc                 1\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("overview %q lacks %q", got, want)
+		}
+	}
+	if strings.Contains(got, "Empty") || strings.Contains(got, "  All") {
+		t.Errorf("overview %q lists empty or pinned tabs as categories", got)
+	}
+}
@@ -0,0 +1,502 @@
+// Package makefile reads the targets of a Makefile the way CoolBox lists
+// them, with the positions editor tooling needs for "go to definition" and
+// hovers.
+package makefile
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Target is one rule of a Makefile.
+type Target struct {
+	Name    string
+	Comment string
+	// File and Line locate the target's first rule line; Line counts from 1.
+	// EndLine is the first rule's last recipe line, or Line without one.
+	File    string
+	Line    int
+	EndLine int
+	// RecipeRange is the byte range of the first rule's recipe lines in
+	// File, without the final newline. It is empty when there is no recipe.
+	RecipeRange Range
+	// Recipe holds the recipe lines without their leading tab, joined by
+	// newlines, across all rules of a double-colon target.
+	Recipe string
+	// Deps lists the prerequisites named on the target's rule lines.
+	Deps []string
+	// IsPhony is set for prerequisites of .PHONY.
+	IsPhony bool
+	// IsDefault marks the default goal, the target make builds when run
+	// without arguments: the one named by .DEFAULT_GOAL, or else the first.
+	IsDefault bool
+	// DoubleColon is set for targets defined with "::" rules. Make allows
+	// several such rules for one target; they are listed once.
+	DoubleColon bool
+	// Category is the name given by an "@category Name" comment above the
+	// target.
+	Category string
+	// Section is the title of the last "##@ Title" header before the target
+	// in its file, the grouping self-documenting help targets print.
+	Section string
+	// Args are the arguments an "@args ENV=dev" comment above the target
+	// suggests for running it, such as variable overrides.
+	Args string
+	// Requires names the variables an "@requires ENV REGION" comment above
+	// the target says must be set for it to run.
+	Requires []string
+	// Confirm is set by an "@confirm" comment above the target: its author
+	// wants every run confirmed.
+	Confirm bool
+}
+
+// Range is a half-open byte range [Start, End).
+type Range struct {
+	Start, End int
+}
+
+// Options tune how a Makefile is read. The zero value reads it the way
+// Parse does.
+type Options struct {
+	// MaxIncludeDepth, when positive, overrides DefaultMaxIncludeDepth.
+	MaxIncludeDepth int
+	// Logf, when set, is told which files the parser reads and which
+	// includes it skips, for debugging why targets are missing.
+	Logf func(format string, args ...interface{})
+}
+
+// maxIncludeDepth is how deeply includes may nest with o.
+func (o Options) maxIncludeDepth() int {
+	if o.MaxIncludeDepth > 0 {
+		return o.MaxIncludeDepth
+	}
+	return DefaultMaxIncludeDepth
+}
+
+// Parse returns the targets of the Makefile at path in definition order,
+// following include directives.
+func Parse(path string) ([]Target, error) {
+	return Options{}.Parse(path)
+}
+
+// Parse is the package's Parse with o.
+func (o Options) Parse(path string) ([]Target, error) {
+	targets, _, err := o.ParseSources(path)
+	return targets, err
+}
+
+// ParseSources is Parse that also returns the paths the result depends on:
+// every file read, optional includes that were missing and the directories
+// searched by include globs.
+//
+// When path is a directory of *.mk fragments, as some projects keep instead
+// of a top-level Makefile, the fragments are parsed in name order and their
+// targets merged.
+func ParseSources(path string) ([]Target, []string, error) {
+	return Options{}.ParseSources(path)
+}
+
+// ParseSources is the package's ParseSources with o.
+func (o Options) ParseSources(path string) ([]Target, []string, error) {
+	p, err := o.parse(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.targets, p.sources, nil
+}
+
+// ParseReader is Parse for a Makefile read from r rather than a file, such
+// as one fetched over HTTP. name labels it in errors and in Target.File.
+// With no directory to find them in, its includes are skipped.
+func ParseReader(name string, r io.Reader) ([]Target, error) {
+	return Options{}.ParseReader(name, r)
+}
+
+// ParseReader is the package's ParseReader with o.
+func (o Options) ParseReader(name string, r io.Reader) ([]Target, error) {
+	p := newParser(name, o)
+	p.noIncludes = true
+	if err := p.read(name, r); err != nil {
+		return nil, err
+	}
+	p.finish()
+	return p.targets, nil
+}
+
+func newParser(path string, opts Options) *parser {
+	return &parser{
+		opts:         opts,
+		root:         filepath.Dir(path),
+		seen:         make(map[string]int),
+		varIndex:     make(map[string]int),
+		including:    make(map[string]bool),
+		phony:        make(map[string]bool),
+		recipePrefix: "\t",
+	}
+}
+
+// parse reads the Makefile or fragment directory at path.
+func (o Options) parse(path string) (*parser, error) {
+	p := newParser(path, o)
+	files := []string{path}
+	if fragments, err := Fragments(path); err != nil {
+		return nil, err
+	} else if fragments != nil {
+		files = fragments
+		p.sources = append(p.sources, path)
+	}
+	for _, file := range files {
+		if err := p.parseFile(file); err != nil {
+			return nil, err
+		}
+	}
+	p.finish()
+	return p, nil
+}
+
+// finish marks the phony targets and the default goal once every file has
+// been read.
+func (p *parser) finish() {
+	goal := p.goal
+	if goal == "" && len(p.targets) > 0 {
+		goal = p.targets[0].Name
+	}
+	for i := range p.targets {
+		p.targets[i].IsPhony = p.phony[p.targets[i].Name]
+		p.targets[i].IsDefault = p.targets[i].Name == goal
+	}
+}
+
+// Fragments returns the *.mk files in dir, sorted, or nil when dir is not a
+// directory.
+func Fragments(dir string) ([]string, error) {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, nil
+	}
+	fragments, err := filepath.Glob(filepath.Join(dir, "*.mk"))
+	if err != nil {
+		return nil, err
+	}
+	if len(fragments) == 0 {
+		return nil, fmt.Errorf("%s: no *.mk fragments in directory", dir)
+	}
+	sort.Strings(fragments)
+	return fragments, nil
+}
+
+var (
+	targetRe       = regexp.MustCompile(`^([a-zA-Z0-9_-]+)\s*(::?)`)               // target: or target:: line
+	includeRe      = regexp.MustCompile(`^\s*(-include|sinclude|include)\s+(.+)$`) // include directive
+	recipePrefixRe = regexp.MustCompile(`^\.RECIPEPREFIX\s*[:!?]*=\s*(.*)$`)       // .RECIPEPREFIX assignment
+	phonyRe        = regexp.MustCompile(`^\.PHONY\s*:([^#;]*)`)                    // .PHONY: targets
+	defaultGoalRe  = regexp.MustCompile(`^\.DEFAULT_GOAL\s*[:?]*=\s*([^\s#]*)`)    // .DEFAULT_GOAL assignment
+	defineRe       = regexp.MustCompile(`^\s*((override|export|private)\s+)*define(\s|$)`)
+	endefRe        = regexp.MustCompile(`^\s*endef(\s|$)`)
+)
+
+// DefaultMaxIncludeDepth is how deeply includes may nest before parsing
+// fails with a *DepthError, unless Options say otherwise. It guards against
+// runaway include chains that cycle detection misses, such as ones through
+// ever-changing paths.
+const DefaultMaxIncludeDepth = 32
+
+// logf passes a message to the Logf of o, if any.
+func (o Options) logf(format string, args ...interface{}) {
+	if o.Logf != nil {
+		o.Logf(format, args...)
+	}
+}
+
+// DepthError reports an include chain nested deeper than allowed, see
+// Options.MaxIncludeDepth.
+type DepthError struct {
+	// Chain lists the files from the Makefile to the include that was
+	// refused.
+	Chain []string
+	Max   int
+}
+
+func (e *DepthError) Error() string {
+	return fmt.Sprintf("includes nested deeper than %d: %s", e.Max, strings.Join(e.Chain, " -> "))
+}
+
+func isDepthError(err error) bool {
+	var depthErr *DepthError
+	return errors.As(err, &depthErr)
+}
+
+// binarySniffLen is how much of a file is checked for NUL bytes before it
+// is parsed, the same amount git looks at.
+const binarySniffLen = 8000
+
+// parser accumulates targets across a Makefile and the files it includes.
+type parser struct {
+	opts      Options         // how the Makefile is read
+	root      string          // directory include paths are relative to, as for make
+	targets   []Target        // in definition order
+	seen      map[string]int  // target -> index in targets
+	including map[string]bool // files currently being parsed, to stop include cycles
+	chain     []string        // the same files in include order
+	sources   []string        // paths the result depends on, see ParseSources
+	phony     map[string]bool // prerequisites of .PHONY, which may come before the rule
+	goal      string          // value of .DEFAULT_GOAL, if set
+	variables []Variable      // in order of first assignment
+	varIndex  map[string]int  // variable name -> index in variables
+	// noIncludes skips include directives, for a Makefile that is not in
+	// a local directory they could be relative to.
+	noIncludes bool
+
+	recipePrefix string // starts a recipe line; a tab unless .RECIPEPREFIX is set
+}
+
+// parseFile reads the targets of one file. Errors carry the path and, once
+// reading has started, the line being processed.
+func (p *parser) parseFile(path string) error {
+	p.opts.logf("parsing %s", path)
+	p.sources = append(p.sources, path)
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if abs, err := filepath.Abs(path); err == nil {
+		p.including[abs] = true
+		defer delete(p.including, abs)
+	}
+	p.chain = append(p.chain, path)
+	defer func() { p.chain = p.chain[:len(p.chain)-1] }()
+	return p.read(path, file)
+}
+
+// read parses the Makefile content r of path.
+func (p *parser) read(path string, r io.Reader) error {
+	// A NUL byte near the start means a binary file was given by mistake;
+	// scanning it would only produce garbage targets.
+	reader := bufio.NewReaderSize(r, binarySniffLen)
+	if head, _ := reader.Peek(binarySniffLen); bytes.IndexByte(head, 0) >= 0 {
+		return fmt.Errorf("%s: not a text Makefile", path)
+	}
+
+	// lineStart is the byte offset of the line just scanned, next that of
+	// the line after it.
+	var lineStart, next int
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if token != nil {
+			lineStart, next = next, next+advance
+		}
+		return advance, token, err
+	})
+	var lastComment, lastCategory, lastArgs string
+	var lastRequires []string
+	section := ""        // from the last "##@" header, which lasts to the end of the file
+	lastConfirm := false // an "@confirm" comment came after the last rule
+	lineNo := 0
+	current := -1      // index of the target whose recipe is being read
+	firstRule := false // current's recipe belongs to its first rule
+	defining := 0      // depth of the define ... endef blocks being skipped
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNo++
+		// A line's length in the file, for the end of a recipe range.
+		lineLen := len(line)
+		if lineNo == 1 {
+			// Some Windows editors start the file with a UTF-8 BOM.
+			line = strings.TrimPrefix(line, "\uFEFF")
+		}
+		if current >= 0 && strings.HasPrefix(line, p.recipePrefix) {
+			t := &p.targets[current]
+			if t.Recipe != "" {
+				t.Recipe += "\n"
+			}
+			t.Recipe += strings.TrimPrefix(line, p.recipePrefix)
+			if firstRule {
+				// No recipe line starts a file, so Start 0 means unset.
+				if t.RecipeRange.Start == 0 {
+					t.RecipeRange.Start = lineStart
+				}
+				t.RecipeRange.End = lineStart + lineLen
+				t.EndLine = lineNo
+			}
+			continue
+		}
+		current = -1
+		// The body of a multi-line variable is not parsed; make allows
+		// defines to nest.
+		if defineRe.MatchString(line) {
+			defining++
+			continue
+		}
+		if defining > 0 {
+			if endefRe.MatchString(line) {
+				defining--
+				lastComment, lastCategory, lastArgs, lastRequires, lastConfirm = "", "", "", nil, false
+			}
+			continue
+		}
+		if m := recipePrefixRe.FindStringSubmatch(line); m != nil {
+			// Like make, only the first character counts and an empty
+			// value restores the tab.
+			p.recipePrefix = "\t"
+			if r := []rune(m[1]); len(r) > 0 {
+				p.recipePrefix = string(r[0])
+			}
+			lastComment, lastCategory, lastArgs, lastRequires, lastConfirm = "", "", "", nil, false
+		} else if m := defaultGoalRe.FindStringSubmatch(line); m != nil {
+			p.goal = m[1]
+		} else if m := phonyRe.FindStringSubmatch(line); m != nil {
+			for _, name := range strings.Fields(m[1]) {
+				p.phony[name] = true
+			}
+		} else if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			text := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "#"))
+			if name, ok := strings.CutPrefix(text, "@category "); ok {
+				lastCategory = strings.TrimSpace(name)
+			} else if args, ok := strings.CutPrefix(text, "@args "); ok {
+				lastArgs = strings.TrimSpace(args)
+			} else if names, ok := strings.CutPrefix(text, "@requires "); ok {
+				lastRequires = append(lastRequires, strings.Fields(strings.ReplaceAll(names, ",", " "))...)
+			} else if title, ok := strings.CutPrefix(text, "#@"); ok {
+				section = strings.TrimSpace(title)
+			} else if text == "@confirm" {
+				lastConfirm = true
+			} else {
+				lastComment = text
+			}
+		} else if m := includeRe.FindStringSubmatch(line); m != nil {
+			if p.noIncludes {
+				p.opts.logf("skipping include %s: %s is not a local file", m[2], path)
+			} else if err := p.include(m[2], m[1] != "include"); err != nil {
+				if isDepthError(err) {
+					// The chain already names every file involved.
+					return err
+				}
+				return fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+			lastComment, lastCategory, lastArgs, lastRequires, lastConfirm = "", "", "", nil, false
+		} else if m := variableRe.FindStringSubmatch(line); m != nil {
+			p.assign(m, path, lineNo)
+		} else if m := targetRe.FindStringSubmatch(line); m != nil && !strings.HasPrefix(line[len(m[0]):], "=") {
+			// The "=" check skips ":=" and "::=" variable assignments.
+			rest := line[len(m[0]):]
+			doubleColon := m[2] == "::"
+			deps := parseDeps(rest)
+			comment := ruleComment(rest, lastComment)
+			if i, ok := p.seen[m[1]]; ok && doubleColon && p.targets[i].DoubleColon {
+				// Another rule for the same double-colon target.
+				if p.targets[i].Comment == "" {
+					p.targets[i].Comment = comment
+				}
+				if p.targets[i].Category == "" {
+					p.targets[i].Category = lastCategory
+				}
+				if p.targets[i].Args == "" {
+					p.targets[i].Args = lastArgs
+				}
+				p.targets[i].Requires = append(p.targets[i].Requires, lastRequires...)
+				p.targets[i].Confirm = p.targets[i].Confirm || lastConfirm
+				p.targets[i].Deps = append(p.targets[i].Deps, deps...)
+				current, firstRule = i, false
+			} else {
+				p.seen[m[1]] = len(p.targets)
+				current, firstRule = len(p.targets), true
+				p.targets = append(p.targets, Target{Name: m[1], Comment: comment, File: path, Line: lineNo, EndLine: lineNo, DoubleColon: doubleColon, Deps: deps, Category: lastCategory, Section: section, Args: lastArgs, Requires: lastRequires, Confirm: lastConfirm})
+			}
+			lastComment, lastCategory, lastArgs, lastRequires, lastConfirm = "", "", "", nil, false
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s:%d: %w", path, lineNo+1, err)
+	}
+	return nil
+}
+
+// ruleComment picks the description for a rule from the text after its
+// colon and the comment line above it. An inline "## text" wins, as used by
+// self-documenting Makefiles; then the preceding comment; then a plain
+// inline "# text".
+func ruleComment(rest, preceding string) string {
+	i := strings.Index(rest, "#")
+	if i < 0 {
+		return preceding
+	}
+	inline := rest[i:]
+	if strings.HasPrefix(inline, "##") {
+		return strings.TrimSpace(strings.TrimLeft(inline, "#"))
+	}
+	if preceding != "" {
+		return preceding
+	}
+	return strings.TrimSpace(inline[1:])
+}
+
+// parseDeps returns the prerequisites in the text following a rule's
+// colon, dropping any inline recipe, comment and the order-only separator.
+// Target-specific variable assignments have no prerequisites.
+func parseDeps(rest string) []string {
+	if i := strings.IndexAny(rest, ";#"); i >= 0 {
+		rest = rest[:i]
+	}
+	if strings.Contains(rest, "=") {
+		return nil
+	}
+	var deps []string
+	for _, dep := range strings.Fields(rest) {
+		if dep != "|" {
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
+
+// include parses the files named by an include directive. Names using make
+// variables cannot be resolved and are skipped. Missing files are an error
+// unless optional is set, as for -include and sinclude.
+func (p *parser) include(names string, optional bool) error {
+	for _, name := range strings.Fields(names) {
+		if strings.Contains(name, "$") {
+			p.opts.logf("skipping include %s: make variables are not expanded", name)
+			continue
+		}
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(p.root, name)
+		}
+		paths := []string{name}
+		if strings.ContainsAny(name, "*?[") {
+			paths, _ = filepath.Glob(name)
+			p.opts.logf("include %s matched %d files", name, len(paths))
+			p.sources = append(p.sources, filepath.Dir(name))
+		}
+		for _, path := range paths {
+			if abs, err := filepath.Abs(path); err == nil && p.including[abs] {
+				return fmt.Errorf("include %s: include cycle", path)
+			}
+			if _, err := os.Stat(path); optional && errors.Is(err, fs.ErrNotExist) {
+				p.opts.logf("optional include %s does not exist", path)
+				p.sources = append(p.sources, path)
+				continue
+			}
+			if len(p.chain) > p.opts.maxIncludeDepth() {
+				chain := append(append([]string(nil), p.chain...), path)
+				return &DepthError{Chain: chain, Max: p.opts.maxIncludeDepth()}
+			}
+			if err := p.parseFile(path); err != nil {
+				if isDepthError(err) {
+					return err
+				}
+				return fmt.Errorf("include %s: %w", path, err)
+			}
+		}
+	}
+	return nil
+}
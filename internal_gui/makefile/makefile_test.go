@@ -0,0 +1,293 @@
+package makefile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDeps(t *testing.T) {
+	tests := []struct {
+		rest string
+		want []string
+	}{
+		{" generate  fmt", []string{"generate", "fmt"}},
+		{" a | dir ; echo inline", []string{"a", "dir"}},
+		{" # just a comment", nil},
+		{" CFLAGS = -O2", nil},
+	}
+	for _, tt := range tests {
+		if got := parseDeps(tt.rest); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseDeps(%q) = %q, want %q", tt.rest, got, tt.want)
+		}
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Makefile")
+	content := ".PHONY: build\n# Compile\nbuild: gen\n\tgo build\n\tgo vet\n\nclean::\n\trm -f out\nclean::\n\trm -rf tmp\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	targets, err := Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("Parse returned %d targets, want 2", len(targets))
+	}
+	build := targets[0]
+	if build.Name != "build" || build.Line != 3 || build.File != path || !build.IsPhony || build.Comment != "Compile" {
+		t.Errorf("build = %+v", build)
+	}
+	if got := content[build.RecipeRange.Start:build.RecipeRange.End]; got != "\tgo build\n\tgo vet" {
+		t.Errorf("build recipe range covers %q", got)
+	}
+	clean := targets[1]
+	if got := content[clean.RecipeRange.Start:clean.RecipeRange.End]; got != "\trm -f out" || clean.Recipe != "rm -f out\nrm -rf tmp" {
+		t.Errorf("clean recipe range covers %q, recipe %q", got, clean.Recipe)
+	}
+	if clean.Line != 7 || clean.IsPhony {
+		t.Errorf("clean = %+v", clean)
+	}
+}
+
+func TestParseDefaultGoal(t *testing.T) {
+	dir := t.TempDir()
+	for content, want := range map[string]string{
+		"build:\ntest:\n":                        "build",
+		"build:\n.DEFAULT_GOAL := test\ntest:\n": "test",
+	} {
+		path := filepath.Join(dir, "Makefile")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		targets, err := Parse(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, target := range targets {
+			if target.IsDefault {
+				got = append(got, target.Name)
+			}
+		}
+		if !reflect.DeepEqual(got, []string{want}) {
+			t.Errorf("%q: default goals %q, want %q", content, got, want)
+		}
+	}
+}
+
+func TestParseDefine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Makefile")
+	content := "define HELP\nusage: make build\n  define INNER\n  inner:\n  endef\nnot-a-target:\nendef\n\nexport define SCRIPT\nrun: fast\nendef\n\n# Build it\nbuild:\n\techo $(HELP)\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	targets, err := Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, target := range targets {
+		names = append(names, target.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"build"}) {
+		t.Fatalf("targets %q, want only build", names)
+	}
+	if targets[0].Comment != "Build it" || !targets[0].IsDefault {
+		t.Errorf("build = %+v, want its comment and the default goal", targets[0])
+	}
+}
+
+func TestParseIncludeDepthLimit(t *testing.T) {
+	dir := t.TempDir()
+	// inc0.mk includes inc1.mk and so on, one level deeper than allowed.
+	for i := 0; i <= DefaultMaxIncludeDepth; i++ {
+		content := fmt.Sprintf("include inc%d.mk\nt%d:\n", i+1, i)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("inc%d.mk", i)), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(dir, fmt.Sprintf("inc%d.mk", DefaultMaxIncludeDepth+1)), []byte("last:\n"), 0o644)
+	path := filepath.Join(dir, "Makefile")
+	os.WriteFile(path, []byte("include inc0.mk\n"), 0o644)
+
+	_, err := Parse(path)
+	var depthErr *DepthError
+	if !errors.As(err, &depthErr) {
+		t.Fatalf("Parse error = %v, want a *DepthError", err)
+	}
+	if n := len(depthErr.Chain); n != DefaultMaxIncludeDepth+2 || depthErr.Chain[0] != path {
+		t.Errorf("chain has %d files starting %q, want %d from the Makefile", n, depthErr.Chain[0], DefaultMaxIncludeDepth+2)
+	}
+	if strings.Count(err.Error(), "include ") != 0 {
+		t.Errorf("error %q is wrapped at every level", err)
+	}
+
+	// Ending the chain with include number DefaultMaxIncludeDepth is fine.
+	os.WriteFile(filepath.Join(dir, fmt.Sprintf("inc%d.mk", DefaultMaxIncludeDepth-1)), []byte("last:\n"), 0o644)
+	if _, err := Parse(path); err != nil {
+		t.Errorf("Parse at the depth limit: %v", err)
+	}
+	if _, err := (Options{MaxIncludeDepth: 2}).Parse(path); !errors.As(err, &depthErr) || depthErr.Max != 2 {
+		t.Errorf("Parse with a depth of 2 = %v, want a *DepthError at 2", err)
+	}
+}
+
+func TestVariables(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Makefile")
+	content := "CC ?= gcc\nCFLAGS := -O2 # optimize\nCFLAGS += -g\nexport PREFIX = /usr/local\nCC ?= clang\nLDLIBS += -lm\nHASH = a\\#b\nVERSION != git describe\ninclude vars.mk\nbuild: CFLAGS += -Wall\nbuild:\n\t$(CC) $(CFLAGS) -o out\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "vars.mk"), []byte("PREFIX = /opt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	vars, err := Variables(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Variable{
+		{Name: "CC", Op: "?=", Value: "gcc", File: path, Line: 1},
+		{Name: "CFLAGS", Op: ":=", Value: "-O2 -g", File: path, Line: 2},
+		{Name: "PREFIX", Op: "=", Value: "/opt", File: filepath.Join(dir, "vars.mk"), Line: 1},
+		{Name: "LDLIBS", Op: "+=", Value: "-lm", File: path, Line: 6},
+		{Name: "HASH", Op: "=", Value: "a#b", File: path, Line: 7},
+		{Name: "VERSION", Op: "!=", Value: "git describe", File: path, Line: 8},
+	}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("Variables =\n%+v\nwant\n%+v", vars, want)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	vars := []Variable{
+		{Name: "PREFIX", Value: "/usr"},
+		{Name: "BIN", Value: "${PREFIX}/bin"},
+		{Name: "LOOP", Value: "$(LOOP)x"},
+		{Name: "O", Value: "out"},
+	}
+	tests := []struct{ value, want string }{
+		{"$(BIN)/tool", "/usr/bin/tool"},
+		{"$O.txt $$HOME", "out.txt $$HOME"},
+		{"$(shell date) $(UNKNOWN) $@", "$(shell date) $(UNKNOWN) $@"},
+		{"unterminated $(PREFIX", "unterminated $(PREFIX"},
+		{"trailing $", "trailing $"},
+	}
+	for _, tt := range tests {
+		if got := Expand(tt.value, vars); got != tt.want {
+			t.Errorf("Expand(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+	if got := Expand("$(LOOP)", vars); !strings.HasSuffix(got, "xxx") {
+		t.Errorf("Expand($(LOOP)) = %q, want it cut off after some x", got)
+	}
+}
+
+func TestParseConfirm(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Makefile")
+	content := "# @confirm\n# Wipe the database\nreset-db:\n\trm -rf db\n\n# Build\nbuild:\n\tgo build\n\n# @confirm\nVAR = 1\ndeploy::\n\t./deploy a\n\n# @confirm\ninclude missing.mk\nlint:\n"
+	if err := os.WriteFile(path, []byte(strings.Replace(content, "include missing.mk", "-include missing.mk", 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	targets, err := Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"reset-db": true, "build": false, "deploy": true, "lint": false}
+	for _, target := range targets {
+		if target.Confirm != want[target.Name] {
+			t.Errorf("%s: Confirm = %v, want %v", target.Name, target.Confirm, want[target.Name])
+		}
+	}
+	if targets[0].Comment != "Wipe the database" {
+		t.Errorf("reset-db comment = %q, want the comment line after @confirm", targets[0].Comment)
+	}
+}
+
+func TestParseSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Makefile")
+	content := "help: ## Show help\n\n##@ Build\nbuild: ## Compile\n\n# Vet the code\nvet:\n\n##@  Release \nrelease:\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	targets, err := Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, target := range targets {
+		got[target.Name] = target.Section
+	}
+	want := map[string]string{"help": "", "build": "Build", "vet": "Build", "release": "Release"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sections = %v, want %v", got, want)
+	}
+	if targets[3].Comment != "" {
+		t.Errorf("release comment = %q, want the header left out", targets[3].Comment)
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Makefile")
+	content := "# @args ENV=dev  REGION=eu \n# Deploy the app\ndeploy::\n\ndeploy::\n\t./deploy\nbuild:\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	targets, err := Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := targets[0]; d.Name != "deploy" || d.Args != "ENV=dev  REGION=eu" || d.Comment != "Deploy the app" {
+		t.Errorf("deploy = %+v, want its @args and comment", d)
+	}
+	if len(targets) != 2 || targets[1].Args != "" {
+		t.Errorf("targets = %+v, want deploy once and build without args", targets)
+	}
+}
+
+func TestParseRequires(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Makefile")
+	content := "# @requires ENV, REGION\n# @requires TOKEN\ndeploy:\n\nbuild:\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	targets, err := Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := targets[0].Requires, []string{"ENV", "REGION", "TOKEN"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("deploy requires %q, want %q", got, want)
+	}
+	if got := targets[1].Requires; got != nil {
+		t.Errorf("build requires %q, want nothing", got)
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	const url = "https://example.com/Makefile"
+	content := ".PHONY: test\n# Build it\nbuild: gen\n\tgo build\ninclude common.mk\ntest:\n\tgo test\n"
+	targets, err := ParseReader(url, strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 || targets[0].Name != "build" || targets[1].Name != "test" {
+		t.Fatalf("ParseReader = %+v, want build and test, with the include skipped", targets)
+	}
+	if b := targets[0]; b.Comment != "Build it" || !b.IsDefault || b.File != url || b.Recipe != "go build" {
+		t.Errorf("build = %+v", b)
+	}
+	if !targets[1].IsPhony {
+		t.Error("test is not phony")
+	}
+
+	if _, err := ParseReader(url, strings.NewReader("a:\x00\n")); err == nil {
+		t.Error("ParseReader accepted binary content")
+	}
+}
@@ -0,0 +1,127 @@
+package makefile
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Variable is a make variable assigned in a Makefile.
+type Variable struct {
+	Name string
+	// Op is the assignment operator that set the value: "=" for a
+	// recursively expanded variable, ":=" or "::=" for a simply expanded
+	// one, "?=" for a default, "!=" for a shell assignment, or "+=" when
+	// the variable was only ever appended to.
+	Op string
+	// Value is the unexpanded value; appends are joined to it with a space.
+	Value string
+	// File and Line locate the assignment that set Op.
+	File string
+	Line int
+}
+
+// variableRe matches a variable assignment, with the modifiers make allows
+// before it.
+var variableRe = regexp.MustCompile(`^\s*(?:(?:override|export|private)\s+)*([A-Za-z0-9_.-]+)\s*(::=|:=|\?=|\+=|!=|=)\s*(.*)$`)
+
+// Variables returns the variables the Makefile at path and its includes
+// assign, in the order they are first assigned, with their values as make
+// would have them at the end of parsing: later assignments replace earlier
+// ones, "?=" only sets unset variables and "+=" appends.
+func Variables(path string) ([]Variable, error) {
+	return Options{}.Variables(path)
+}
+
+// Variables is the package's Variables with o.
+func (o Options) Variables(path string) ([]Variable, error) {
+	p, err := o.parse(path)
+	if err != nil {
+		return nil, err
+	}
+	return p.variables, nil
+}
+
+// assign records the assignment matched by variableRe in the line of file.
+func (p *parser) assign(m []string, file string, line int) {
+	name, op, value := m[1], m[2], stripComment(m[3])
+	i, ok := p.varIndex[name]
+	if !ok {
+		p.varIndex[name] = len(p.variables)
+		p.variables = append(p.variables, Variable{Name: name, Op: op, Value: value, File: file, Line: line})
+		return
+	}
+	v := &p.variables[i]
+	switch op {
+	case "?=":
+	case "+=":
+		if v.Value != "" && value != "" {
+			v.Value += " "
+		}
+		v.Value += value
+	default:
+		*v = Variable{Name: name, Op: op, Value: value, File: file, Line: line}
+	}
+}
+
+// stripComment drops a trailing comment from a variable's value, as make
+// does unless the # is escaped.
+func stripComment(value string) string {
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' {
+			i++
+		} else if value[i] == '#' {
+			value = value[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(strings.ReplaceAll(value, `\#`, "#"))
+}
+
+// maxExpandDepth bounds the nesting Expand follows, so a variable referring
+// to itself cannot loop.
+const maxExpandDepth = 16
+
+// Expand substitutes the references to the variables in vars found in
+// value, $(NAME), ${NAME} and $N for one-letter names, recursively. It is a
+// best effort: functions such as $(shell ...), automatic variables and
+// unknown names are left as written, and "$$" stays an escaped dollar.
+func Expand(value string, vars []Variable) string {
+	lookup := make(map[string]string, len(vars))
+	for _, v := range vars {
+		lookup[v.Name] = v.Value
+	}
+	return expand(value, lookup, 0)
+}
+
+func expand(value string, vars map[string]string, depth int) string {
+	if depth > maxExpandDepth || !strings.Contains(value, "$") {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' || i+1 == len(value) {
+			b.WriteByte(value[i])
+			continue
+		}
+		ref, end := value[i+1:i+2], i+2
+		if open := value[i+1]; open == '(' || open == '{' {
+			closing := byte(')')
+			if open == '{' {
+				closing = '}'
+			}
+			j := strings.IndexByte(value[i+2:], closing)
+			if j < 0 {
+				b.WriteString(value[i:])
+				break
+			}
+			ref, end = value[i+2:i+2+j], i+3+j
+		}
+		if v, ok := vars[ref]; ok && ref != "$" {
+			b.WriteString(expand(v, vars, depth+1))
+		} else {
+			b.WriteString(value[i:end])
+		}
+		i = end - 1
+	}
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// writeMarkdown documents tabs as Markdown: a section per non-empty tab with
+// a table of its targets and their descriptions.
+func writeMarkdown(w io.Writer, tabs []Tab) error {
+	if _, err := fmt.Fprintln(w, "# Makefile targets"); err != nil {
+		return err
+	}
+	for _, tab := range tabs {
+		if len(tab.Options) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n## %s\n\n", tab.Name)
+		fmt.Fprintln(w, "| Target | Description |")
+		fmt.Fprintln(w, "| --- | --- |")
+		for _, opt := range tab.Options {
+			target := "`" + opt.Target + "`"
+			if opt.AliasOf != "" {
+				target += " (alias of `" + opt.AliasOf + "`)"
+			}
+			if _, err := fmt.Fprintf(w, "| %s | %s |\n", target, markdownCell(opt.Comment)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// markdownCell escapes text for use inside a Markdown table cell.
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	text = strings.ReplaceAll(text, "|", `\|`)
+	return strings.ReplaceAll(text, "\n", "<br>")
+}
+
+// markdownTags renders the small part of Markdown target comments use,
+// **bold**, `code` spans and "-" or "*" bullets, as tview color tags.
+// Anything else, including markers without a closing pair, is shown as
+// written.
+func markdownTags(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		rest := strings.TrimLeft(line, " ")
+		indent := line[:len(line)-len(rest)]
+		if item, ok := strings.CutPrefix(rest, "- "); ok {
+			lines[i] = indent + "  • " + markdownInline(item)
+		} else if item, ok := strings.CutPrefix(rest, "* "); ok {
+			lines[i] = indent + "  • " + markdownInline(item)
+		} else {
+			lines[i] = markdownInline(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markdownInline renders the bold and code spans of one line, escaping
+// the rest.
+func markdownInline(line string) string {
+	var b strings.Builder
+	plain := 0 // start of the text not yet written
+	for i := 0; i < len(line); {
+		var open, tag, closeTag string
+		switch {
+		case strings.HasPrefix(line[i:], "`"):
+			open, tag, closeTag = "`", "[yellow]", "[-]"
+		case strings.HasPrefix(line[i:], "**"):
+			open, tag, closeTag = "**", "[::b]", "[::-]"
+		default:
+			i++
+			continue
+		}
+		end := strings.Index(line[i+len(open):], open)
+		inner := ""
+		if end >= 0 {
+			inner = line[i+len(open) : i+len(open)+end]
+		}
+		// Bold needs text hugging its markers, as in Markdown itself.
+		if end < 0 || inner == "" || open == "**" && strings.TrimSpace(inner) != inner {
+			i += len(open)
+			continue
+		}
+		b.WriteString(tview.Escape(line[plain:i]))
+		b.WriteString(tag + tview.Escape(inner) + closeTag)
+		i += len(open) + end + len(open)
+		plain = i
+	}
+	b.WriteString(tview.Escape(line[plain:]))
+	return b.String()
+}
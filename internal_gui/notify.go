@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// notify shows a desktop notification using the tools each platform ships
+// with: osascript on macOS, PowerShell on Windows and notify-send elsewhere.
+func notify(title, message string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := `display notification "` + appleScriptEscape(message) + `" with title "` + appleScriptEscape(title) + `"`
+		cmd = exec.Command("osascript", "-e", script)
+	case "windows":
+		script := `Add-Type -AssemblyName System.Windows.Forms; ` +
+			`$n = New-Object System.Windows.Forms.NotifyIcon; ` +
+			`$n.Icon = [System.Drawing.SystemIcons]::Information; $n.Visible = $true; ` +
+			`$n.ShowBalloonTip(5000, '` + powerShellEscape(title) + `', '` + powerShellEscape(message) + `', 'Info'); ` +
+			`Start-Sleep -Seconds 6; $n.Dispose()`
+		cmd = exec.Command("powershell", "-NoProfile", "-Command", script)
+	default:
+		cmd = exec.Command("notify-send", title, message)
+	}
+	return cmd.Run()
+}
+
+func appleScriptEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+func powerShellEscape(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// openInFileManager opens dir in the platform's file manager. It fails when
+// no opener is installed so callers can fall back to showing the path.
+func openInFileManager(dir string) error {
+	opener := "xdg-open"
+	switch runtime.GOOS {
+	case "darwin":
+		opener = "open"
+	case "windows":
+		opener = "explorer"
+	}
+	if _, err := exec.LookPath(opener); err != nil {
+		return fmt.Errorf("no file manager opener found (%s)", opener)
+	}
+	// Start rather than Run: the opener may stay alive with the window, and
+	// explorer reports a non-zero status even on success.
+	return exec.Command(opener, dir).Start()
+}
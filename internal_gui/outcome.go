@@ -0,0 +1,82 @@
+package main
+
+import "sync"
+
+// Outcome is the result of the most recent run of a target.
+type Outcome int
+
+const (
+	OutcomeNone Outcome = iota // not run this session
+	OutcomePassed
+	OutcomeFailed
+)
+
+// outcomeText is the text cue for o shown ahead of a label in accessible
+// mode, where color alone must not tell passed and failed runs apart.
+func outcomeText(o Outcome) string {
+	switch o {
+	case OutcomePassed:
+		return "(ok) "
+	case OutcomeFailed:
+		return "(failed) "
+	}
+	return ""
+}
+
+// Outcomes records the last outcome of each target during a session. It is
+// safe for concurrent use since runs finish on background goroutines.
+type Outcomes struct {
+	mu       sync.Mutex
+	byTarget map[string]Outcome
+}
+
+// Record stores the result of running target.
+func (o *Outcomes) Record(target string, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.byTarget == nil {
+		o.byTarget = make(map[string]Outcome)
+	}
+	if err != nil {
+		o.byTarget[target] = OutcomeFailed
+	} else {
+		o.byTarget[target] = OutcomePassed
+	}
+}
+
+// Get returns the last outcome for opt, sharing it between an alias and the
+// target it points to.
+func (o *Outcomes) Get(opt MakeOption) Outcome {
+	target := opt.Target
+	if opt.AliasOf != "" {
+		target = opt.AliasOf
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.byTarget[target]
+}
+
+// ForgetChanged drops the outcome of every target whose recipe differs
+// between before and after, so a reloaded Makefile does not show results
+// that no longer apply.
+func (o *Outcomes) ForgetChanged(before, after []Tab) {
+	recipes := func(tabs []Tab) map[string]string {
+		m := make(map[string]string)
+		for _, t := range tabs {
+			for _, opt := range t.Options {
+				if opt.AliasOf == "" {
+					m[opt.Target] = opt.Recipe
+				}
+			}
+		}
+		return m
+	}
+	old, cur := recipes(before), recipes(after)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for target := range o.byTarget {
+		if recipe, ok := cur[target]; !ok || recipe != old[target] {
+			delete(o.byTarget, target)
+		}
+	}
+}
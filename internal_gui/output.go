@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+
+	"github.com/rivo/tview"
+)
+
+// escapeWriter escapes tview style tags in command output so text such as
+// "[Makefile] done" is shown verbatim instead of being parsed as a color.
+type escapeWriter struct {
+	w io.Writer
+}
+
+func (e escapeWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(e.w, tview.Escape(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// dirBannerRe matches the lines a recursive make prints around each
+// sub-make, e.g. "make[1]: Entering directory '/src/lib'".
+var dirBannerRe = regexp.MustCompile(`^g?make(\[\d+\])?: (Entering|Leaving) directory `)
+
+// dirBannerWriter dims make's directory banner lines, or drops them when hide
+// is set, so the real output stands out. Everything else passes through
+// unchanged as soon as it is written; only a partial line that may still
+// turn out to be a banner is held back until its newline arrives.
+type dirBannerWriter struct {
+	w       io.Writer
+	hide    bool
+	pending []byte
+}
+
+func (d *dirBannerWriter) Write(p []byte) (int, error) {
+	d.pending = append(d.pending, p...)
+	var out []byte
+	for {
+		i := bytes.IndexByte(d.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := d.pending[:i+1]
+		switch {
+		case !dirBannerRe.Match(line):
+			out = append(out, line...)
+		case !d.hide:
+			out = append(out, "\x1b[2m"...)
+			out = append(out, line[:i]...)
+			out = append(out, "\x1b[0m\n"...)
+		}
+		d.pending = d.pending[i+1:]
+	}
+	if !mayBeBanner(d.pending) {
+		out = append(out, d.pending...)
+		d.pending = nil
+	}
+	if len(out) > 0 {
+		if _, err := d.w.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// flush writes the partial line held back, if any, as it is: the run has
+// ended, so it will not become a banner. A highlightWriter it writes to is
+// flushed too.
+func (d *dirBannerWriter) flush() {
+	if len(d.pending) > 0 {
+		d.w.Write(d.pending)
+		d.pending = nil
+	}
+	if h, ok := d.w.(*highlightWriter); ok {
+		h.flush()
+	}
+}
+
+// mayBeBanner reports whether the partial line b could still become a
+// directory banner. Prompts and other output without a newline are not held
+// back because they do not start like make's own messages.
+func mayBeBanner(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	for _, prefix := range []string{"make", "gmake"} {
+		n := len(b)
+		if n > len(prefix) {
+			n = len(prefix)
+		}
+		if string(b[:n]) == prefix[:n] {
+			return true
+		}
+	}
+	return false
+}
+
+// newPaneWriter returns a writer that renders command output, including
+// ANSI colors, into view. Directory banners are dimmed, or dropped when
+// hideDirs is set. With highlight, structured output is colored, see
+// highlightWriter. problems, when not nil, is fed the output to pick out
+// errors and warnings; view must then have regions enabled. The writer's
+// flush is called as each run ends.
+func newPaneWriter(view *tview.TextView, hideDirs, highlight bool, problems *problemWriter) *dirBannerWriter {
+	w := tview.ANSIWriter(view)
+	if problems != nil {
+		problems.w = w
+		w = problems
+	}
+	w = escapeWriter{w}
+	if highlight {
+		w = &highlightWriter{w: w}
+	}
+	return &dirBannerWriter{w: w, hide: hideDirs}
+}
+
+// repeatWriter collapses runs of identical consecutive lines, such as
+// retries, into the first line followed by "⟲ line (×N)" once the run ends.
+// While a run goes on, onRepeat is told the count so far so it can be shown
+// live; it is told 0 when the run ends. A partial line that may still
+// repeat the last one is held back until its newline.
+type repeatWriter struct {
+	w        io.Writer
+	onRepeat func(count int)
+	last     []byte // last complete line, without its newline
+	count    int    // consecutive occurrences of last
+	line     []byte // the line being written
+	sent     int    // bytes of line already passed through
+}
+
+func (r *repeatWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	var out []byte
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			r.line = append(r.line, p...)
+			if r.sent == 0 && r.last != nil && bytes.HasPrefix(r.last, r.line) {
+				break
+			}
+			out = r.endRepeats(out)
+			out = append(out, r.line[r.sent:]...)
+			r.sent = len(r.line)
+			break
+		}
+		r.line = append(r.line, p[:i]...)
+		p = p[i+1:]
+		if r.sent == 0 && r.last != nil && bytes.Equal(r.line, r.last) {
+			r.count++
+			if r.onRepeat != nil {
+				r.onRepeat(r.count)
+			}
+		} else {
+			out = r.endRepeats(out)
+			out = append(append(out, r.line[r.sent:]...), '\n')
+			r.last, r.count = append(r.last[:0], r.line...), 1
+		}
+		r.line, r.sent = r.line[:0], 0
+	}
+	if len(out) > 0 {
+		if _, err := r.w.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// endRepeats appends the marker for the run of repeats just ended, if any.
+func (r *repeatWriter) endRepeats(out []byte) []byte {
+	if r.count > 1 {
+		out = append(out, fmt.Sprintf("\x1b[2m⟲ %s (×%d)\x1b[0m\n", r.last, r.count)...)
+		if r.onRepeat != nil {
+			r.onRepeat(0)
+		}
+	}
+	r.count = 1
+	return out
+}
+
+// flush ends the current run of repeats and writes any held partial line,
+// so the next command's output does not continue it. A nil r does nothing.
+func (r *repeatWriter) flush() {
+	if r == nil {
+		return
+	}
+	out := r.endRepeats(nil)
+	out = append(out, r.line[r.sent:]...)
+	r.last, r.line, r.sent = nil, r.line[:0], 0
+	if len(out) > 0 {
+		r.w.Write(out)
+	}
+}
+
+// defaultOutputMaxLines caps the output pane at roughly a few megabytes of
+// typical build output.
+const defaultOutputMaxLines = 50000
+
+// lineLimitWriter counts the lines written through it and calls onExceed
+// once when there are more than max, so a truncation notice can be shown.
+// The pane itself drops the oldest lines, see tview.TextView.SetMaxLines.
+type lineLimitWriter struct {
+	w        io.Writer
+	max      int
+	lines    int
+	onExceed func()
+}
+
+func (l *lineLimitWriter) Write(p []byte) (int, error) {
+	before := l.lines
+	l.lines += bytes.Count(p, []byte("\n"))
+	if before <= l.max && l.lines > l.max && l.onExceed != nil {
+		l.onExceed()
+	}
+	return l.w.Write(p)
+}
+
+// tagWriter prefixes every line written to it with tag, such as "[build] ",
+// so the output of several targets can be told apart. Writers sharing mu
+// write whole lines only, keeping concurrent runs from interleaving within
+// a line; a trailing partial line is written by flush.
+type tagWriter struct {
+	w       io.Writer
+	tag     string
+	mu      *sync.Mutex
+	pending []byte
+}
+
+func (t *tagWriter) Write(p []byte) (int, error) {
+	t.pending = append(t.pending, p...)
+	i := bytes.LastIndexByte(t.pending, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	lines := bytes.SplitAfter(t.pending[:i+1], []byte("\n"))
+	t.pending = append([]byte(nil), t.pending[i+1:]...)
+	var out []byte
+	for _, line := range lines {
+		if len(line) > 0 {
+			out = append(out, t.tag...)
+			out = append(out, line...)
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := t.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// flush writes any partial last line.
+func (t *tagWriter) flush() {
+	if len(t.pending) > 0 {
+		t.Write([]byte("\n"))
+	}
+}
+
+// lockedWriter serializes the writes of concurrent runs to w, whose state,
+// like that of the output pane's writers, is not safe for concurrent use.
+type lockedWriter struct {
+	w  io.Writer
+	mu *sync.Mutex
+}
+
+func (l lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// lockStdio returns stdio with its output written under mu, see
+// lockedWriter.
+func lockStdio(stdio Stdio, mu *sync.Mutex) Stdio {
+	locked := Stdio{Stdin: stdio.Stdin}
+	if stdio.Stdout != nil {
+		locked.Stdout = lockedWriter{stdio.Stdout, mu}
+	}
+	if stdio.Stderr != nil {
+		locked.Stderr = lockedWriter{stdio.Stderr, mu}
+	}
+	return locked
+}
+
+// tagStdio returns stdio with its output tagged with target's name, and the
+// function that flushes it once the run is over. A shared Stdout and Stderr
+// stay shared so their lines keep their order.
+func tagStdio(stdio Stdio, target string, mu *sync.Mutex) (Stdio, func()) {
+	out := &tagWriter{w: stdio.Stdout, tag: "[" + target + "] ", mu: mu}
+	tagged := Stdio{Stdin: stdio.Stdin, Stdout: out, Stderr: out}
+	if stdio.Stderr != stdio.Stdout {
+		errOut := &tagWriter{w: stdio.Stderr, tag: out.tag, mu: mu}
+		tagged.Stderr = errOut
+		return tagged, func() { out.flush(); errOut.flush() }
+	}
+	return tagged, out.flush
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// overviewText summarizes tabs for the TUI's startup overview: how many
+// targets there are and how many are documented, the default goal, the
+// build tool in use and how many targets each category holds.
+func overviewText(tabs []Tab, tool string) string {
+	total := countTargets(tabs)
+	missing := 0
+	if i, err := findTab(tabs, undocumentedTab); err == nil {
+		missing = len(tabs[i].Options)
+	}
+	goal := "none"
+	for _, t := range tabs {
+		for _, opt := range t.Options {
+			if opt.IsDefault {
+				goal = opt.Target
+			}
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Targets:       %d\n", total)
+	fmt.Fprintf(&b, "Documented:    %d, undocumented: %d\n", total-missing, missing)
+	fmt.Fprintf(&b, "Default goal:  %s\n", goal)
+	fmt.Fprintf(&b, "Build tool:    %s\n", tool)
+	var categories []string
+	for _, t := range tabs {
+		if !pinnedTabs[t.Name] && len(t.Options) > 0 {
+			categories = append(categories, fmt.Sprintf("  %-20s %d", t.Name, len(t.Options)))
+		}
+	}
+	if len(categories) > 0 {
+		b.WriteString("\nCategories:\n" + strings.Join(categories, "\n") + "\n")
+	}
+	return b.String()
+}
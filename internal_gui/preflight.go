@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// syntaxErrorRe matches the errors make reports while reading a Makefile,
+// such as "Makefile:12: *** missing separator.  Stop.", as opposed to the
+// "make: ***" ones about what to build.
+var syntaxErrorRe = regexp.MustCompile(`(?m)^[^\s:][^:]*:\d+: \*\*\* .*$`)
+
+// preflight checks, when Preflight is set, that make accepts the Makefile
+// before a target runs, so a broken Makefile is reported as such rather
+// than through a confusing failure part way. The result is kept until one
+// of the files make read changes. Other build tools, launcher entries,
+// remote Makefiles and targets run in a container, whose make may differ
+// from the host's, are not checked.
+func (r *Runner) preflight(target string) error {
+	if !r.Preflight || len(r.Launch) > 0 || !isGNUMake(r.Program()) || r.containerImage(target) != "" || isRemoteMakefile(r.Makefile) {
+		return nil
+	}
+	r.preflightMu.Lock()
+	defer r.preflightMu.Unlock()
+	if r.preflightStamps != nil && freshStamps(r.preflightStamps) {
+		return r.preflightErr
+	}
+	// Stamped ahead of the check, so an edit made meanwhile is checked
+	// next time.
+	_, sources, err := r.ParseOptions.ParseSources(r.Makefile)
+	if err != nil {
+		sources = []string{r.Makefile}
+	}
+	stamps := make(map[string]sourceStamp, len(sources))
+	for _, source := range sources {
+		if abs, err := filepath.Abs(source); err == nil {
+			source = abs
+		}
+		stamps[source] = stampOf(source)
+	}
+	r.preflightErr = r.checkSyntax()
+	r.preflightStamps = stamps
+	return r.preflightErr
+}
+
+// checkSyntax has make read the Makefile without running anything and
+// returns the errors it reports about the file itself. A make that cannot
+// be started leaves the question open, and the run goes ahead.
+func (r *Runner) checkSyntax() error {
+	args := append([]string(nil), r.Command...)
+	if len(args) == 0 {
+		args = r.makeCommand()
+	}
+	args = append(args, r.Flags...)
+	args = append(args, "--dry-run", "--question")
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = r.Dir()
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	// A non-zero status is expected: --question fails when anything needs
+	// building.
+	cmd.Run()
+	if errs := syntaxErrorRe.FindAllString(out.String(), -1); len(errs) > 0 {
+		diag.warnf("preflight: make rejects %s", r.Makefile)
+		return fmt.Errorf("make rejects the Makefile, nothing was run:\n%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
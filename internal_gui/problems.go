@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// problemPresets are the error and warning line patterns of common
+// toolchains, selected by name with -problems.
+var problemPresets = map[string][]string{
+	// gcc and clang: "file.c:12:5: error: ...", including fatal errors.
+	"gcc": {`^[^\s:]+:\d+(:\d+)?: (fatal error|error|warning): `},
+	// The go compiler and vet ("file.go:12:5: ..."), failed tests, whose
+	// messages are indented, and panics.
+	"go": {`^\s*[^\s:]+\.go:\d+(:\d+)?: `, `^--- FAIL: `, `^panic: `},
+	// rustc and cargo: "error[E0308]: ..." and "warning: ...".
+	"rust": {`^(error|warning)(\[[A-Z]\d+\])?: `},
+}
+
+// presetNames lists the problemPresets for messages.
+func presetNames() string {
+	names := make([]string, 0, len(problemPresets))
+	for name := range problemPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// Problem is an error or warning line found in a run's output.
+type Problem struct {
+	Region  string // the pane region holding the line, see problemWriter
+	Text    string // the line, without colors
+	Warning bool   // the line mentions a warning rather than an error
+}
+
+// problemMatcher recognizes error and warning lines.
+type problemMatcher []*regexp.Regexp
+
+// newProblemMatcher compiles the patterns of the named presets and the
+// extra regular expressions.
+func newProblemMatcher(presets, patterns []string) (problemMatcher, error) {
+	var m problemMatcher
+	for _, name := range presets {
+		preset, ok := problemPresets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown problem preset %q (available: %s)", name, presetNames())
+		}
+		patterns = append(patterns, preset...)
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("problem pattern %q: %w", pattern, err)
+		}
+		m = append(m, re)
+	}
+	return m, nil
+}
+
+// match returns the Problem line is, if any.
+func (m problemMatcher) match(line string) (Problem, bool) {
+	for _, re := range m {
+		if re.MatchString(line) {
+			return Problem{Text: line, Warning: strings.Contains(strings.ToLower(line), "warning")}, true
+		}
+	}
+	return Problem{}, false
+}
+
+// ansiRe matches the ANSI escape sequences stripped before matching.
+var ansiRe = regexp.MustCompile(`\x1b\[[0-9;?]*[@-~]`)
+
+// problemWriter sits between escapeWriter and the pane's ANSI writer. It
+// wraps every line in a region of its own, "l1", "l2" and so on, so a line
+// can be highlighted and scrolled to however it wraps, and reports the
+// lines matcher recognizes to onProblem once they are complete. Lines are
+// passed on as they arrive, even in pieces.
+type problemWriter struct {
+	w         io.Writer
+	matcher   problemMatcher
+	onProblem func(Problem)
+	lines     int    // regions opened so far
+	open      bool   // a line's region is open
+	line      []byte // the open line's text, for matching
+}
+
+func (p *problemWriter) Write(b []byte) (int, error) {
+	n := len(b)
+	var out []byte
+	for len(b) > 0 {
+		if !p.open {
+			p.lines++
+			p.open = true
+			out = append(out, fmt.Sprintf(`["l%d"]`, p.lines)...)
+		}
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			p.line = append(p.line, b...)
+			out = append(out, b...)
+			break
+		}
+		p.line = append(p.line, b[:i]...)
+		out = append(append(out, b[:i]...), `[""]`+"\n"...)
+		b = b[i+1:]
+		p.open = false
+		p.check()
+	}
+	if _, err := p.w.Write(out); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// check reports the line just completed if it is a problem.
+func (p *problemWriter) check() {
+	// Undo escapeWriter, which turned "[x]" into "[x[]", and drop colors.
+	text := strings.ReplaceAll(string(p.line), "[]", "]")
+	text = strings.TrimRight(ansiRe.ReplaceAllString(text, ""), "\r")
+	p.line = p.line[:0]
+	if problem, ok := p.matcher.match(text); ok && p.onProblem != nil {
+		problem.Region = fmt.Sprintf("l%d", p.lines)
+		p.onProblem(problem)
+	}
+}
+
+// reset starts counting lines afresh for a cleared pane.
+func (p *problemWriter) reset() {
+	p.lines, p.open, p.line = 0, false, p.line[:0]
+}
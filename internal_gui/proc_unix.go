@@ -0,0 +1,27 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts cmd in its own process group so that cancelling
+// it also stops the commands make spawned.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// setTerminalSession starts cmd in a new session with its stdin, a
+// pseudo-terminal, as the controlling terminal. A session leader is also
+// the leader of its own process group, so killProcessGroup still works.
+func setTerminalSession(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+}
+
+// killProcessGroup asks cmd and everything in its process group to stop.
+// SIGTERM gives make the chance to delete half-built targets.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
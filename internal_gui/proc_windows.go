@@ -0,0 +1,18 @@
+package main
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup is a no-op on Windows; killProcessGroup walks the process
+// tree instead.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// setTerminalSession is never needed on Windows, which has no ptys.
+func setTerminalSession(cmd *exec.Cmd) {}
+
+// killProcessGroup stops cmd and the processes it started.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
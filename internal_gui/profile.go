@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TabRule describes one tab of a categorization profile. A target lands in
+// the first tab of the profile whose rule it matches.
+type TabRule struct {
+	Name     string   `yaml:"name"`
+	Contains []string `yaml:"contains"` // substrings of the target name
+	Prefix   []string `yaml:"prefix"`   // prefixes of the target name
+}
+
+func (r TabRule) matches(target string) bool {
+	for _, s := range r.Contains {
+		if strings.Contains(target, s) {
+			return true
+		}
+	}
+	for _, p := range r.Prefix {
+		if strings.HasPrefix(target, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// categorizeWithRules sorts options into one tab per rule, in rule order.
+// Like categorizeOptions, targets matching no rule are left out and
+// @category annotations take precedence over the rules.
+func categorizeWithRules(options []MakeOption, rules []TabRule) []Tab {
+	tabs := make([]Tab, len(rules))
+	for i, rule := range rules {
+		tabs[i].Name = rule.Name
+	}
+	for _, opt := range options {
+		if opt.Category != "" {
+			continue
+		}
+		name := opt.Target
+		if opt.AliasOf != "" {
+			name = opt.AliasOf
+		}
+		for i, rule := range rules {
+			if rule.matches(name) {
+				diag.debugf("categorized %s as %s: matches the profile rule", opt.Target, rule.Name)
+				tabs[i].Options = append(tabs[i].Options, opt)
+				break
+			}
+		}
+	}
+	return addCategorized(tabs, options)
+}
+
+// profileNames lists the profiles in cfg in the order they are cycled
+// through, starting with "" for the built-in categorization.
+func profileNames(cfg *Config) []string {
+	names := []string{""}
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// checkProfile reports an error when profile is not defined in cfg. The
+// empty name always refers to the built-in categorization.
+func checkProfile(cfg *Config, profile string) error {
+	if _, ok := cfg.Profiles[profile]; profile != "" && !ok {
+		return fmt.Errorf("no profile named %q in config", profile)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxProjectDepth is how many directories deep under the projects root
+// findProjects looks.
+const maxProjectDepth = 3
+
+// maxPickerRows caps the matches pickProject lists at once.
+const maxPickerRows = 15
+
+// skippedProjectDirs are never searched for projects: dependencies and
+// build output hold build files that are not the user's projects.
+var skippedProjectDirs = map[string]bool{"node_modules": true, "vendor": true, "target": true, "dist": true, "build": true}
+
+// findProjects returns the directories under root, root included, that
+// have a build file CoolBox recognizes, in walk order. A project's own
+// subdirectories are not searched, nor are hidden directories, those in
+// skippedProjectDirs or any deeper than maxProjectDepth. Unreadable
+// directories are skipped.
+func findProjects(root string) []string {
+	var found []string
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if path != root && (strings.HasPrefix(name, ".") || skippedProjectDirs[name]) {
+			return filepath.SkipDir
+		}
+		if len(detectBuildTools(path)) > 0 {
+			found = append(found, path)
+			return filepath.SkipDir
+		}
+		if rel, err := filepath.Rel(root, path); err == nil && rel != "." && strings.Count(rel, string(filepath.Separator))+1 >= maxProjectDepth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return found
+}
+
+// projectChoices merges the recent projects, most recent first, with those
+// found under the projects root, dropping duplicates and the recent
+// directories that no longer have a build file. It also returns how many
+// were dropped that way.
+func projectChoices(recent, found []string) ([]string, int) {
+	seen := make(map[string]bool)
+	var choices []string
+	missing := 0
+	for _, dir := range recent {
+		if len(detectBuildTools(dir)) == 0 {
+			missing++
+			continue
+		}
+		seen[dir] = true
+		choices = append(choices, dir)
+	}
+	for _, dir := range found {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			choices = append(choices, dir)
+		}
+	}
+	return choices, missing
+}
+
+// fuzzyScore reports whether the letters of query appear in s in order,
+// ignoring case, and how well: consecutive letters and letters starting a
+// path element or word score higher, as does a match near the end, where
+// a project's own name is.
+func fuzzyScore(query, s string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	if len(q) == 0 {
+		return 0, true
+	}
+	text := []rune(s)
+	score, last := 0, -1
+	// Matching from the end favors the last path element.
+	qi := len(q) - 1
+	for i := len(text) - 1; i >= 0 && qi >= 0; i-- {
+		if unicode.ToLower(text[i]) != q[qi] {
+			continue
+		}
+		score++
+		if last == i+1 {
+			score += 3
+		}
+		if i == 0 || strings.ContainsRune("/\\-_. ", text[i-1]) {
+			score += 2
+		}
+		last = i
+		qi--
+	}
+	if qi >= 0 {
+		return 0, false
+	}
+	return score*100 - (len(text) - last), true
+}
+
+// fuzzyFilter returns the choices matching query, best first; ties keep
+// the order of choices, so recent projects lead.
+func fuzzyFilter(query string, choices []string) []string {
+	type match struct {
+		choice string
+		score  int
+	}
+	var matches []match
+	for _, choice := range choices {
+		if score, ok := fuzzyScore(query, choice); ok {
+			matches = append(matches, match{choice, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	result := make([]string, len(matches))
+	for i, m := range matches {
+		result[i] = m.choice
+	}
+	return result
+}
+
+// pickProject lets the user type to narrow choices down and pick one by
+// its number, reading answers from in and writing to out. An empty answer
+// takes the best match. It reports false at the end of input.
+func pickProject(choices []string, in io.Reader, out io.Writer) (string, bool) {
+	scanner := bufio.NewScanner(in)
+	query := ""
+	for {
+		matches := fuzzyFilter(query, choices)
+		if len(matches) == 0 {
+			fmt.Fprintf(out, "No projects match %q.\n", query)
+		}
+		for i, dir := range matches {
+			if i == maxPickerRows {
+				fmt.Fprintf(out, "  … %d more, type to narrow down\n", len(matches)-i)
+				break
+			}
+			fmt.Fprintf(out, "  %d) %s\n", i+1, dir)
+		}
+		fmt.Fprint(out, "Project (number, or letters to search): ")
+		if !scanner.Scan() {
+			return "", false
+		}
+		answer := strings.TrimSpace(scanner.Text())
+		if answer == "" && len(matches) > 0 {
+			return matches[0], true
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(matches) && n <= maxPickerRows {
+			return matches[n-1], true
+		}
+		query = answer
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/creack/pty"
+)
+
+// ptyPair is an open pseudo-terminal: the controlling side CoolBox reads
+// and writes, and the terminal the command is attached to.
+type ptyPair struct {
+	pty, tty *os.File
+}
+
+// openPTY opens a pseudo-terminal for a run when r.PTY is set, sized by
+// r.TermSize. It reports false when ptys are off or unavailable, as on
+// Windows, and the run uses pipes instead.
+func (r *Runner) openPTY() (ptyPair, bool) {
+	if !r.PTY {
+		return ptyPair{}, false
+	}
+	p, tty, err := pty.Open()
+	if err != nil {
+		return ptyPair{}, false
+	}
+	if r.TermSize != nil {
+		if cols, rows := r.TermSize(); cols > 0 && rows > 0 {
+			pty.Setsize(p, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)})
+		}
+	}
+	return ptyPair{p, tty}, true
+}
+
+// Resize gives the ptys of the running commands a new size, which sends
+// them SIGWINCH as a resized terminal would, so full-screen and progress
+// output redraws to fit.
+func (r *Runner) Resize(cols, rows int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for p := range r.ptys {
+		pty.Setsize(p, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)})
+	}
+}
+
+// startOnPTY starts cmd attached to pair's terminal, copying its stdin to
+// the terminal and everything the terminal shows, stdout and stderr alike,
+// to its stdout. The returned function waits for the command and for the
+// last output to be copied.
+func startOnPTY(cmd *exec.Cmd, pair ptyPair) (func() error, error) {
+	in, out := cmd.Stdin, cmd.Stdout
+	if out == nil {
+		out = io.Discard
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = pair.tty, pair.tty, pair.tty
+	setTerminalSession(cmd)
+	err := cmd.Start()
+	// The command has its own descriptor for the terminal now; closing
+	// ours lets reads from the pty end once the command exits.
+	pair.tty.Close()
+	if err != nil {
+		pair.pty.Close()
+		return nil, err
+	}
+	if in != nil {
+		go io.Copy(pair.pty, in)
+	}
+	copied := make(chan struct{})
+	go func() {
+		// Linux reports EIO rather than EOF once the terminal is closed.
+		crlf := &crlfWriter{w: out}
+		io.Copy(crlf, pair.pty)
+		crlf.flush()
+		close(copied)
+	}()
+	return func() error {
+		err := cmd.Wait()
+		<-copied
+		pair.pty.Close()
+		return err
+	}, nil
+}
+
+// crlfWriter turns the "\r\n" line endings a terminal produces back into
+// "\n". A lone "\r", as progress bars use, passes through.
+type crlfWriter struct {
+	w  io.Writer
+	cr bool // the last write ended in "\r"
+}
+
+func (c *crlfWriter) Write(p []byte) (int, error) {
+	buf := p
+	if c.cr {
+		buf = append([]byte("\r"), p...)
+	}
+	c.cr = bytes.HasSuffix(buf, []byte("\r"))
+	if c.cr {
+		buf = buf[:len(buf)-1]
+	}
+	if _, err := c.w.Write(bytes.ReplaceAll(buf, []byte("\r\n"), []byte("\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// flush writes a held-back trailing "\r".
+func (c *crlfWriter) flush() {
+	if c.cr {
+		c.w.Write([]byte("\r"))
+		c.cr = false
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// recipePrefixes lists make's recipe line prefixes and what they mean.
+var recipePrefixes = []struct {
+	prefix  string
+	meaning string
+}{
+	{"@", "not echoed"},
+	{"-", "errors ignored"},
+	{"+", "runs even with -n"},
+}
+
+// describeRecipe renders recipe for display. Each command's @, - and +
+// prefixes are moved into a gutter so the commands line up, followed by a
+// legend for the prefixes that occur.
+func describeRecipe(recipe string) string {
+	if recipe == "" {
+		return ""
+	}
+	used := make(map[string]bool)
+	var b strings.Builder
+	for _, line := range strings.Split(recipe, "\n") {
+		cmd := strings.TrimLeft(line, " \t")
+		var flags string
+		for cmd != "" && strings.ContainsAny(cmd[:1], "@-+") {
+			if !strings.Contains(flags, cmd[:1]) {
+				flags += cmd[:1]
+				used[cmd[:1]] = true
+			}
+			cmd = strings.TrimLeft(cmd[1:], " \t")
+		}
+		b.WriteString(flags + strings.Repeat(" ", 4-len(flags)) + cmd + "\n")
+	}
+	var legend []string
+	for _, p := range recipePrefixes {
+		if used[p.prefix] {
+			legend = append(legend, p.prefix+" "+p.meaning)
+		}
+	}
+	if len(legend) > 0 {
+		b.WriteString("\n" + strings.Join(legend, ", ") + "\n")
+	}
+	return b.String()
+}
+
+// targetSource returns the lines of opt's first rule, from the rule line to
+// the end of its recipe, verbatim behind a line-number gutter.
+func (r *Runner) targetSource(opt MakeOption) (string, error) {
+	if opt.Line == 0 {
+		return "", errors.New("the source lines of targets are only known for Makefiles")
+	}
+	file := opt.Source
+	if file == "" {
+		file = r.Makefile
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return sourceLines(string(data), opt.Line, opt.EndLine), nil
+}
+
+// sourceLines numbers lines first to last, counted from 1, of content.
+func sourceLines(content string, first, last int) string {
+	lines := strings.Split(strings.TrimPrefix(content, "\uFEFF"), "\n")
+	if last > len(lines) {
+		last = len(lines)
+	}
+	width := len(strconv.Itoa(last))
+	var b strings.Builder
+	for n := first; n <= last; n++ {
+		fmt.Fprintf(&b, "%*d │ %s\n", width, n, strings.TrimSuffix(lines[n-1], "\r"))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// keepScrollOnResize keeps the bordered, wrapping view on the same output
+// when the terminal is resized. tview wraps the text anew for the new width
+// but keeps the number of rows scrolled, which lands a pane scrolled back
+// on unrelated output, so the line that was at the top is found again; a
+// pane showing the end stays pinned there. resized, when set, is told each
+// new inner size, for a pty to follow.
+func keepScrollOnResize(view *tview.TextView, resized func(cols, rows int)) {
+	var lastWidth, lastHeight int
+	// The draw func runs ahead of the view's own drawing, while its text
+	// is still indexed for the old width.
+	view.SetDrawFunc(func(screen tcell.Screen, x, y, width, height int) (int, int, int, int) {
+		x, y, width, height = x+1, y+1, width-2, height-2 // inside the border
+		if width <= 0 || height <= 0 || (width == lastWidth && height == lastHeight) {
+			return x, y, width, height
+		}
+		if lastWidth > 0 && width != lastWidth {
+			row, _ := view.GetScrollOffset()
+			text := view.GetText(false)
+			if row+lastHeight >= wrappedRows(text, lastWidth) {
+				view.ScrollToEnd()
+			} else {
+				view.ScrollTo(reflowRow(text, row, lastWidth, width), 0)
+			}
+		}
+		lastWidth, lastHeight = width, height
+		if resized != nil {
+			resized(width, height)
+		}
+		return x, y, width, height
+	})
+}
+
+// regionTag matches the region tags tview takes out of a view's text.
+var regionTag = regexp.MustCompile(`\["[a-zA-Z0-9_,;: \-\.]*"\]`)
+
+// wrappedRows returns the rows a view with style tags and regions needs
+// for text when width columns wide, at least one. A final newline does not
+// start another row.
+func wrappedRows(text string, width int) int {
+	rows := 0
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		rows += lineRows(line, width)
+	}
+	return rows
+}
+
+// lineRows returns the rows line takes wrapped to width, at least one. It
+// breaks lines where a view does, without building one.
+func lineRows(line string, width int) int {
+	if rows := len(tview.WordWrap(regionTag.ReplaceAllString(line, ""), width)); rows > 0 {
+		return rows
+	}
+	return 1
+}
+
+// reflowRow maps row, the first row shown of text wrapped to oldWidth, to
+// the row that shows the same place once the text is wrapped to newWidth.
+// Within a wrapped line it keeps the same share of the line's rows.
+func reflowRow(text string, row, oldWidth, newWidth int) int {
+	oldTop, newTop := 0, 0
+	for _, line := range strings.Split(text, "\n") {
+		oldRows := lineRows(line, oldWidth)
+		newRows := lineRows(line, newWidth)
+		if oldTop+oldRows > row {
+			return newTop + (row-oldTop)*newRows/oldRows
+		}
+		oldTop += oldRows
+		newTop += newRows
+	}
+	return newTop
+}
+
+// fillListOnResize scrolls the bordered list back when it grows taller than
+// the items from its first shown one need, which would leave rows empty at
+// the bottom; tview itself only scrolls to keep the selection in view.
+// Each item takes rowsPerItem rows.
+func fillListOnResize(list *tview.List, rowsPerItem int) {
+	lastHeight := 0
+	list.SetDrawFunc(func(screen tcell.Screen, x, y, width, height int) (int, int, int, int) {
+		x, y, width, height = x+1, y+1, width-2, height-2 // inside the border
+		if lastHeight > 0 && height > lastHeight {
+			offset, column := list.GetOffset()
+			if fit := height / rowsPerItem; offset > 0 && list.GetItemCount()-offset < fit {
+				offset = list.GetItemCount() - fit
+				if offset < 0 {
+					offset = 0
+				}
+				list.SetOffset(offset, column)
+			}
+		}
+		lastHeight = height
+		return x, y, width, height
+	})
+}
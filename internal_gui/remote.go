@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"internal_gui/makefile"
+)
+
+// remoteTimeout bounds fetching a Makefile given by URL, from connecting
+// to reading the last byte.
+const remoteTimeout = 15 * time.Second
+
+// maxRemoteSize is the largest Makefile fetchMakefile reads.
+const maxRemoteSize = 8 << 20
+
+// errRemoteRun refuses runs of a Makefile read over HTTP that was not
+// mapped to a local checkout.
+var errRemoteRun = errors.New("a Makefile read over HTTP is only for inspection; give its local checkout with -remote-dir to run targets")
+
+// isRemoteMakefile reports whether path is an http or https URL rather
+// than a file.
+func isRemoteMakefile(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// fetchMakefile downloads the Makefile at url, failing on anything but a
+// 200 response.
+func fetchMakefile(url string) ([]byte, error) {
+	client := &http.Client{Timeout: remoteTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, fmt.Errorf("fetching %s: no response within %s", url, remoteTimeout)
+		}
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	if len(body) > maxRemoteSize {
+		return nil, fmt.Errorf("fetching %s: larger than %d MB", url, maxRemoteSize>>20)
+	}
+	return body, nil
+}
+
+// parseRemoteMakefile fetches and parses the Makefile at url with opts.
+// Its includes are not followed.
+func parseRemoteMakefile(url string, opts makefile.Options) ([]MakeOption, error) {
+	body, err := fetchMakefile(url)
+	if err != nil {
+		return nil, err
+	}
+	diag.infof("fetched %s (%d bytes)", url, len(body))
+	targets, err := opts.ParseReader(url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return targetOptions(targets, url), nil
+}
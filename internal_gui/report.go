@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultReportFile is offered by the TUI when -report is not given.
+const defaultReportFile = "coolbox-report.html"
+
+// maxReportOutput caps the output kept per run for the report, and
+// maxReportRuns the runs kept, dropping the oldest.
+const (
+	maxReportOutput = 1 << 20
+	maxReportRuns   = 100
+)
+
+// RunRecord is one run of the session history.
+type RunRecord struct {
+	Target   string
+	Command  []string
+	Start    time.Time
+	Duration time.Duration
+	ExitCode int
+	// Output holds what the run printed, stdout and stderr interleaved. It
+	// is nil for runs attached to the terminal, whose output is not
+	// captured.
+	Output    []byte
+	Truncated bool // Output was cut at maxReportOutput
+}
+
+// History records the runs of a session for the HTML report. It is safe
+// for concurrent use.
+type History struct {
+	mu   sync.Mutex
+	runs []RunRecord
+}
+
+func (h *History) add(rec RunRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.runs = append(h.runs, rec)
+	if len(h.runs) > maxReportRuns {
+		h.runs = h.runs[len(h.runs)-maxReportRuns:]
+	}
+}
+
+// Runs returns the recorded runs, oldest first.
+func (h *History) Runs() []RunRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]RunRecord(nil), h.runs...)
+}
+
+// captureWriter keeps up to maxReportOutput bytes of what a run writes to
+// its stdout and stderr, which exec may copy from separate goroutines.
+type captureWriter struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (c *captureWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if room := maxReportOutput - c.buf.Len(); len(p) > room {
+		c.buf.Write(p[:room])
+		c.truncated = true
+	} else {
+		c.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// reportTemplate lays out the report; the styles are inline so the file
+// can be shared on its own.
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>CoolBox run report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+section { border-left: 6px solid #2a2; margin: 1.5em 0; padding: 0 1em; }
+section.failed { border-color: #c22; }
+dl { display: grid; grid-template-columns: max-content auto; gap: .2em 1em; }
+dt { font-weight: bold; }
+dd { margin: 0; font-family: monospace; }
+pre { background: #111; color: #ddd; padding: 1em; overflow-x: auto; }
+.b { font-weight: bold; } .d { opacity: .6; }
+.c30, .c90 { color: #888; } .c31, .c91 { color: #f66; } .c32, .c92 { color: #6d6; } .c33, .c93 { color: #ec4; }
+.c34, .c94 { color: #69f; } .c35, .c95 { color: #d7d; } .c36, .c96 { color: #5dd; } .c37, .c97 { color: #fff; }
+</style>
+</head>
+<body>
+<h1>CoolBox run report</h1>
+<p>{{.Dir}}, written {{.Written.Format "2006-01-02 15:04:05"}}</p>
+{{range .Runs}}<section{{if .ExitCode}} class="failed"{{end}}>
+<h2>{{.Target}}</h2>
+<dl>
+<dt>Command</dt><dd>{{.CommandLine}}</dd>
+<dt>Started</dt><dd>{{.Start.Format "2006-01-02 15:04:05"}}</dd>
+<dt>Duration</dt><dd>{{.Duration}}</dd>
+<dt>Exit code</dt><dd>{{.ExitCode}}</dd>
+</dl>
+{{if .Captured}}<pre>{{.Output}}</pre>{{if .Truncated}}<p>Output truncated.</p>{{end}}{{else}}<p>Ran on the terminal; output not captured.</p>{{end}}
+</section>
+{{else}}<p>No runs this session.</p>
+{{end}}</body>
+</html>
+`))
+
+// writeReport writes runs as a self-contained HTML page, with the ANSI
+// colors of their output kept.
+func writeReport(w io.Writer, dir string, runs []RunRecord) error {
+	type reportRun struct {
+		RunRecord
+		CommandLine string
+		Captured    bool
+		Output      template.HTML
+	}
+	data := struct {
+		Dir     string
+		Written time.Time
+		Runs    []reportRun
+	}{Dir: dir, Written: time.Now()}
+	for _, run := range runs {
+		args := make([]string, len(run.Command))
+		for i, arg := range run.Command {
+			args[i] = shellQuote(arg)
+		}
+		data.Runs = append(data.Runs, reportRun{
+			RunRecord:   run,
+			CommandLine: strings.Join(args, " "),
+			Captured:    run.Output != nil,
+			Output:      template.HTML(ansiHTML(run.Output)),
+		})
+	}
+	return reportTemplate.Execute(w, data)
+}
+
+// writeReportFile writes the report of history to path.
+func writeReportFile(path, dir string, history *History) error {
+	var buf bytes.Buffer
+	if err := writeReport(&buf, dir, history.Runs()); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// ansiHTML converts terminal output to escaped HTML, turning the bold, dim
+// and foreground color codes of SGR sequences into spans styled by the
+// report's classes. Other escape sequences are dropped, and a carriage
+// return keeps only what was written after it, as a terminal would show
+// progress output.
+func ansiHTML(out []byte) string {
+	lines := strings.Split(string(out), "\n")
+	for i, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if j := strings.LastIndexByte(line, '\r'); j >= 0 {
+			line = line[j+1:]
+		}
+		lines[i] = line
+	}
+	text := strings.Join(lines, "\n")
+
+	var b strings.Builder
+	var bold, dim bool
+	color := 0
+	open := false
+	for len(text) > 0 {
+		i := strings.IndexByte(text, '\x1b')
+		if i < 0 {
+			b.WriteString(html.EscapeString(text))
+			break
+		}
+		b.WriteString(html.EscapeString(text[:i]))
+		text = text[i+1:]
+		if !strings.HasPrefix(text, "[") {
+			continue
+		}
+		// A CSI sequence ends with a byte in @ to ~.
+		end := strings.IndexFunc(text[1:], func(r rune) bool { return r >= '@' && r <= '~' })
+		if end < 0 {
+			break
+		}
+		params, final := text[1:end+1], text[end+1]
+		text = text[end+2:]
+		if final != 'm' {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			n, _ := strconv.Atoi(param) // "" means 0, a reset
+			if n == 38 || n == 48 {
+				// 256-color and RGB colors; their arguments are not codes.
+				break
+			}
+			switch {
+			case n == 0:
+				bold, dim, color = false, false, 0
+			case n == 1:
+				bold = true
+			case n == 2:
+				dim = true
+			case n == 22:
+				bold, dim = false, false
+			case n >= 30 && n <= 37, n >= 90 && n <= 97:
+				color = n
+			case n == 39:
+				color = 0
+			}
+		}
+		if open {
+			b.WriteString("</span>")
+			open = false
+		}
+		var classes []string
+		if bold {
+			classes = append(classes, "b")
+		}
+		if dim {
+			classes = append(classes, "d")
+		}
+		if color != 0 {
+			classes = append(classes, "c"+strconv.Itoa(color))
+		}
+		if len(classes) > 0 {
+			b.WriteString(`<span class="` + strings.Join(classes, " ") + `">`)
+			open = true
+		}
+	}
+	if open {
+		b.WriteString("</span>")
+	}
+	return b.String()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// requiredVars maps the targets in tabs to the variables their "@requires"
+// comments name, for Runner.SetRequires.
+func requiredVars(tabs []Tab) map[string][]string {
+	requires := make(map[string][]string)
+	for _, t := range tabs {
+		for _, opt := range t.Options {
+			if len(opt.Requires) > 0 {
+				requires[opt.Target] = opt.Requires
+			}
+		}
+	}
+	return requires
+}
+
+// SetRequires replaces the variables each target needs set, which run
+// checks with MissingVars before starting it. The tabs reloading while a
+// run goes on is why it is guarded.
+func (r *Runner) SetRequires(requires map[string][]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requires = requires
+}
+
+// MissingVars returns the variables target requires that are unset, in
+// the order its "@requires" comment names them. A variable is set by a
+// "NAME=value" argument, a non-empty value in the environment, or a
+// non-empty assignment in the Makefile or a file it includes, which -env
+// and included env files provide.
+func (r *Runner) MissingVars(target string, args []string) []string {
+	r.mu.Lock()
+	names := r.requires[r.Resolve(target)]
+	r.mu.Unlock()
+	if len(names) == 0 {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, arg := range args {
+		if name, value, ok := strings.Cut(arg, "="); ok && value != "" {
+			// Make also takes "NAME:=value" and the like on the command line.
+			set[strings.TrimRight(name, ":?+!")] = true
+		}
+	}
+	if !isRemoteMakefile(r.Makefile) {
+		vars, _ := r.ParseOptions.Variables(r.Makefile)
+		for _, v := range vars {
+			if v.Value != "" {
+				set[v.Name] = true
+			}
+		}
+	}
+	var missing []string
+	for _, name := range names {
+		if !set[name] && os.Getenv(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// requiredEnv returns "NAME=value" for each variable target requires that
+// CoolBox's environment sets, for a spawned run that does not inherit it.
+func (r *Runner) requiredEnv(target string) []string {
+	r.mu.Lock()
+	names := r.requires[r.Resolve(target)]
+	r.mu.Unlock()
+	var env []string
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// withVars appends a "NAME=" argument for each of names to args, quoted
+// for shellWords, for the prompt that asks for them.
+func withVars(args, names []string) string {
+	var fields []string
+	for _, arg := range args {
+		fields = append(fields, shellQuote(arg))
+	}
+	for _, name := range names {
+		fields = append(fields, name+"=")
+	}
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,742 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"internal_gui/makefile"
+)
+
+// Runner executes Makefile targets on behalf of the front-ends so the TUI
+// and GUI behave the same way.
+type Runner struct {
+	// Makefile is the path of the parsed build file. Targets run in its
+	// directory.
+	Makefile string
+
+	// LocalDir is the checkout targets run in when Makefile is a URL, see
+	// isRemoteMakefile. Without one such a Makefile can only be inspected.
+	LocalDir string
+
+	// Command is the program and leading arguments that run a target, see
+	// BuildTool. Nil means make, as found by makeProgram.
+	Command []string
+
+	// Aliases maps alias names to the targets they stand for.
+	Aliases map[string]string
+
+	// Launch maps the labels of an explicit launcher config to the entries
+	// run for them instead of a build tool.
+	Launch map[string]LauncherEntry
+
+	// Targets holds the per-target settings of the config.
+	Targets map[string]TargetConfig
+
+	// Workflows maps the names of the config's workflows to them; Run
+	// runs a workflow's steps, see RunWorkflow.
+	Workflows map[string]Workflow
+
+	// Flags are passed to the command ahead of the target on every run.
+	Flags []string
+
+	// Jobs, when positive, is how many jobs the build tool runs at once,
+	// see jobFlags.
+	Jobs int
+
+	// Container, when set, is the image targets run in with docker, see
+	// inContainer. A target's own container setting overrides it.
+	// ContainerEnv names the variables passed through to the container.
+	Container    string
+	ContainerEnv []string
+
+	// Echo writes the command line ahead of a run's output when that output
+	// is captured, as in the TUI's output pane, rather than shown on the
+	// terminal.
+	Echo bool
+
+	// PTY runs captured commands on a pseudo-terminal so they keep their
+	// colors and progress output. Where ptys are unavailable they fall
+	// back to pipes. TermSize, when set, reports the terminal size to use.
+	PTY      bool
+	TermSize func() (cols, rows int)
+
+	// KeepGoing continues a sequence after a failed step.
+	KeepGoing bool
+
+	// Parallel runs the steps of a sequence at the same time.
+	Parallel bool
+
+	// TagOutput controls prefixing output lines with the target's name:
+	// "always", "never", or by default only when a sequence runs several
+	// targets and its output is not the terminal, or runs them in parallel.
+	TagOutput string
+
+	// Outcomes, when set, records the result of every run.
+	Outcomes *Outcomes
+
+	// History, when set, records every run and its output for the HTML
+	// report.
+	History *History
+
+	// AlwaysMake passes make -B, --always-make, so targets are rebuilt
+	// whatever their timestamps. Other build tools ignore it.
+	AlwaysMake bool
+
+	// Preflight has make read the Makefile before each run and refuses to
+	// run when it reports errors in it, see preflight.
+	Preflight bool
+
+	// ParseOptions tune how the Makefile is read whenever the runner reads
+	// it, as for its variables.
+	ParseOptions makefile.Options
+
+	// Spawn launches the targets run with captured output detached, in a
+	// tmux window or terminal of their own, see spawn. A target's own spawn
+	// setting does the same for it.
+	Spawn bool
+
+	// AfterRun, when set, is called with the resolved target once each run
+	// finishes, on the goroutine that ran it.
+	AfterRun func(target string, err error)
+
+	preflightMu     sync.Mutex
+	preflightStamps map[string]sourceStamp // the files the last preflight read
+	preflightErr    error                  // and what it found
+
+	mu       sync.Mutex
+	active   map[*exec.Cmd]bool     // commands running in their own process group
+	ptys     map[*os.File]bool      // the ptys of the active commands, for Resize
+	tails    map[chan struct{}]bool // closed by Cancel to stop tailing log files
+	requires map[string][]string    // see SetRequires
+	started  int                    // see Started
+
+	// NotifyAfter is the run time at or above which a desktop notification
+	// is sent when the target finishes. Zero disables notifications.
+	NotifyAfter time.Duration
+}
+
+// Stdio connects a run to its input and output. A nil Stdin reads from the
+// null device.
+type Stdio struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// terminalStdio attaches a run directly to CoolBox's own terminal.
+var terminalStdio = Stdio{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+
+// Dir returns the absolute directory containing the Makefile, or LocalDir
+// for one read over HTTP.
+func (r *Runner) Dir() string {
+	if isRemoteMakefile(r.Makefile) {
+		if dir, err := filepath.Abs(r.LocalDir); err == nil && r.LocalDir != "" {
+			return dir
+		}
+		return r.LocalDir
+	}
+	dir, err := filepath.Abs(filepath.Dir(r.Makefile))
+	if err != nil {
+		return filepath.Dir(r.Makefile)
+	}
+	return dir
+}
+
+// Resolve returns the real target for name, following aliases.
+func (r *Runner) Resolve(name string) string {
+	if target, ok := r.Aliases[name]; ok {
+		return target
+	}
+	return name
+}
+
+// CommandLine returns the full command that builds target: the program,
+// the default flags and the resolved target, or the shell running a
+// launcher entry, wrapped to run in a container when one is set.
+func (r *Runner) CommandLine(target string) []string {
+	image := r.containerImage(target)
+	args := r.toolCommand(target, image != "")
+	if image == "" {
+		return args
+	}
+	return r.inContainer(image, r.targetDir(target), args)
+}
+
+// targetDir returns the directory target runs in: the cwd of its launcher
+// entry or target config, relative to the Makefile's directory unless
+// absolute, or else the Makefile's directory. A target config's cwd only
+// applies to make, which -f points back at the Makefile; the other build
+// tools find their file from where they start.
+func (r *Runner) targetDir(target string) string {
+	var cwd string
+	if len(r.Command) == 0 {
+		cwd = r.Targets[r.Resolve(target)].Cwd
+	}
+	if entry, ok := r.Launch[r.Resolve(target)]; ok {
+		cwd = entry.Cwd
+	}
+	switch {
+	case cwd == "":
+		return r.Dir()
+	case filepath.IsAbs(cwd):
+		return cwd
+	}
+	return filepath.Join(r.Dir(), cwd)
+}
+
+// toolCommand is CommandLine without the container. Inside a container
+// the program is the Linux one whatever the host.
+func (r *Runner) toolCommand(target string, container bool) []string {
+	if entry, ok := r.Launch[r.Resolve(target)]; ok {
+		if container {
+			return []string{"sh", "-c", entry.Command}
+		}
+		return shellCommand(entry.Command).Args
+	}
+	args := append([]string(nil), r.Command...)
+	if len(args) == 0 {
+		args = r.makeCommandIn(r.targetDir(target))
+		if container {
+			args[0] = "make"
+		}
+	}
+	args = append(args, r.Flags...)
+	args = append(args, r.jobArgs(args[0])...)
+	args = append(args, r.alwaysMakeArgs(args[0])...)
+	return append(args, r.Resolve(target))
+}
+
+// jobFlags returns the flags passing Jobs to the build tool: -jN for make
+// and --concurrency N for task. Other tools, and nmake, have no such
+// option, and a launcher config takes none, so there are none.
+func (r *Runner) jobFlags() []string {
+	return r.jobArgs(r.Program())
+}
+
+// jobArgs is jobFlags for program, which toolCommand also adds.
+func (r *Runner) jobArgs(program string) []string {
+	if r.Jobs <= 0 || len(r.Launch) > 0 {
+		return nil
+	}
+	switch {
+	case isGNUMake(program):
+		return []string{"-j" + strconv.Itoa(r.Jobs)}
+	case strings.TrimSuffix(filepath.Base(program), ".exe") == "task":
+		return []string{"--concurrency", strconv.Itoa(r.Jobs)}
+	}
+	return nil
+}
+
+// isGNUMake reports whether program is make under one of the names GNU
+// make goes by, rather than nmake or another build tool.
+func isGNUMake(program string) bool {
+	switch strings.TrimSuffix(filepath.Base(program), ".exe") {
+	case "make", "gmake", "mingw32-make":
+		return true
+	}
+	return false
+}
+
+// alwaysMakeFlags returns the -B that AlwaysMake adds to make's command
+// line, or nothing when it is off, the build tool is not make or a
+// launcher config is in use.
+func (r *Runner) alwaysMakeFlags() []string {
+	return r.alwaysMakeArgs(r.Program())
+}
+
+// alwaysMakeArgs is alwaysMakeFlags for program, which toolCommand also
+// adds.
+func (r *Runner) alwaysMakeArgs(program string) []string {
+	if !r.AlwaysMake || len(r.Launch) > 0 || !isGNUMake(program) {
+		return nil
+	}
+	return []string{"-B"}
+}
+
+// Program returns the name of the build tool that runs targets, also when
+// they run in a container.
+func (r *Runner) Program() string { return r.toolCommand("", false)[0] }
+
+// containerWorkdir is where the Makefile's directory is mounted inside a
+// container.
+const containerWorkdir = "/src"
+
+// containerImage returns the image target runs in: its own from the
+// config, else Container, or "" to run it directly.
+func (r *Runner) containerImage(target string) string {
+	if image := r.Targets[r.Resolve(target)].Container; image != "" {
+		return image
+	}
+	return r.Container
+}
+
+// inContainer wraps args to run them in image with docker: the Makefile's
+// directory is mounted at containerWorkdir and dir, which is in or below
+// it, is the working directory. Files outside the Makefile's directory,
+// such as includes from a parent, are not visible. The container is
+// removed afterwards and stopped along with make, since docker forwards
+// signals and --init passes them on.
+func (r *Runner) inContainer(image, dir string, args []string) []string {
+	workdir := containerWorkdir
+	if rel, err := filepath.Rel(r.Dir(), dir); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		workdir = path.Join(containerWorkdir, filepath.ToSlash(rel))
+	}
+	wrapped := []string{"docker", "run", "--rm", "-i", "--init", "-v", r.Dir() + ":" + containerWorkdir, "-w", workdir}
+	for _, name := range r.ContainerEnv {
+		wrapped = append(wrapped, "-e", name)
+	}
+	wrapped = append(wrapped, image)
+	return append(wrapped, args...)
+}
+
+// Run invokes the build tool for target, which may be an alias, connected
+// to stdio. args follow the target on the command line, as the variable
+// overrides given at the TUI's args prompt do; workflows take none.
+func (r *Runner) Run(target string, stdio Stdio, args ...string) error {
+	if _, ok := r.Workflows[target]; ok {
+		_, err := r.RunWorkflow(target, stdio)
+		return err
+	}
+	if r.TagOutput == "always" && stdio.Stdout != nil {
+		tagged, flush := tagStdio(stdio, target, &sync.Mutex{})
+		defer flush()
+		stdio = tagged
+	}
+	return r.run(target, stdio, args...)
+}
+
+// command returns the unstarted command that runs target, which may be an
+// alias, with extra arguments after it, in the directory it runs in.
+func (r *Runner) command(target string, extra ...string) *exec.Cmd {
+	args := append(r.CommandLine(target), extra...)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = r.targetDir(target)
+	return cmd
+}
+
+// Preview describes what running target would execute, one "Label: value"
+// line each for the executable, its arguments, the directory and the
+// environment, for the confirmation shown before a run. Make variable
+// assignments among the arguments, such as those from makeFlags, are
+// listed as the overrides they are. args are the extra arguments the run
+// would be given, see Run.
+func (r *Runner) Preview(target string, args ...string) string {
+	if w, ok := r.Workflows[target]; ok {
+		mode := "one after another, stopping at the first failure"
+		switch {
+		case w.Parallel:
+			mode = "all at once"
+		case w.keepGoing():
+			mode = "one after another, going on past failures"
+		}
+		return "Workflow: " + strings.Join(w.Steps, " → ") + "\nSteps run " + mode
+	}
+	cmd := r.command(target, args...)
+	var quoted, vars []string
+	for _, arg := range cmd.Args[1:] {
+		if _, ok := r.Launch[r.Resolve(target)]; !ok && !strings.HasPrefix(arg, "-") && strings.Contains(arg, "=") {
+			vars = append(vars, arg)
+		}
+		quoted = append(quoted, shellQuote(arg))
+	}
+	lines := []string{
+		"Executable: " + cmd.Path,
+		"Arguments: " + strings.Join(quoted, " "),
+		"Directory: " + cmd.Dir,
+	}
+	if len(vars) > 0 {
+		lines = append(lines, "Overrides: "+strings.Join(vars, " "))
+	}
+	return strings.Join(append(lines, "Environment: inherited from CoolBox"), "\n")
+}
+
+// run is Run without tagging.
+func (r *Runner) run(target string, stdio Stdio, args ...string) error {
+	if isRemoteMakefile(r.Makefile) && r.LocalDir == "" {
+		return errRemoteRun
+	}
+	if missing := r.MissingVars(target, args); len(missing) > 0 {
+		err := fmt.Errorf("%s requires %s to be set, in the environment or as arguments such as %s=value; nothing was run", r.Resolve(target), strings.Join(missing, ", "), missing[0])
+		if stdio.Stderr != nil {
+			fmt.Fprintln(stdio.Stderr, err)
+		}
+		return err
+	}
+	if err := r.preflight(target); err != nil {
+		if stdio.Stderr != nil {
+			fmt.Fprintln(stdio.Stderr, err)
+		}
+		return err
+	}
+	start := time.Now()
+	cmd := r.command(target, args...)
+	target = r.Resolve(target)
+	if r.Echo && stdio.Stdout != nil && stdio.Stdout != os.Stdout {
+		fmt.Fprintln(stdio.Stdout, echoLine(cmd))
+	}
+	if r.spawns(target) && stdio.Stdout != nil && stdio.Stdout != os.Stdout {
+		where, err := spawn(cmd, target, r.requiredEnv(target))
+		if err == nil {
+			fmt.Fprintf(stdio.Stdout, "Spawned %s %s\n", target, where)
+		}
+		return err
+	}
+	// Only what the target's daemon logs from now on is of interest.
+	tail := r.Targets[target].Tail
+	if tail != "" && !filepath.IsAbs(tail) {
+		tail = filepath.Join(r.Dir(), tail)
+	}
+	offset := fileSize(tail)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdio.Stdin, stdio.Stdout, stdio.Stderr
+	// Output on the terminal is left alone so commands still see a tty.
+	var capture *captureWriter
+	if r.History != nil && stdio.Stdout != nil && stdio.Stdout != os.Stdout {
+		capture = &captureWriter{}
+		cmd.Stdout = io.MultiWriter(stdio.Stdout, capture)
+		if stdio.Stderr != nil {
+			cmd.Stderr = io.MultiWriter(stdio.Stderr, capture)
+		}
+	}
+	diag.infof("starting %s: %s in %s", target, strings.Join(cmd.Args, " "), cmd.Dir)
+	r.mu.Lock()
+	r.started++
+	r.mu.Unlock()
+	err := r.exec(cmd)
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		diag.warnf("%s did not run: %v", target, err)
+	} else {
+		diag.infof("%s exited %d after %s", target, exitCode(err), time.Since(start).Round(time.Millisecond))
+	}
+	if r.History != nil {
+		rec := RunRecord{Target: target, Command: cmd.Args, Start: start, Duration: time.Since(start).Round(time.Millisecond), ExitCode: exitCode(err)}
+		if capture != nil {
+			rec.Output, rec.Truncated = append([]byte{}, capture.buf.Bytes()...), capture.truncated
+		}
+		r.History.add(rec)
+	}
+	if err == nil && tail != "" && stdio.Stdout != nil && stdio.Stdout != os.Stdout {
+		r.tail(tail, offset, stdio.Stdout)
+	}
+	if r.Outcomes != nil {
+		r.Outcomes.Record(target, err)
+	}
+	if r.AfterRun != nil {
+		r.AfterRun(target, err)
+	}
+
+	if elapsed := time.Since(start); r.NotifyAfter > 0 && elapsed >= r.NotifyAfter {
+		status := "succeeded"
+		if err != nil {
+			status = "failed"
+		}
+		// Notifications are best-effort; a missing notifier must not
+		// affect the run.
+		notify("CoolBox", fmt.Sprintf("%s %s %s after %s", r.Program(), target, status, elapsed.Round(time.Second)))
+	}
+	return err
+}
+
+// Started returns how many runs have started the build tool in place, as
+// opposed to being refused or spawned, so the TUI can tell whether a run
+// used its one-shot -B.
+func (r *Runner) Started() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.started
+}
+
+// echoLine renders cmd as a shell prompt line, "$ make build", prefixed
+// with a cd when it runs outside the directory CoolBox was started in.
+func echoLine(cmd *exec.Cmd) string {
+	line := "$ " + commandLine(cmd)
+	if wd, err := os.Getwd(); err == nil && cmd.Dir != "" && filepath.Clean(cmd.Dir) != wd {
+		dir := cmd.Dir
+		if rel, err := filepath.Rel(wd, dir); err == nil {
+			dir = rel
+		}
+		line = "$ cd " + shellQuote(dir) + " && " + line[2:]
+	}
+	return line
+}
+
+// shellQuote single-quotes s when a POSIX shell would not take it as one
+// word.
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"$`\\|&;<>()*?[]#~{}") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellWords splits s into words the way a POSIX shell would, without
+// expanding anything, so that arguments shellQuote wrote come back whole:
+// single quotes keep everything, double quotes keep all but a backslash
+// before one of $`"\, and a backslash outside quotes keeps the next
+// character, or itself at the end.
+func shellWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated ' quote")
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\", s[i+1]) >= 0 {
+					i++
+				}
+				word.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, errors.New(`unterminated " quote`)
+			}
+			inWord = true
+		case c == '\\':
+			if i+1 < len(s) {
+				i++
+			}
+			word.WriteByte(s[i])
+			inWord = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// windowsMakes are the make programs tried on Windows, where GNU make is
+// often installed as mingw32-make and Visual Studio ships nmake.
+var windowsMakes = []string{"make", "mingw32-make", "nmake"}
+
+// makeProgram returns the make to run. Other platforms always use make.
+func makeProgram() string {
+	if runtime.GOOS == "windows" {
+		return firstOnPath(windowsMakes)
+	}
+	return "make"
+}
+
+// firstOnPath returns the first of names found on PATH, or make when
+// there is none, so the failure to start it names the usual program.
+func firstOnPath(names []string) string {
+	for _, name := range names {
+		if _, err := exec.LookPath(name); err == nil {
+			return name
+		}
+	}
+	return "make"
+}
+
+// defaultMakefiles are the names make finds without -f.
+var defaultMakefiles = map[string]bool{"GNUmakefile": true, "makefile": true, "Makefile": true}
+
+// makefileNames are the names make looks for, in its order.
+var makefileNames = []string{"GNUmakefile", "makefile", "Makefile"}
+
+// envMakefile returns the Makefile for environment env that stands in for
+// base: base.env, such as Makefile.prod, or when base has one of make's
+// default names, the first of GNUmakefile.env, makefile.env and
+// Makefile.env that exists. Without one it returns base and false.
+func envMakefile(base, env string) (string, bool) {
+	candidates := []string{base + "." + env}
+	if defaultMakefiles[filepath.Base(base)] {
+		for _, name := range makefileNames {
+			candidates = append(candidates, filepath.Join(filepath.Dir(base), name+"."+env))
+		}
+	}
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return base, false
+}
+
+// makeCommand returns make with the -f options needed to read r.Makefile
+// from r.Dir(): one per fragment for a fragment directory, or the file's
+// name when make would not pick it up by default.
+func (r *Runner) makeCommand() []string {
+	return r.makeCommandIn(r.Dir())
+}
+
+// makeCommandIn is makeCommand for make started in dir rather than the
+// Makefile's directory, which then always needs -f to find it.
+func (r *Runner) makeCommandIn(dir string) []string {
+	args := []string{makeProgram()}
+	file := r.Makefile
+	if isRemoteMakefile(file) {
+		// The local checkout's copy is what runs.
+		file = filepath.Join(r.Dir(), filepath.Base(file))
+	}
+	if fragments, _ := makefile.Fragments(file); fragments != nil {
+		for _, fragment := range fragments {
+			if rel, err := filepath.Rel(dir, fragment); err == nil {
+				fragment = rel
+			}
+			args = append(args, "-f", fragment)
+		}
+	} else if base := filepath.Base(file); file != "" && (dir != r.Dir() || !defaultMakefiles[base]) {
+		path := base
+		if dir != r.Dir() {
+			path, _ = filepath.Abs(file)
+			if rel, err := filepath.Rel(dir, path); err == nil {
+				path = rel
+			}
+		}
+		args = append(args, "-f", path)
+	}
+	return args
+}
+
+// openStdinFile opens path, relative to dir unless absolute, to be a run's
+// standard input.
+func openStdinFile(path, dir string) (*os.File, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := file.Stat(); err == nil && info.IsDir() {
+		file.Close()
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	return file, nil
+}
+
+// shellCommand returns command run by sh -c, or cmd /C on Windows.
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// RunShell runs an arbitrary command line in the Makefile's directory,
+// connected to stdio.
+func (r *Runner) RunShell(command string, stdio Stdio) error {
+	if isRemoteMakefile(r.Makefile) && r.LocalDir == "" {
+		return errRemoteRun
+	}
+	cmd := shellCommand(command)
+	cmd.Dir = r.Dir()
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdio.Stdin, stdio.Stdout, stdio.Stderr
+	return r.exec(cmd)
+}
+
+// exec runs cmd so that Cancel can stop it. Commands attached to the
+// terminal stay in CoolBox's process group: the terminal delivers Ctrl+C
+// to them directly, and moving them out of it would stop them the moment
+// they read from the terminal.
+func (r *Runner) exec(cmd *exec.Cmd) error {
+	if cmd.Stdin == os.Stdin {
+		return cmd.Run()
+	}
+	wait := cmd.Wait
+	var ptyFile *os.File
+	if tty, ok := r.openPTY(); ok {
+		w, err := startOnPTY(cmd, tty)
+		if err != nil {
+			return err
+		}
+		wait, ptyFile = w, tty.pty
+	} else {
+		setProcessGroup(cmd)
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	if r.active == nil {
+		r.active = make(map[*exec.Cmd]bool)
+	}
+	r.active[cmd] = true
+	if ptyFile != nil {
+		if r.ptys == nil {
+			r.ptys = make(map[*os.File]bool)
+		}
+		r.ptys[ptyFile] = true
+	}
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.active, cmd)
+		delete(r.ptys, ptyFile)
+		r.mu.Unlock()
+	}()
+	return wait()
+}
+
+// tail streams the log file at path into w, see tailFile, until Cancel.
+func (r *Runner) tail(path string, offset int64, w io.Writer) {
+	stop := make(chan struct{})
+	r.mu.Lock()
+	if r.tails == nil {
+		r.tails = make(map[chan struct{}]bool)
+	}
+	r.tails[stop] = true
+	r.mu.Unlock()
+	fmt.Fprintf(w, "Tailing %s until cancelled\n", path)
+	tailFile(path, offset, w, stop)
+}
+
+// Cancel stops every running command along with the processes it started,
+// and any log file being tailed.
+func (r *Runner) Cancel() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for cmd := range r.active {
+		killProcessGroup(cmd)
+	}
+	for stop := range r.tails {
+		close(stop)
+		delete(r.tails, stop)
+	}
+}
+
+// runSummary is the one-line report -summary prints after a -run.
+func runSummary(name string, err error, elapsed time.Duration) string {
+	return fmt.Sprintf("coolbox: %s exited %d in %s", name, exitCode(err), elapsed.Round(100*time.Millisecond))
+}
+
+// exitCode maps the error returned by Run to a process exit status.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
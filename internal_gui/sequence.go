@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// StepResult is the outcome of one target in a sequence.
+type StepResult struct {
+	Target  string
+	Err     error
+	Skipped bool // not run because an earlier step failed
+}
+
+// RunSequence runs targets one after another like "make a && make b",
+// stopping at the first failure unless r.KeepGoing is set, or all at once
+// when r.Parallel is set. Progress and a final summary are written to
+// stdio.Stdout. The returned error is the first failure, if any.
+func (r *Runner) RunSequence(targets []string, stdio Stdio) ([]StepResult, error) {
+	if r.Parallel {
+		return r.runParallel(targets, stdio)
+	}
+	return r.runSequential(targets, stdio, r.KeepGoing)
+}
+
+// Replay re-runs targets, the last ones run from the Makefile, one after
+// another in the order they ran, and stops at the first failure
+// whatever r.Parallel and r.KeepGoing say.
+func (r *Runner) Replay(targets []string, stdio Stdio) ([]StepResult, error) {
+	return r.runSequential(targets, stdio, false)
+}
+
+// runSequential runs targets in order, going on past failures when
+// keepGoing is set.
+func (r *Runner) runSequential(targets []string, stdio Stdio, keepGoing bool) ([]StepResult, error) {
+	results := make([]StepResult, len(targets))
+	var mu sync.Mutex
+	var firstErr error
+	for i, target := range targets {
+		results[i].Target = target
+		if firstErr != nil && !keepGoing {
+			results[i].Skipped = true
+			continue
+		}
+		fmt.Fprintf(stdio.Stdout, "==> [%d/%d] %s\n", i+1, len(targets), target)
+		step, flush := stdio, func() {}
+		if r.tagSteps(len(targets), stdio.Stdout != os.Stdout) {
+			step, flush = tagStdio(stdio, target, &mu)
+		}
+		err := r.run(target, step)
+		flush()
+		results[i].Err = err
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	fmt.Fprintln(stdio.Stdout, summarizeSequence(results))
+	return results, firstErr
+}
+
+// runParallel runs every target at the same time. They cannot share
+// stdin, so each reads from the null device. Output that is not the
+// terminal is written under a lock, tagged or not.
+func (r *Runner) runParallel(targets []string, stdio Stdio) ([]StepResult, error) {
+	results := make([]StepResult, len(targets))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	fmt.Fprintf(stdio.Stdout, "==> running %s in parallel\n", strings.Join(targets, ", "))
+	for i, target := range targets {
+		results[i].Target = target
+		step, flush := Stdio{Stdout: stdio.Stdout, Stderr: stdio.Stderr}, func() {}
+		if r.tagSteps(len(targets), true) {
+			step, flush = tagStdio(step, target, &mu)
+		} else if stdio.Stdout != os.Stdout {
+			step = lockStdio(step, &mu)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i].Err = r.run(results[i].Target, step)
+			flush()
+		}(i)
+	}
+	wg.Wait()
+	var firstErr error
+	for _, res := range results {
+		if res.Err != nil && firstErr == nil {
+			firstErr = res.Err
+		}
+	}
+	fmt.Fprintln(stdio.Stdout, summarizeSequence(results))
+	return results, firstErr
+}
+
+// tagSteps reports whether the steps of a sequence of n targets get
+// tagged output, see Runner.TagOutput. captured tells whether the output
+// goes somewhere other than the terminal, which make would lose if its
+// output were piped through a tagger.
+func (r *Runner) tagSteps(n int, captured bool) bool {
+	switch r.TagOutput {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	return n > 1 && captured
+}
+
+// summarizeSequence renders results as a single line such as
+// "sequence: clean ok, build failed (exit 2), test skipped".
+func summarizeSequence(results []StepResult) string {
+	parts := make([]string, len(results))
+	for i, res := range results {
+		switch {
+		case res.Skipped:
+			parts[i] = res.Target + " skipped"
+		case res.Err != nil:
+			parts[i] = fmt.Sprintf("%s failed (exit %d)", res.Target, exitCode(res.Err))
+		default:
+			parts[i] = res.Target + " ok"
+		}
+	}
+	return "sequence: " + strings.Join(parts, ", ")
+}
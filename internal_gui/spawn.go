@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// terminalCommands lists the terminal emulators tried for spawned targets
+// on Unix desktops, in order of preference, each with the arguments after
+// which it takes the command to run.
+var terminalCommands = [][]string{
+	{"x-terminal-emulator", "-e"},
+	{"gnome-terminal", "--"},
+	{"konsole", "-e"},
+	{"xfce4-terminal", "-x"},
+	{"alacritty", "-e"},
+	{"kitty"},
+	{"foot"},
+	{"xterm", "-e"},
+}
+
+// spawns reports whether target is launched detached with spawn rather
+// than run in place, by -spawn or its own spawn setting.
+func (r *Runner) spawns(target string) bool {
+	return r.Spawn || r.Targets[r.Resolve(target)].Spawn
+}
+
+// spawn starts cmd, the run of target, detached from CoolBox so that a
+// long-running target such as a server leaves the UI free: in a new tmux
+// window when CoolBox runs inside tmux, in a new terminal window on a
+// desktop, or else in the background with its output appended to a log
+// file. It returns where the target went. env, "NAME=value" pairs, is set
+// in a tmux or Terminal window, which start from the tmux server's or the
+// login environment rather than CoolBox's.
+func spawn(cmd *exec.Cmd, target string, env []string) (string, error) {
+	line := commandLine(cmd)
+	// The window stays open once the target exits, so its last words can
+	// still be read.
+	held := line + "; status=$?; printf '\\n%s exited %d. Press Enter to close.' " + shellQuote(target) + " $status; read _"
+	if os.Getenv("TMUX") != "" {
+		if _, err := exec.LookPath("tmux"); err == nil {
+			args := []string{"new-window", "-n", target, "-c", cmd.Dir}
+			for _, v := range env {
+				args = append(args, "-e", v)
+			}
+			window := exec.Command("tmux", append(args, "sh", "-c", held)...)
+			if out, err := window.CombinedOutput(); err != nil {
+				return "", fmt.Errorf("tmux new-window: %v: %s", err, strings.TrimSpace(string(out)))
+			}
+			return "in tmux window " + target, nil
+		}
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		// Terminal's shell does not inherit CoolBox's environment either,
+		// and may be zsh, where status is read-only, so held runs in sh.
+		exports := ""
+		for _, v := range env {
+			name, value, _ := strings.Cut(v, "=")
+			exports += "export " + name + "=" + shellQuote(value) + "; "
+		}
+		script := `tell application "Terminal" to do script "cd ` + appleScriptEscape(shellQuote(cmd.Dir)) + ` && ` + appleScriptEscape(exports+"sh -c "+shellQuote(held)) + `"`
+		if err := exec.Command("osascript", "-e", script).Run(); err == nil {
+			return "in a Terminal window", nil
+		}
+	case "windows":
+		// Left to the background: cmd's quoting is not the shell's.
+	default:
+		if os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != "" {
+			for _, args := range terminalCommands {
+				if _, err := exec.LookPath(args[0]); err != nil {
+					continue
+				}
+				term := exec.Command(args[0], append(args[1:], "sh", "-c", held)...)
+				term.Dir = cmd.Dir
+				setProcessGroup(term)
+				if err := term.Start(); err != nil {
+					return "", err
+				}
+				go term.Wait()
+				return "in a " + args[0] + " window", nil
+			}
+		}
+	}
+	return spawnInBackground(cmd, target)
+}
+
+// spawnInBackground is spawn's fallback: cmd runs in its own process
+// group, out of reach of Cancel, writing to a new log file in the temporary
+// directory.
+func spawnInBackground(cmd *exec.Cmd, target string) (string, error) {
+	name := strings.NewReplacer("/", "_", `\`, "_").Replace(target)
+	log, err := os.CreateTemp("", "coolbox-"+name+"-*.log")
+	if err != nil {
+		return "", err
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = nil, log, log
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		log.Close()
+		return "", err
+	}
+	go func() {
+		cmd.Wait()
+		log.Close()
+	}()
+	return fmt.Sprintf("in the background (pid %d), logging to %s", cmd.Process.Pid, log.Name()), nil
+}
+
+// commandLine is cmd's arguments quoted for the shell.
+func commandLine(cmd *exec.Cmd) string {
+	args := make([]string, len(cmd.Args))
+	for i, arg := range cmd.Args {
+		args[i] = shellQuote(arg)
+	}
+	return strings.Join(args, " ")
+}
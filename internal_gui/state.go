@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// maxRecent caps State.Recent.
+const maxRecent = 10
+
+// maxLastRuns caps the targets kept per Makefile in State.LastRuns.
+const maxLastRuns = 50
+
+// defaultOutputSplit is the share of the TUI, in percent, given to the
+// output pane until the user resizes it.
+const defaultOutputSplit = 30
+
+// State holds UI preferences persisted between sessions.
+type State struct {
+	// OutputSplit is the percentage of the TUI height used by the output
+	// pane when it is open.
+	OutputSplit int `json:"outputSplit"`
+	// OutputPinned keeps the output pane open even when nothing has run.
+	OutputPinned bool `json:"outputPinned"`
+	// Profile is the categorization profile last selected.
+	Profile string `json:"profile,omitempty"`
+	// RunCounts maps the absolute path of a Makefile to how often each of
+	// its targets has been run.
+	RunCounts map[string]map[string]int `json:"runCounts,omitempty"`
+	// LastRuns maps the absolute path of a Makefile to the targets last
+	// run from it, oldest first, for replaying them.
+	LastRuns map[string][]string `json:"lastRuns,omitempty"`
+	// WindowWidth and WindowHeight are the last size of the GUI window.
+	WindowWidth  float32 `json:"windowWidth,omitempty"`
+	WindowHeight float32 `json:"windowHeight,omitempty"`
+	// Runners maps the absolute path of a project directory to the build
+	// tool last picked there.
+	Runners map[string]string `json:"runners,omitempty"`
+	// Recent lists the project directories CoolBox was used in, most
+	// recent first, up to maxRecent.
+	Recent []string `json:"recent,omitempty"`
+	// ProjectsRoot is the directory -pick searches for projects, as last
+	// given with -projects-root.
+	ProjectsRoot string `json:"projectsRoot,omitempty"`
+
+	path   string
+	mu     sync.Mutex // guards the fields, which are saved from run goroutines
+	saveMu sync.Mutex // keeps saves in order, so the last one written wins
+}
+
+// statePath returns the location of the state file.
+func statePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "coolbox", "state.json"), nil
+}
+
+// loadState reads the state file. Any problem reading it yields the
+// defaults, since losing preferences must never stop the tool starting.
+func loadState() *State {
+	s := &State{OutputSplit: defaultOutputSplit}
+	path, err := statePath()
+	if err != nil {
+		return s
+	}
+	s.path = path
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, s)
+	}
+	return s
+}
+
+// save writes the state file, creating its directory if needed. Runs
+// finishing at once may save together; each replaces the file whole.
+func (s *State) save() error {
+	if s.path == "" {
+		return nil
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// setOutputSplit sets OutputSplit, which a run finishing may be saving.
+// The setters below likewise leave saving to the caller.
+func (s *State) setOutputSplit(split int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.OutputSplit = split
+}
+
+// setOutputPinned sets OutputPinned.
+func (s *State) setOutputPinned(pinned bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.OutputPinned = pinned
+}
+
+// setProfile sets Profile.
+func (s *State) setProfile(profile string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Profile = profile
+}
+
+// setWindowSize sets WindowWidth and WindowHeight.
+func (s *State) setWindowSize(width, height float32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.WindowWidth, s.WindowHeight = width, height
+}
+
+// stateKey returns the absolute form of path, which keys the per-project
+// entries of the state.
+func stateKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// countRun records a run of target from makefile and saves the state.
+func (s *State) countRun(makefile, target string) {
+	key := stateKey(makefile)
+	s.mu.Lock()
+	if s.RunCounts == nil {
+		s.RunCounts = make(map[string]map[string]int)
+	}
+	if s.RunCounts[key] == nil {
+		s.RunCounts[key] = make(map[string]int)
+	}
+	s.RunCounts[key][target]++
+	if s.LastRuns == nil {
+		s.LastRuns = make(map[string][]string)
+	}
+	runs := append(s.LastRuns[key], target)
+	if len(runs) > maxLastRuns {
+		runs = runs[len(runs)-maxLastRuns:]
+	}
+	s.LastRuns[key] = runs
+	s.mu.Unlock()
+	s.save()
+}
+
+// runCount returns how often opt has been run from makefile. An alias
+// shares the count of its target.
+func (s *State) runCount(makefile string, opt MakeOption) int {
+	target := opt.Target
+	if opt.AliasOf != "" {
+		target = opt.AliasOf
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.RunCounts[stateKey(makefile)][target]
+}
+
+// lastRuns returns the last n targets run from makefile, in the order they
+// ran. There may be fewer than n.
+func (s *State) lastRuns(makefile string, n int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := s.LastRuns[stateKey(makefile)]
+	if n < len(runs) {
+		runs = runs[len(runs)-n:]
+	}
+	return append([]string(nil), runs...)
+}
+
+// pruneRunCounts forgets the counts and last runs of targets not among
+// options, so renamed or deleted targets do not linger in the state file
+// or get replayed.
+func (s *State) pruneRunCounts(makefile string, options []MakeOption) {
+	key := stateKey(makefile)
+	present := make(map[string]bool)
+	for _, opt := range options {
+		present[opt.Target] = true
+	}
+	s.mu.Lock()
+	pruned := false
+	for target := range s.RunCounts[key] {
+		if !present[target] {
+			delete(s.RunCounts[key], target)
+			pruned = true
+		}
+	}
+	if runs, ok := s.LastRuns[key]; ok {
+		kept := runs[:0]
+		for _, target := range runs {
+			if present[target] {
+				kept = append(kept, target)
+			}
+		}
+		if len(kept) < len(runs) {
+			s.LastRuns[key] = kept
+			pruned = true
+		}
+	}
+	s.mu.Unlock()
+	if pruned {
+		s.save()
+	}
+}
+
+// rememberRunner records name as the build tool picked in dir.
+func (s *State) rememberRunner(dir, name string) {
+	key := stateKey(dir)
+	s.mu.Lock()
+	if s.Runners[key] == name {
+		s.mu.Unlock()
+		return
+	}
+	if s.Runners == nil {
+		s.Runners = make(map[string]string)
+	}
+	s.Runners[key] = name
+	s.mu.Unlock()
+	s.save()
+}
+
+// addRecent moves dir to the front of the recent projects.
+func (s *State) addRecent(dir string) {
+	key := stateKey(dir)
+	s.mu.Lock()
+	if len(s.Recent) > 0 && s.Recent[0] == key {
+		s.mu.Unlock()
+		return
+	}
+	recent := []string{key}
+	for _, d := range s.Recent {
+		if d != key && len(recent) < maxRecent {
+			recent = append(recent, d)
+		}
+	}
+	s.Recent = recent
+	s.mu.Unlock()
+	s.save()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// tailInterval is how often a tailed log file is checked for new output.
+const tailInterval = 250 * time.Millisecond
+
+// fileSize returns the size of path, or 0 when it cannot be read, as the
+// offset to tail a log file from.
+func fileSize(path string) int64 {
+	if info, err := os.Stat(path); err == nil {
+		return info.Size()
+	}
+	return 0
+}
+
+// tailFile copies what is written to path past offset to w until stop is
+// closed. The file need not exist yet; when it shrinks, as when a log is
+// rotated, it is read again from the start.
+func tailFile(path string, offset int64, w io.Writer, stop <-chan struct{}) {
+	ticker := time.NewTicker(tailInterval)
+	defer ticker.Stop()
+	for {
+		offset = copyFrom(path, offset, w)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// copyFrom copies path from offset to its end to w and returns the new
+// offset.
+func copyFrom(path string, offset int64, w io.Writer) int64 {
+	file, err := os.Open(path)
+	if err != nil {
+		return offset
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err == nil && info.Size() < offset {
+		offset = 0
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return offset
+	}
+	n, _ := io.Copy(w, file)
+	return offset + n
+}
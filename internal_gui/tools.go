@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BuildTool describes a build system whose targets CoolBox can list and run.
+type BuildTool struct {
+	// Name identifies the tool in pickers and for -runner.
+	Name string
+	// Files are the build file names the tool reads, in lookup order.
+	Files []string
+	// Command is the program and leading arguments; the target is appended.
+	Command []string
+	// Parse reads the targets from a build file.
+	Parse func(path string) ([]MakeOption, error)
+}
+
+// buildTools lists the supported build systems, make first. make has no
+// Command; the Runner picks the platform's make, see makeProgram. Its
+// files are tried in make's own order, so the one listed is the one make
+// runs.
+var buildTools = []BuildTool{
+	{Name: "make", Files: makefileNames, Parse: parseMakefile},
+	{Name: "just", Files: []string{"justfile", "Justfile", ".justfile"}, Command: []string{"just"}, Parse: parseJustfile},
+	{Name: "task", Files: []string{"Taskfile.yml", "Taskfile.yaml"}, Command: []string{"task"}, Parse: parseTaskfile},
+	{Name: "npm", Files: []string{"package.json"}, Command: []string{"npm", "run"}, Parse: parsePackageJSON},
+}
+
+// findBuildTool returns the build tool called name.
+func findBuildTool(name string) (BuildTool, error) {
+	names := make([]string, len(buildTools))
+	for i, tool := range buildTools {
+		if tool.Name == name {
+			return tool, nil
+		}
+		names[i] = tool.Name
+	}
+	return BuildTool{}, fmt.Errorf("unknown runner %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// buildFile returns the path of tool's build file in dir, or "" when there
+// is none.
+func (tool BuildTool) buildFile(dir string) string {
+	for _, name := range tool.Files {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// detectBuildTools returns the build tools that have a build file in dir.
+func detectBuildTools(dir string) []BuildTool {
+	var found []BuildTool
+	for _, tool := range buildTools {
+		if tool.buildFile(dir) != "" {
+			found = append(found, tool)
+		}
+	}
+	return found
+}
+
+// promptBuildTool asks on out which of tools to use and reads the answer
+// from in, see promptChoice.
+func promptBuildTool(tools []BuildTool, in io.Reader, out io.Writer) BuildTool {
+	names := make([]string, len(tools))
+	for i, tool := range tools {
+		names[i] = tool.Name
+	}
+	return tools[promptChoice("Several build files found:", "Runner", names, in, out)]
+}
+
+// promptChoice lists choices on out under heading and reads the index of
+// the one picked from in, given as a number or the choice itself. An empty
+// answer or end of input picks the first.
+func promptChoice(heading, label string, choices []string, in io.Reader, out io.Writer) int {
+	fmt.Fprintln(out, heading)
+	for i, choice := range choices {
+		fmt.Fprintf(out, "  %d) %s\n", i+1, choice)
+	}
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprintf(out, "%s [1-%d, default 1]: ", label, len(choices))
+		if !scanner.Scan() {
+			return 0
+		}
+		answer := strings.TrimSpace(scanner.Text())
+		if answer == "" {
+			return 0
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(choices) {
+			return n - 1
+		}
+		for i, choice := range choices {
+			if choice == answer {
+				return i
+			}
+		}
+	}
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// justRecipeRe matches a justfile recipe header such as "build target='x': deps".
+var justRecipeRe = regexp.MustCompile(`^@?([A-Za-z_][A-Za-z0-9_-]*)[^:]*:(.*)$`)
+
+// parseJustfile reads the recipes from a justfile. A comment on the line
+// before a recipe is its description, as "just --list" shows it.
+func parseJustfile(path string) ([]MakeOption, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var options []MakeOption
+	var comment string
+	private := false
+	inRecipe := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inRecipe && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			if body := strings.TrimSpace(line); body != "" {
+				recipe := &options[len(options)-1].Recipe
+				if *recipe != "" {
+					*recipe += "\n"
+				}
+				*recipe += body
+			}
+			continue
+		}
+		inRecipe = false
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "#"):
+			comment = strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+			continue
+		case strings.HasPrefix(trimmed, "["):
+			// Attributes sit between a comment and its recipe; [private]
+			// hides the recipe from "just --list".
+			private = private || strings.Contains(trimmed, "private")
+			continue
+		}
+		if m := justRecipeRe.FindStringSubmatch(line); m != nil && !strings.HasPrefix(m[2], "=") {
+			opt := MakeOption{Target: m[1], Comment: comment, Internal: private, IsPhony: true, IsDefault: len(options) == 0}
+			if deps := strings.Fields(m[2]); len(deps) > 0 {
+				opt.Deps = deps
+			}
+			options = append(options, opt)
+			inRecipe = true
+		}
+		comment = ""
+		private = false
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// taskfileTask holds the fields of a Taskfile task that CoolBox shows.
+type taskfileTask struct {
+	Desc     string    `yaml:"desc"`
+	Internal bool      `yaml:"internal"`
+	Deps     yaml.Node `yaml:"deps"`
+	Cmds     yaml.Node `yaml:"cmds"`
+}
+
+// parseTaskfile reads the tasks from a Taskfile.yml in file order.
+func parseTaskfile(path string) ([]MakeOption, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Tasks yaml.Node `yaml:"tasks"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var options []MakeOption
+	for i := 0; i+1 < len(doc.Tasks.Content); i += 2 {
+		name := doc.Tasks.Content[i].Value
+		var task taskfileTask
+		// A task may be written as a bare list of commands.
+		if doc.Tasks.Content[i+1].Kind == yaml.SequenceNode {
+			task.Cmds = *doc.Tasks.Content[i+1]
+		} else if err := doc.Tasks.Content[i+1].Decode(&task); err != nil {
+			return nil, fmt.Errorf("%s: task %s: %w", path, name, err)
+		}
+		opt := MakeOption{Target: name, Comment: task.Desc, Internal: task.Internal, IsPhony: true, IsDefault: name == "default"}
+		opt.Deps = taskfileStrings(task.Deps.Content, "task")
+		opt.Recipe = strings.Join(taskfileStrings(task.Cmds.Content, "cmd"), "\n")
+		options = append(options, opt)
+	}
+	return options, nil
+}
+
+// taskfileStrings returns the scalar entries of nodes, taking key from
+// mapping entries such as "- task: build".
+func taskfileStrings(nodes []*yaml.Node, key string) []string {
+	var values []string
+	for _, node := range nodes {
+		switch node.Kind {
+		case yaml.ScalarNode:
+			values = append(values, node.Value)
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					values = append(values, node.Content[i+1].Value)
+				}
+			}
+		}
+	}
+	return values
+}
+
+// parsePackageJSON reads the npm scripts from a package.json, sorted by
+// name. Each script's command is kept as its recipe.
+func parsePackageJSON(path string) ([]MakeOption, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	names := make([]string, 0, len(pkg.Scripts))
+	for name := range pkg.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	options := make([]MakeOption, len(names))
+	for i, name := range names {
+		options[i] = MakeOption{Target: name, Recipe: pkg.Scripts[name], IsPhony: true}
+	}
+	return options, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"internal_gui/makefile"
+)
+
+// Variables reads the variables assigned in the Makefile. Only make's
+// build files have them.
+func (r *Runner) Variables() ([]makefile.Variable, error) {
+	if len(r.Command) > 0 {
+		return nil, errors.New("variables are read from a Makefile; the current runner is " + r.Program())
+	}
+	return r.ParseOptions.Variables(r.Makefile)
+}
+
+// describeVariables lists vars one per line as they are assigned, with the
+// operator kept so recursive (=), simple (:=), default (?=), shell (!=) and
+// appended (+=) variables can be told apart. A best-effort expansion
+// follows when it differs, and the file is named for variables from an
+// include.
+func describeVariables(vars []makefile.Variable, mainFile string) string {
+	if len(vars) == 0 {
+		return "No variables are assigned."
+	}
+	var b strings.Builder
+	for _, v := range vars {
+		fmt.Fprintf(&b, "%s %s %s", v.Name, v.Op, v.Value)
+		if v.File != mainFile {
+			fmt.Fprintf(&b, "  (%s:%d)", filepath.Base(v.File), v.Line)
+		}
+		b.WriteString("\n")
+		if expanded := makefile.Expand(v.Value, vars); expanded != v.Value {
+			b.WriteString("    → " + expanded + "\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// workflowsTab is the name of the tab listing the config's workflows.
+const workflowsTab = "Workflows"
+
+// Workflow is a named pipeline of targets from the config. Unlike a
+// sequence it is listed in the UIs, in the Workflows tab, and carries its
+// own options. It is written either as a plain list of steps or as a
+// mapping:
+//
+//	workflows:
+//	  check: [lint, test]
+//	  release:
+//	    description: Build and publish a release
+//	    steps: [clean, build, test, publish]
+//	    stopOnError: true
+type Workflow struct {
+	Steps       []string `yaml:"steps"`
+	Description string   `yaml:"description"`
+	// StopOnError skips the remaining steps after a failure; it defaults
+	// to true. Parallel runs every step at once instead.
+	StopOnError *bool `yaml:"stopOnError"`
+	Parallel    bool  `yaml:"parallel"`
+}
+
+func (w *Workflow) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		*w = Workflow{}
+		return node.Decode(&w.Steps)
+	}
+	type plain Workflow // without this method, to decode the mapping
+	return node.Decode((*plain)(w))
+}
+
+// keepGoing reports whether the steps after a failed one still run.
+func (w Workflow) keepGoing() bool {
+	return w.StopOnError != nil && !*w.StopOnError
+}
+
+// validateWorkflows checks, like validateSequences, that workflow names do
+// not shadow targets, aliases or sequences and that every step is a known
+// target or alias.
+func validateWorkflows(options []MakeOption, cfg *Config) error {
+	targets := make(map[string]bool, len(options))
+	for _, opt := range options {
+		targets[opt.Target] = true
+	}
+	for name, w := range cfg.Workflows {
+		if _, ok := cfg.Sequences[name]; ok || targets[name] {
+			return fmt.Errorf("workflow %q collides with a target, alias or sequence", name)
+		}
+		if len(w.Steps) == 0 {
+			return fmt.Errorf("workflow %q has no steps", name)
+		}
+		for _, step := range w.Steps {
+			if !targets[step] {
+				return fmt.Errorf("workflow %q: unknown target %q", name, step)
+			}
+		}
+	}
+	return nil
+}
+
+// workflowTab lists workflows as runnable entries, by name, described by
+// their description or else their steps. Their steps count as their
+// prerequisites, so searching by prerequisite finds them. A workflow is
+// marked Confirm when one of its steps is among options marked so.
+func workflowTab(workflows map[string]Workflow, options []MakeOption) Tab {
+	tab := Tab{Name: workflowsTab}
+	all := []Tab{{Options: options}}
+	for name, w := range workflows {
+		comment := w.Description
+		if comment == "" {
+			comment = strings.Join(w.Steps, " → ")
+		}
+		confirm := len(confirmedSteps(all, w.Steps)) > 0
+		tab.Options = append(tab.Options, MakeOption{Target: name, Comment: comment, Deps: w.Steps, IsPhony: true, Confirm: confirm})
+	}
+	sort.Slice(tab.Options, func(i, j int) bool { return tab.Options[i].Target < tab.Options[j].Target })
+	return tab
+}
+
+// RunWorkflow runs the steps of the workflow name with its options rather
+// than the runner's KeepGoing and Parallel, and records the workflow's own
+// outcome. The progress of each step and a final summary are written to
+// stdio.Stdout.
+func (r *Runner) RunWorkflow(name string, stdio Stdio) ([]StepResult, error) {
+	w := r.Workflows[name]
+	fmt.Fprintf(stdio.Stdout, "==> workflow %s: %s\n", name, strings.Join(w.Steps, " → "))
+	var results []StepResult
+	var err error
+	if w.Parallel {
+		results, err = r.runParallel(w.Steps, stdio)
+	} else {
+		results, err = r.runSequential(w.Steps, stdio, w.keepGoing())
+	}
+	if r.Outcomes != nil {
+		r.Outcomes.Record(name, err)
+	}
+	return results, err
+}